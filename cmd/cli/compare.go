@@ -0,0 +1,367 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+
+	"trufast/internal/textsearch"
+)
+
+// extendFactor is how much looser the threshold is when growing a region
+// Neighbours of a confirmed match only need to be roughly similar to be included
+const extendFactor = 2
+
+// MatchRegion is a passage of the query document aligned with a passage of the indexed file
+type MatchRegion struct {
+	QueryStart int64
+	// QueryStart is the byte offset where the passage begins in the query document
+
+	QueryEnd int64
+	// QueryEnd is the byte offset just past the passage in the query document
+
+	SourceDoc int
+	// SourceDoc is the index of the indexed document holding the aligned passage
+
+	SourceStart int64
+	// SourceStart is the byte offset where the aligned passage begins in the indexed file
+
+	SourceEnd int64
+	// SourceEnd is the byte offset just past the aligned passage in the indexed file
+
+	Chunks int
+	// Chunks is the number of aligned chunk pairs in the region
+
+	Distance float64
+	// Distance is the mean Hamming distance of the aligned chunk pairs
+}
+
+// CompareOptions groups the optional settings of the compare command
+type CompareOptions struct {
+	DiffFormat string
+	// DiffFormat selects word-level diff output ("text", "html" or "json") for each region
+
+	IgnoreQuotes bool
+	// IgnoreQuotes leaves text within quotation marks and block quotes of the
+	// query out of the comparison, so properly quoted citations are not reported
+
+	Format string
+	// Format selects an exported report ("html", "markdown", "csv" or "json")
+	// instead of the plain listing
+
+	Out string
+	// Out is the path the report is written to; empty writes to stdout
+
+	Output string
+	// Output selects machine-readable output on stdout ("json", "ndjson" or "tsv")
+	// instead of the plain listing
+}
+
+// Comparison is the result of comparing a query document against an index
+type Comparison struct {
+	Query *Index
+	// Query is the in-memory index of the query document, chunked like the source index
+
+	Duplicates []DocumentMatch
+	// Duplicates lists indexed documents that are near-duplicates of the whole query
+
+	Regions []MatchRegion
+	// Regions lists aligned passages ordered by position in the query document
+
+	MatchedBytes int64
+	// MatchedBytes is the number of query bytes covered by at least one region
+
+	TotalBytes int64
+	// TotalBytes is the size of the query document
+
+	Originality float64
+	// Originality is the percentage of the query not covered by any region (0-100)
+}
+
+// compareCommand handles the compare command
+// It checks a whole query document against an index and reports which passages
+// of the query match which passages of the indexed file
+// Parameters:
+//
+//	indexFile: Path to the previously generated index file
+//	queryFile: Path to the document to check
+//	opts: Optional diff format and quotation handling
+//
+// Returns:
+//
+//	error: nil on success, error if operation fails
+func compareCommand(indexFile string, queryFile string, opts CompareOptions) error {
+	// Validate parameters
+	if indexFile == "" {
+		return fmt.Errorf("error: index file is required")
+		// Ensures an index file path was provided via -i flag
+	}
+	if queryFile == "" {
+		return fmt.Errorf("error: query document is required")
+		// Ensures a query document path was provided via -f flag
+	}
+	if err := validateDiffFormat(opts.DiffFormat); err != nil {
+		return err
+	}
+	format, err := reportFormat(opts.Format, opts.Out)
+	if err != nil {
+		return err
+	}
+	if format != "" && opts.DiffFormat != "" {
+		return fmt.Errorf("error: choose either a diff or a report format")
+	}
+	if err := validateOutputFormat(opts.Output); err != nil {
+		return err
+	}
+	if err := checkOutputFlags("compare", format, opts.Output); err != nil {
+		return err
+	}
+	if opts.Output != "" && opts.DiffFormat != "" {
+		return fmt.Errorf("error: choose either an output mode or a diff format")
+	}
+
+	// Load the index from file into memory
+	index, err := loadIndex(indexFile)
+	if err != nil {
+		return err
+	}
+
+	comparison, err := compareDocument(index, queryFile, opts.IgnoreQuotes)
+	if err != nil {
+		return err
+	}
+
+	// Write machine-readable hits instead of the listing when requested
+	if opts.Output != "" {
+		hits, err := compareHits(index, comparison)
+		if err != nil {
+			return err
+		}
+		return writeHits(os.Stdout, opts.Output, compareOutputSchema, compareHitHeader, hits)
+	}
+
+	// Export a report instead of printing the regions when requested
+	if format != "" {
+		report, err := compareReport(index, indexFile, queryFile, comparison)
+		if err != nil {
+			return err
+		}
+		return writeReport(report, format, opts.Out)
+	}
+
+	// Print word-level diffs of each region when requested
+	if opts.DiffFormat != "" {
+		reports, err := diffRegions(index, comparison)
+		if err != nil {
+			return err
+		}
+		return printDiffReports(reports, opts.DiffFormat)
+	}
+
+	source := index.FilePath
+	if len(index.Documents) > 1 {
+		source = fmt.Sprintf("%d documents", len(index.Documents))
+	}
+	fmt.Printf("Compared %s against %s (%d chunk(s) of %d bytes)\n", queryFile, source, len(comparison.Query.Chunks), index.ChunkSize)
+	for _, duplicate := range comparison.Duplicates {
+		fmt.Printf("Near-duplicate of %s (document distance %d)\n", indexDocument(index, duplicate.Doc).Path, duplicate.Distance)
+	}
+	queryDoc := indexDocument(comparison.Query, 0)
+	for i, region := range comparison.Regions {
+		sourceDoc := indexDocument(index, region.SourceDoc)
+		queryFirst, _ := lineColumn(queryDoc, region.QueryStart)
+		queryLast, _ := lineColumn(queryDoc, max(region.QueryEnd-1, region.QueryStart))
+		sourceFirst, _ := lineColumn(sourceDoc, region.SourceStart)
+		sourceLast, _ := lineColumn(sourceDoc, max(region.SourceEnd-1, region.SourceStart))
+
+		var label string
+		if len(index.Documents) > 1 {
+			label = " of " + sourceDoc.Path
+		}
+		fmt.Printf("Region %d: query bytes %d-%d (lines %d-%d) <= source bytes %d-%d%s (lines %d-%d), %d chunk(s), mean distance %.1f\n",
+			i+1, region.QueryStart, region.QueryEnd, queryFirst, queryLast,
+			region.SourceStart, region.SourceEnd, label, sourceFirst, sourceLast,
+			region.Chunks, region.Distance)
+	}
+
+	// Print summary
+	fmt.Println("\n---")
+	fmt.Printf("\nMatched %d of %d query bytes in %d region(s).\n", comparison.MatchedBytes, comparison.TotalBytes, len(comparison.Regions))
+	fmt.Printf("Originality score: %.1f%%\n", comparison.Originality)
+	return nil
+}
+
+// compareDocument aligns a query document with an index using seed-and-extend
+// The query is chunked with the index's chunk size; every query chunk close to an
+// indexed chunk is a seed, and each seed is extended along its diagonal (query chunk
+// i+1 against source chunk j+1, and so on) while neighbours stay roughly similar
+// Seeds are only sought in sections that can hold a close chunk (see candidateChunks),
+// and whole-document fingerprints report near-duplicates of the entire query
+// Regions never cross document boundaries or include suppressed (boilerplate) chunks
+// Parameters:
+//
+//	index: Pointer to the loaded Index structure
+//	queryFile: Path to the document to check
+//	ignoreQuotes: Blank out quotations in the query before fingerprinting it
+//
+// Returns:
+//
+//	*Comparison: Aligned regions and originality figures
+//	error: nil on success, error if the query cannot be read
+func compareDocument(index *Index, queryFile string, ignoreQuotes bool) (*Comparison, error) {
+	if err := validateChunkSize(index.ChunkSize); err != nil {
+		return nil, err
+	}
+	query, err := queryIndex(queryFile, index.ChunkSize, indexHashBits(index), ignoreQuotes)
+	if err != nil {
+		return nil, err
+	}
+	if index.IDF != nil {
+		applyWeights(query, index.IDF)
+		// The query must be fingerprinted with the corpus's weights
+	}
+
+	distance := func(i, j int) int {
+		return HammingDistance(query.Chunks[i].Hash, index.Chunks[j].Hash)
+	}
+	// extends reports whether source chunk j may continue a region in document doc
+	threshold := hammingThreshold(index)
+	extends := func(i, j, doc int) bool {
+		return index.Chunks[j].Doc == doc && !index.Chunks[j].Suppressed && distance(i, j) <= extendFactor*threshold
+	}
+
+	// Seeds: every (query chunk, source chunk) pair within the lookup threshold
+	type seed struct{ query, source int }
+	var seeds []seed
+	for i, chunk := range query.Chunks {
+		// Coarse level: only sections that can hold a chunk close to this one
+		candidates, ok := candidateChunks(index, chunk.Hash, threshold)
+		if !ok {
+			for hash, chunkIndices := range index.HashToChunks {
+				if HammingDistance(chunk.Hash, hash) <= threshold {
+					for _, j := range chunkIndices {
+						if !index.Chunks[j].Suppressed {
+							seeds = append(seeds, seed{i, j})
+						}
+					}
+				}
+			}
+			continue
+		}
+		// Fine level: the chunks of those sections
+		for _, j := range candidates {
+			if !index.Chunks[j].Suppressed && distance(i, j) <= threshold {
+				seeds = append(seeds, seed{i, j})
+			}
+		}
+	}
+	sort.Slice(seeds, func(a, b int) bool {
+		if seeds[a].query != seeds[b].query {
+			return seeds[a].query < seeds[b].query
+		}
+		return seeds[a].source < seeds[b].source
+	})
+
+	// Extend each seed that is not already part of a region
+	comparison := &Comparison{Query: query, Duplicates: nearDuplicates(index, query)}
+	aligned := make(map[seed]bool)
+	coveredChunks := make(map[int]bool)
+	for _, s := range seeds {
+		if aligned[s] {
+			continue
+		}
+
+		doc := index.Chunks[s.source].Doc
+		first := s
+		for first.query > 0 && first.source > 0 && extends(first.query-1, first.source-1, doc) {
+			first = seed{first.query - 1, first.source - 1}
+		}
+		last := s
+		for last.query < len(query.Chunks)-1 && last.source < len(index.Chunks)-1 && extends(last.query+1, last.source+1, doc) {
+			last = seed{last.query + 1, last.source + 1}
+		}
+
+		var total int
+		for k := 0; k <= last.query-first.query; k++ {
+			pair := seed{first.query + k, first.source + k}
+			aligned[pair] = true
+			coveredChunks[pair.query] = true
+			total += distance(pair.query, pair.source)
+		}
+		chunks := last.query - first.query + 1
+
+		lastQuery, lastSource := query.Chunks[last.query], index.Chunks[last.source]
+		comparison.Regions = append(comparison.Regions, MatchRegion{
+			QueryStart:  query.Chunks[first.query].Offset,
+			QueryEnd:    lastQuery.Offset + int64(lastQuery.Size),
+			SourceDoc:   doc,
+			SourceStart: index.Chunks[first.source].Offset,
+			SourceEnd:   lastSource.Offset + int64(lastSource.Size),
+			Chunks:      chunks,
+			Distance:    float64(total) / float64(chunks),
+		})
+	}
+	sort.SliceStable(comparison.Regions, func(a, b int) bool {
+		return comparison.Regions[a].QueryStart < comparison.Regions[b].QueryStart
+	})
+
+	// Originality is the share of query bytes not covered by any region
+	for i, chunk := range query.Chunks {
+		comparison.TotalBytes += int64(chunk.Size)
+		if coveredChunks[i] {
+			comparison.MatchedBytes += int64(chunk.Size)
+		}
+	}
+	comparison.Originality = 100
+	if comparison.TotalBytes > 0 {
+		comparison.Originality = 100 * float64(comparison.TotalBytes-comparison.MatchedBytes) / float64(comparison.TotalBytes)
+	}
+	return comparison, nil
+}
+
+// queryIndex fingerprints the query document of a comparison
+// With ignoreQuotes, quotations are blanked out first; byte offsets are unchanged,
+// so regions still point into the original file
+func queryIndex(queryFile string, chunkSize int, hashBits int, ignoreQuotes bool) (*Index, error) {
+	if !ignoreQuotes {
+		return createIndexBits(queryFile, chunkSize, hashBits)
+	}
+	data, err := os.ReadFile(queryFile)
+	if err != nil {
+		return nil, fmt.Errorf("%w. Check the file path and try again", err)
+	}
+	masked := maskQuotations(data)
+	return buildIndex(bytes.NewReader(masked), queryFile, int64(len(masked)), chunkSize, hashBits)
+}
+
+// diffRegions diffs the query text of each region against its aligned source text
+// Parameters:
+//
+//	index: Pointer to the loaded Index structure
+//	comparison: Result of compareDocument
+//
+// Returns:
+//
+//	[]DiffReport: One report per region, in the same order
+//	error: nil on success, error if reading either file fails
+func diffRegions(index *Index, comparison *Comparison) ([]DiffReport, error) {
+	reports := make([]DiffReport, 0, len(comparison.Regions))
+	for _, region := range comparison.Regions {
+		queryText, err := getChunkContent(comparison.Query.FilePath, region.QueryStart, int(region.QueryEnd-region.QueryStart))
+		if err != nil {
+			return nil, err
+		}
+		sourceText, err := getChunkContent(indexDocument(index, region.SourceDoc).Path, region.SourceStart, int(region.SourceEnd-region.SourceStart))
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, DiffReport{
+			Offset: region.QueryStart,
+			Start:  region.SourceStart,
+			End:    region.SourceEnd,
+			Ops:    textsearch.DiffWords(sourceText, queryText),
+		})
+	}
+	return reports, nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCompareDocument(t *testing.T) {
+	index, err := createIndex("../../resources/original.txt", 64)
+	if err != nil {
+		t.Fatalf("createIndex failed: %v", err)
+	}
+
+	// The plagiarised copy changes two words, so it aligns as one region
+	comparison, err := compareDocument(index, "../../resources/plagirized.txt", false)
+	if err != nil {
+		t.Fatalf("compareDocument failed: %v", err)
+	}
+	if len(comparison.Regions) != 1 {
+		t.Fatalf("Expected 1 region, got %+v", comparison.Regions)
+	}
+	region := comparison.Regions[0]
+	if region.QueryStart != 0 || region.QueryEnd != comparison.TotalBytes || region.SourceStart != 0 {
+		t.Errorf("Unexpected region: %+v", region)
+	}
+	if comparison.Originality != 0 {
+		t.Errorf("Expected originality 0, got %.1f", comparison.Originality)
+	}
+
+	// Unrelated text has no regions
+	comparison, err = compareDocument(index, "../../resources/t.txt", false)
+	if err != nil {
+		t.Fatalf("compareDocument failed: %v", err)
+	}
+	if len(comparison.Regions) != 0 || comparison.Originality != 100 {
+		t.Errorf("Expected fully original document, got %d region(s), %.1f%%", len(comparison.Regions), comparison.Originality)
+	}
+}
+
+func TestCompareDocumentPartial(t *testing.T) {
+	index, err := createIndex("../../resources/original.txt", 64)
+	if err != nil {
+		t.Fatalf("createIndex failed: %v", err)
+	}
+
+	// An essay that quotes the source after an original introduction
+	source, err := os.ReadFile("../../resources/original.txt")
+	if err != nil {
+		t.Fatalf("Failed to read source: %v", err)
+	}
+	intro := strings.Repeat("Lorem ipsum dolor sit amet, consectetur adipiscing elit nunc. ", 4)
+	file := "test_essay.txt"
+	os.WriteFile(file, []byte(intro+string(source)), 0644)
+	defer os.Remove(file)
+
+	comparison, err := compareDocument(index, file, false)
+	if err != nil {
+		t.Fatalf("compareDocument failed: %v", err)
+	}
+	if len(comparison.Regions) == 0 {
+		t.Fatalf("Expected the copied passage to be found")
+	}
+	if first := comparison.Regions[0]; first.QueryStart < int64(len(intro)) || first.SourceStart != 0 {
+		t.Errorf("Expected region after the introduction aligned with the source start, got %+v", first)
+	}
+	if comparison.Originality <= 0 || comparison.Originality >= 100 {
+		t.Errorf("Expected partial originality, got %.1f", comparison.Originality)
+	}
+}
+
+func Test_compareCommand(t *testing.T) {
+	indexFile := "test_compare.idx"
+	defer os.Remove(indexFile)
+
+	if err := indexCommand("../../resources/original.txt", 64, indexFile, IndexOptions{}); err != nil {
+		t.Fatalf("indexCommand failed: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		queryFile string
+		diff      string
+		output    string
+		wantErr   bool
+	}{
+		{"summary", "../../resources/plagirized.txt", "", "", false},
+		{"diff", "../../resources/plagirized.txt", "text", "", false},
+		{"ndjson output", "../../resources/plagirized.txt", "", "ndjson", false},
+		{"missing query", "", "", "", true},
+		{"query not found", "testdata/nonexistent.txt", "", "", true},
+		{"bad diff format", "../../resources/plagirized.txt", "xml", "", true},
+		{"bad output format", "../../resources/plagirized.txt", "", "xml", true},
+		{"diff and output", "../../resources/plagirized.txt", "text", "json", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := compareCommand(indexFile, tt.queryFile, CompareOptions{DiffFormat: tt.diff, Output: tt.output}); (err != nil) != tt.wantErr {
+				t.Errorf("compareCommand() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
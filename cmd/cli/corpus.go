@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"trufast/internal/textsearch"
+)
+
+// expandInputs turns the -i argument into the list of files to index
+// The argument may be a single file, a comma-separated list of files, or a
+// directory, in which case every regular file directly inside it is indexed
+// Parameters:
+//
+//	input: Value of the -i flag
+//
+// Returns:
+//
+//	[]string: File paths in a stable order
+//	error: nil on success, error if a path cannot be read or nothing is left to index
+func expandInputs(input string) ([]string, error) {
+	var paths []string
+	for _, part := range strings.Split(input, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		info, err := os.Stat(part)
+		if err != nil || !info.IsDir() {
+			paths = append(paths, part)
+			// Missing files are reported by createIndex with its usual message
+			continue
+		}
+
+		entries, err := os.ReadDir(part)
+		if err != nil {
+			return nil, fmt.Errorf("error reading directory: %w", err)
+		}
+		for _, entry := range entries {
+			if entry.Type().IsRegular() {
+				paths = append(paths, filepath.Join(part, entry.Name()))
+			}
+		}
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("error: no files to index in %q", input)
+	}
+	return paths, nil
+}
+
+// createCorpusIndex indexes several files as separate documents of one index
+// Each file is indexed with createIndex and the results are concatenated;
+// chunk offsets stay relative to their own document
+// Parameters:
+//
+//	paths: Files to index, one document each
+//	chunkSize: Size in bytes for each chunk
+//	hashBits: Fingerprint width in bits (64, 128 or 256)
+//
+// Returns:
+//
+//	*Index: Pointer to the combined Index structure
+//	error: nil on success, error if any file cannot be indexed
+func createCorpusIndex(paths []string, chunkSize int, hashBits int) (*Index, error) {
+	if len(paths) == 1 {
+		return createIndexBits(paths[0], chunkSize, hashBits)
+	}
+
+	corpus := &Index{
+		FilePath:     paths[0],
+		ChunkSize:    chunkSize,
+		HashBits:     hashBits,
+		HashToChunks: make(map[Fingerprint][]int),
+		Postings:     make(map[string][]Posting),
+	}
+	for doc, path := range paths {
+		index, err := createIndexBits(path, chunkSize, hashBits)
+		if err != nil {
+			return nil, err
+		}
+
+		// Shift chunk indices past the documents already added
+		base := len(corpus.Chunks)
+		for i, chunk := range index.Chunks {
+			chunk.Doc = doc
+			corpus.Chunks = append(corpus.Chunks, chunk)
+			corpus.HashToChunks[chunk.Hash] = append(corpus.HashToChunks[chunk.Hash], base+i)
+		}
+		for term, postings := range index.Postings {
+			for _, posting := range postings {
+				corpus.Postings[term] = append(corpus.Postings[term], Posting{Chunk: base + posting.Chunk, Freq: posting.Freq})
+			}
+		}
+		corpus.Documents = append(corpus.Documents, index.Documents...)
+	}
+	corpus.Vocabulary = textsearch.BuildVocabulary(corpus.Postings)
+	buildHierarchy(corpus)
+	return corpus, nil
+}
+
+// indexDocument returns the document with the given position in the index
+// Indexes created before multi-document support describe their single file
+// through FilePath only, which is returned as a document without a line table
+func indexDocument(index *Index, doc int) *Document {
+	if doc >= 0 && doc < len(index.Documents) {
+		return &index.Documents[doc]
+	}
+	return &Document{Path: index.FilePath}
+}
+
+// chunkDocument returns the document a chunk belongs to
+func chunkDocument(index *Index, chunk ChunkInfo) *Document {
+	return indexDocument(index, chunk.Doc)
+}
+
+// chunkAt returns the index of the chunk of a document starting at the given offset
+// The first chunk of the index is returned when no chunk starts there
+func chunkAt(index *Index, doc int, offset int64) int {
+	for i, chunk := range index.Chunks {
+		if chunk.Doc == doc && chunk.Offset == offset {
+			return i
+		}
+	}
+	return 0
+}
+
+// documentLabel names the document of a chunk for result listings
+// Single-document indexes need no label, so an empty string is returned
+func documentLabel(index *Index, chunk ChunkInfo) string {
+	if len(index.Documents) <= 1 {
+		return ""
+	}
+	return " in " + chunkDocument(index, chunk).Path
+}
+
+// markSuppressed recomputes which chunks are boilerplate
+// A chunk is suppressed when its hash is within the Hamming threshold of a registered
+// exclusion fingerprint, or when the same hash occurs in more than
+// CommonThreshold percent of the documents
+func markSuppressed(index *Index) {
+	for i := range index.Chunks {
+		index.Chunks[i].Suppressed = false
+	}
+
+	// Fingerprints shared by too many documents
+	if index.CommonThreshold > 0 && len(index.Documents) > 1 {
+		for _, chunkIndices := range index.HashToChunks {
+			docs := make(map[int]bool)
+			for _, chunkIdx := range chunkIndices {
+				docs[index.Chunks[chunkIdx].Doc] = true
+			}
+			share := 100 * float64(len(docs)) / float64(len(index.Documents))
+			if share > index.CommonThreshold {
+				for _, chunkIdx := range chunkIndices {
+					index.Chunks[chunkIdx].Suppressed = true
+				}
+			}
+		}
+	}
+
+	// Fingerprints close to the registered exclusion corpus
+	if len(index.Excluded) > 0 {
+		threshold := hammingThreshold(index)
+		for hash, chunkIndices := range index.HashToChunks {
+			for _, excluded := range index.Excluded {
+				if HammingDistance(hash, excluded) <= threshold {
+					for _, chunkIdx := range chunkIndices {
+						index.Chunks[chunkIdx].Suppressed = true
+					}
+					break
+				}
+			}
+		}
+	}
+}
+
+// excludeCommand handles the exclude command
+// It registers boilerplate files whose fingerprints are subtracted from all results
+// Parameters:
+//
+//	indexFile: Path to the previously generated index file (updated in place)
+//	exclusionFiles: Comma-separated list of files or directories with expected text
+//
+// Returns:
+//
+//	error: nil on success, error if operation fails
+func excludeCommand(indexFile string, exclusionFiles string) error {
+	// Validate parameters
+	if indexFile == "" {
+		return fmt.Errorf("error: index file is required")
+	}
+	if exclusionFiles == "" {
+		return fmt.Errorf("error: exclusion corpus is required")
+		// Ensures boilerplate files were provided via -f flag
+	}
+
+	index, err := loadIndex(indexFile)
+	if err != nil {
+		return err
+	}
+	if err := validateChunkSize(index.ChunkSize); err != nil {
+		return err
+	}
+
+	paths, err := expandInputs(exclusionFiles)
+	if err != nil {
+		return err
+	}
+
+	// Fingerprint the boilerplate with the index's own chunking
+	known := make(map[Fingerprint]bool, len(index.Excluded))
+	for _, hash := range index.Excluded {
+		known[hash] = true
+	}
+	added := 0
+	for _, path := range paths {
+		boilerplate, err := createIndexBits(path, index.ChunkSize, indexHashBits(index))
+		if err != nil {
+			return err
+		}
+		if index.IDF != nil {
+			applyWeights(boilerplate, index.IDF)
+		}
+		for _, chunk := range boilerplate.Chunks {
+			if !known[chunk.Hash] {
+				known[chunk.Hash] = true
+				index.Excluded = append(index.Excluded, chunk.Hash)
+				added++
+			}
+		}
+	}
+
+	markSuppressed(index)
+	if err := saveIndex(index, indexFile); err != nil {
+		return err
+	}
+
+	suppressed := 0
+	for _, chunk := range index.Chunks {
+		if chunk.Suppressed {
+			suppressed++
+		}
+	}
+	fmt.Printf("Registered %d exclusion fingerprint(s); %d of %d chunks are suppressed\n", added, suppressed, len(index.Chunks))
+	return nil
+}
+
+// maskQuotations blanks out quoted passages so they do not count as matches
+// Text between double quotes (straight or curly) and lines starting with '>'
+// (block quotes) are replaced by spaces, keeping every byte offset unchanged
+// A quote that is not closed before the next blank line is left alone
+func maskQuotations(text []byte) []byte {
+	masked := make([]byte, len(text))
+	copy(masked, text)
+	blank := func(from, to int) {
+		for i := from; i < to; i++ {
+			if masked[i] != '\n' {
+				masked[i] = ' '
+			}
+		}
+	}
+
+	// Block quotes
+	for start := 0; start < len(masked); {
+		end := bytes.IndexByte(masked[start:], '\n')
+		if end < 0 {
+			end = len(masked)
+		} else {
+			end += start
+		}
+		if trimmed := bytes.TrimLeft(masked[start:end], " \t"); len(trimmed) > 0 && trimmed[0] == '>' {
+			blank(start, end)
+		}
+		start = end + 1
+	}
+
+	// Quotation marks; each opening mark has a matching closing mark
+	pairs := [][2]string{{`"`, `"`}, {"“", "”"}}
+	for i := 0; i < len(masked); i++ {
+		for _, pair := range pairs {
+			if !bytes.HasPrefix(masked[i:], []byte(pair[0])) {
+				continue
+			}
+			rest := masked[i+len(pair[0]):]
+			closing := bytes.Index(rest, []byte(pair[1]))
+			if closing < 0 || bytes.Contains(rest[:closing], []byte("\n\n")) {
+				break
+			}
+			end := i + len(pair[0]) + closing + len(pair[1])
+			blank(i, end)
+			i = end - 1
+			break
+		}
+	}
+	return masked
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeCorpus creates a directory of documents that share a common header
+func writeCorpus(t *testing.T, header string, bodies ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for i, body := range bodies {
+		name := filepath.Join(dir, string(rune('a'+i))+".txt")
+		if err := os.WriteFile(name, []byte(header+body), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	return dir
+}
+
+func TestExpandInputs(t *testing.T) {
+	dir := writeCorpus(t, "", "one", "two")
+	os.Mkdir(filepath.Join(dir, "sub"), 0755)
+
+	got, err := expandInputs(dir + ", extra.txt")
+	if err != nil {
+		t.Fatalf("expandInputs failed: %v", err)
+	}
+	want := []string{filepath.Join(dir, "a.txt"), filepath.Join(dir, "b.txt"), "extra.txt"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expandInputs() = %v, want %v", got, want)
+	}
+
+	if _, err := expandInputs(" , "); err == nil {
+		t.Errorf("Expected error for empty input list")
+	}
+}
+
+func TestCreateCorpusIndex(t *testing.T) {
+	dir := writeCorpus(t, "", "alpha beta gamma", "delta alpha")
+	paths, _ := expandInputs(dir)
+
+	index, err := createCorpusIndex(paths, 16, 64)
+	if err != nil {
+		t.Fatalf("createCorpusIndex failed: %v", err)
+	}
+	if len(index.Documents) != 2 || index.Documents[1].Path != paths[1] || index.Documents[1].Size != 11 {
+		t.Fatalf("Unexpected documents: %+v", index.Documents)
+	}
+
+	// The second document starts again at offset 0
+	second := index.Chunks[len(index.Chunks)-1]
+	if second.Doc != 1 || second.Offset != 0 {
+		t.Errorf("Expected first chunk of document 1 at offset 0, got %+v", second)
+	}
+
+	// Postings point at chunks of both documents
+	docs := make(map[int]bool)
+	for _, posting := range index.Postings["alpha"] {
+		docs[index.Chunks[posting.Chunk].Doc] = true
+	}
+	if len(docs) != 2 {
+		t.Errorf("Expected alpha in both documents, got postings %+v", index.Postings["alpha"])
+	}
+	for hash, chunkIndices := range index.HashToChunks {
+		for _, chunkIdx := range chunkIndices {
+			if index.Chunks[chunkIdx].Hash != hash {
+				t.Errorf("HashToChunks points at chunk %d with a different hash", chunkIdx)
+			}
+		}
+	}
+}
+
+func TestMarkSuppressed(t *testing.T) {
+	header := "Licensed under the Apache License Version 2.0 see LICENSE\n"
+	dir := writeCorpus(t, header,
+		"The tiger hunted quietly through the tall grass at dusk.\n",
+		"Seven ships sailed north across the frozen grey ocean.\n",
+		"Bread rises slowly when the kitchen is cold in winter.\n",
+	)
+	paths, _ := expandInputs(dir)
+	index, err := createCorpusIndex(paths, len(header), 64)
+	if err != nil {
+		t.Fatalf("createCorpusIndex failed: %v", err)
+	}
+
+	// The header occurs in every document, which is above a 50% threshold
+	index.CommonThreshold = 50
+	markSuppressed(index)
+	for _, chunk := range index.Chunks {
+		if want := chunk.Offset == 0; chunk.Suppressed != want {
+			t.Errorf("Chunk at %d of document %d: suppressed = %v, want %v", chunk.Offset, chunk.Doc, chunk.Suppressed, want)
+		}
+	}
+	headerHash := index.Chunks[0].Hash
+	if matches, _ := lookupQuery(index, headerHash); len(matches) != 0 {
+		t.Errorf("Expected suppressed header to be left out of lookups, got %d match(es)", len(matches))
+	}
+
+	// A threshold of 100% never suppresses anything
+	index.CommonThreshold = 100
+	markSuppressed(index)
+	if matches, _ := lookupQuery(index, headerHash); len(matches) != 3 {
+		t.Errorf("Expected header in 3 documents, got %d match(es)", len(matches))
+	}
+
+	// Registered exclusion fingerprints suppress near matches regardless of threshold
+	index.Excluded = []Fingerprint{{headerHash[0] ^ 1}}
+	markSuppressed(index)
+	if matches, _ := lookupQuery(index, headerHash); len(matches) != 0 {
+		t.Errorf("Expected excluded header to be left out of lookups, got %d match(es)", len(matches))
+	}
+	if results := bm25Search(index, "apache license"); len(results) != 0 {
+		t.Errorf("Expected no keyword hits in boilerplate, got %+v", results)
+	}
+}
+
+func TestMaskQuotations(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"straight quotes", `He said "stay here" twice.`, `He said             twice.`},
+		{"curly quotes", "A “quoted” word", "A " + strings.Repeat(" ", len("“quoted”")) + " word"},
+		{"block quote", "Intro\n> quoted line\nOutro", "Intro\n" + strings.Repeat(" ", 13) + "\nOutro"},
+		{"unclosed quote", "a \"dangling\n\nparagraph\" end", "a \"dangling\n\nparagraph\" end"},
+		{"multi-line quote", "x \"one\ntwo\" y", "x     \n     y"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(maskQuotations([]byte(tt.text)))
+			if got != tt.want {
+				t.Errorf("maskQuotations() = %q, want %q", got, tt.want)
+			}
+			if len(got) != len(tt.text) {
+				t.Errorf("maskQuotations() changed length from %d to %d", len(tt.text), len(got))
+			}
+		})
+	}
+}
+
+func Test_excludeCommand(t *testing.T) {
+	header := "Licensed under the Apache License Version 2.0 see LICENSE\n"
+	dir := writeCorpus(t, header,
+		"The tiger hunted quietly through the tall grass at dusk.\n",
+		"Seven ships sailed north across the frozen grey ocean.\n",
+	)
+	boilerplate := filepath.Join(t.TempDir(), "license.txt")
+	os.WriteFile(boilerplate, []byte(header), 0644)
+
+	indexFile := "test_exclude.idx"
+	defer os.Remove(indexFile)
+	if err := indexCommand(dir, len(header), indexFile, IndexOptions{}); err != nil {
+		t.Fatalf("indexCommand failed: %v", err)
+	}
+
+	if err := excludeCommand(indexFile, ""); err == nil {
+		t.Errorf("Expected error for missing exclusion corpus")
+	}
+	if err := excludeCommand(indexFile, boilerplate); err != nil {
+		t.Fatalf("excludeCommand failed: %v", err)
+	}
+
+	index, err := loadIndex(indexFile)
+	if err != nil {
+		t.Fatalf("loadIndex failed: %v", err)
+	}
+	if len(index.Excluded) != 1 {
+		t.Errorf("Expected 1 exclusion fingerprint, got %d", len(index.Excluded))
+	}
+	if err := lookupCommand(indexFile, index.Chunks[0].Hash, LookupOptions{}); err == nil {
+		t.Errorf("Expected lookup of excluded header to find nothing")
+	}
+	if err := lookupCommand(indexFile, index.Chunks[1].Hash, LookupOptions{}); err != nil {
+		t.Errorf("lookupCommand failed for document body: %v", err)
+	}
+}
+
+func TestCompareDocumentIgnoreQuotes(t *testing.T) {
+	index, err := createIndex("../../resources/original.txt", 64)
+	if err != nil {
+		t.Fatalf("createIndex failed: %v", err)
+	}
+	source, err := os.ReadFile("../../resources/original.txt")
+	if err != nil {
+		t.Fatalf("Failed to read source: %v", err)
+	}
+
+	// The whole source, properly quoted
+	file := "test_quoted.txt"
+	os.WriteFile(file, []byte(`"`+string(source)+`"`), 0644)
+	defer os.Remove(file)
+
+	comparison, err := compareDocument(index, file, true)
+	if err != nil {
+		t.Fatalf("compareDocument failed: %v", err)
+	}
+	if len(comparison.Regions) != 0 {
+		t.Errorf("Expected quoted text to be ignored, got %+v", comparison.Regions)
+	}
+}
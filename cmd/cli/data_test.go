@@ -3,22 +3,21 @@ package main
 import (
 	"encoding/gob"
 	"os"
+	"path/filepath"
 	"testing"
 )
 
-// setupTestData creates test files for the tests.
-func setupTestData(t *testing.T) {
-	err := os.Mkdir("testdata", 0755)
-	if err != nil && !os.IsExist(err) {
-		t.Fatalf("Failed to create testdata directory: %v", err)
-	}
+// setupTestData creates test files for the tests in a temporary directory.
+// It returns the directory; the checked-in files under testdata are left alone.
+func setupTestData(t *testing.T) string {
+	dir := t.TempDir()
 
 	// Create a valid index file
 	validIndex := Index{
-		HashToChunks: map[uint64][]int{123: {0, 1}},
+		HashToChunks: map[Fingerprint][]int{{123}: {0, 1}},
 		Chunks:       []ChunkInfo{{Offset: 10, Size: 100}, {Offset: 110, Size: 200}},
 	}
-	validFile, err := os.Create("testdata/valid_index.gob")
+	validFile, err := os.Create(filepath.Join(dir, "valid_index.gob"))
 	if err != nil {
 		t.Fatalf("Failed to create valid index file: %v", err)
 	}
@@ -30,7 +29,7 @@ func setupTestData(t *testing.T) {
 	validFile.Close()
 
 	// Create an invalid index file (corrupted)
-	invalidFile, err := os.Create("testdata/invalid_index.gob")
+	invalidFile, err := os.Create(filepath.Join(dir, "invalid_index.gob"))
 	if err != nil {
 		t.Fatalf("Failed to create invalid index file: %v", err)
 	}
@@ -41,9 +40,11 @@ func setupTestData(t *testing.T) {
 	invalidFile.Close()
 
 	// Create an empty file
-	emptyFile, err := os.Create("testdata/empty.gob")
+	emptyFile, err := os.Create(filepath.Join(dir, "empty.gob"))
 	if err != nil {
 		t.Fatalf("Failed to create empty index file: %v", err)
 	}
 	emptyFile.Close()
+
+	return dir
 }
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"trufast/internal/textsearch"
+)
+
+const (
+	ansiDeleted  = "\033[31m"
+	ansiInserted = "\033[32m"
+	// ANSI colours for removed (red) and added (green) words
+)
+
+// DiffReport is the diff of a query against one matched region of the indexed file
+type DiffReport struct {
+	Offset int64 `json:"offset"`
+	// Offset is the byte offset of the matching chunk for lookup,
+	// or of the aligned passage in the query document for compare
+
+	Start int64 `json:"start"`
+	// Start is the byte offset where the compared source region begins
+
+	End int64 `json:"end"`
+	// End is the byte offset just past the compared source region
+
+	Ops []textsearch.DiffOp `json:"ops"`
+	// Ops is the word-level edit script turning the source region into the query
+}
+
+// validateDiffFormat ensures the requested diff format is supported
+// An empty format disables diff output
+func validateDiffFormat(format string) error {
+	switch format {
+	case "", "text", "html", "json":
+		return nil
+	}
+	return fmt.Errorf("unknown diff format %q (expected text, html or json)", format)
+}
+
+// renderDiffText renders diff steps for a terminal
+// Removed words are shown as [-word-] and added words as {+word+};
+// with colour enabled they are shown in red and green instead
+func renderDiffText(ops []textsearch.DiffOp, color bool) string {
+	var sb strings.Builder
+	deleted := func(text string) {
+		if color {
+			sb.WriteString(ansiDeleted + text + ansiReset)
+		} else {
+			sb.WriteString("[-" + text + "-]")
+		}
+	}
+	inserted := func(text string) {
+		if color {
+			sb.WriteString(ansiInserted + text + ansiReset)
+		} else {
+			sb.WriteString("{+" + text + "+}")
+		}
+	}
+
+	for i, op := range ops {
+		if i > 0 {
+			sb.WriteString(" ")
+		}
+		switch op.Op {
+		case "equal":
+			sb.WriteString(op.Query)
+		case "delete":
+			deleted(op.Source)
+		case "insert":
+			inserted(op.Query)
+		case "replace":
+			deleted(op.Source)
+			inserted(op.Query)
+		}
+	}
+	return sb.String()
+}
+
+// printDiffReports writes diff reports to stdout in the requested format
+// Parameters:
+//
+//	reports: One report per matched chunk
+//	format: "text", "html" or "json"
+//
+// Returns:
+//
+//	error: nil on success, error if JSON encoding fails
+func printDiffReports(reports []DiffReport, format string) error {
+	switch format {
+	case "json":
+		data, err := json.MarshalIndent(reports, "", "  ")
+		if err != nil {
+			return fmt.Errorf("error encoding diff: %w", err)
+		}
+		fmt.Println(string(data))
+	case "html":
+		for _, report := range reports {
+			fmt.Printf("<!-- match at byte offset %d, source bytes %d-%d -->\n", report.Offset, report.Start, report.End)
+			fmt.Println(textsearch.RenderDiffHTML(report.Ops))
+		}
+	default:
+		color := isTerminal(os.Stdout)
+		for i, report := range reports {
+			if i > 0 {
+				fmt.Println("\n---")
+			}
+			fmt.Printf("Diff against bytes %d-%d (match at byte offset %d):\n", report.Start, report.End, report.Offset)
+			fmt.Println(renderDiffText(report.Ops, color))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"trufast/internal/textsearch"
+)
+
+func TestRenderDiffText(t *testing.T) {
+	ops := textsearch.DiffWords("arrive on <time>", "arrive in <time>")
+	if got, want := renderDiffText(ops, false), "arrive [-on-]{+in+} time"; got != want {
+		t.Errorf("renderDiffText() = %q, want %q", got, want)
+	}
+}
+
+func Test_lookupCommandDiff(t *testing.T) {
+	indexFile := "test_diff.idx"
+	defer os.Remove(indexFile)
+
+	if err := indexCommand("../../resources/original.txt", 4096, indexFile, IndexOptions{}); err != nil {
+		t.Fatalf("indexCommand failed: %v", err)
+	}
+	index, err := loadIndex(indexFile)
+	if err != nil {
+		t.Fatalf("loadIndex failed: %v", err)
+	}
+
+	query := "The meeting is scheduled for noon, and everyone was expected to arrive in time."
+	reports, err := diffMatches(index, index.Chunks, query)
+	if err != nil {
+		t.Fatalf("diffMatches failed: %v", err)
+	}
+	if len(reports) != 1 {
+		t.Fatalf("Expected 1 report, got %d", len(reports))
+	}
+	if got := renderDiffText(reports[0].Ops, false); !strings.Contains(got, "[-was-]{+is+}") || !strings.Contains(got, "[-on-]{+in+}") {
+		t.Errorf("Unexpected diff: %s", got)
+	}
+
+	for _, format := range []string{"text", "html", "json"} {
+		if err := lookupCommand(indexFile, index.Chunks[0].Hash, LookupOptions{QueryText: query, DiffFormat: format}); err != nil {
+			t.Errorf("lookupCommand(%s) failed: %v", format, err)
+		}
+	}
+	if err := lookupCommand(indexFile, index.Chunks[0].Hash, LookupOptions{DiffFormat: "text"}); err == nil {
+		t.Errorf("Expected error for diff without query text")
+	}
+	if err := lookupCommand(indexFile, index.Chunks[0].Hash, LookupOptions{QueryText: query, DiffFormat: "xml"}); err == nil {
+		t.Errorf("Expected error for unknown diff format")
+	}
+}
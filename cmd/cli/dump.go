@@ -0,0 +1,547 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"trufast/internal/textsearch"
+)
+
+// chunkRecordHeader is the header row of CSV chunk dumps, in column order
+var chunkRecordHeader = []string{"document", "offset", "size", "hash"}
+
+// dumpSchema identifies the metadata record at the start of a chunk dump
+// It changes only when a field is renamed or removed; new fields may be added
+const dumpSchema = "blitz.dump.v1"
+
+// DumpHeader is the metadata record written ahead of the chunk records
+// It carries the index settings that cannot be recovered from the chunks:
+// NDJSON dumps start with it as their first line, CSV dumps as a "#" line
+// holding the same JSON object before the header row
+type DumpHeader struct {
+	Schema string `json:"schema"`
+	// Schema is always dumpSchema
+
+	ChunkSize int `json:"chunk_size"`
+	// ChunkSize is the configured chunk size of the index in bytes
+
+	HashBits int `json:"hash_bits"`
+	// HashBits is the fingerprint width of the index
+
+	CommonThreshold float64 `json:"common_threshold,omitempty"`
+	// CommonThreshold is the index's boilerplate threshold in percent of documents
+
+	Excluded []string `json:"excluded,omitempty"`
+	// Excluded holds the registered exclusion fingerprints in hexadecimal
+
+	IDF map[string]float64 `json:"idf,omitempty"`
+	// IDF is the weighting table of a TF-IDF index; the chunk hashes were computed with it
+
+	Documents []DumpDocument `json:"documents,omitempty"`
+	// Documents lists the document fingerprints, used when a document cannot be read on import
+}
+
+// DumpDocument is the fingerprint of one indexed document in a dump's metadata record
+type DumpDocument struct {
+	Path string `json:"path"`
+	// Path is the path of the document as it appears in the chunk records
+
+	Hash string `json:"hash"`
+	// Hash is the document fingerprint in hexadecimal
+}
+
+// ChunkRecord is one chunk of an index as exported by dump and read by import
+type ChunkRecord struct {
+	Document string `json:"document"`
+	// Document is the path of the file the chunk belongs to
+
+	Offset int64 `json:"offset"`
+	// Offset is the byte offset of the chunk in its document
+
+	Size int `json:"size"`
+	// Size is the length of the chunk in bytes
+
+	Hash string `json:"hash"`
+	// Hash is the chunk fingerprint in hexadecimal, as printed by lookup
+}
+
+// dumpFormat resolves the format of a chunk dump
+// Without an explicit format it is taken from the file extension, defaulting to CSV
+// Parameters:
+//
+//	format: Value of the -format flag
+//	path: The dump file; empty for stdout
+//
+// Returns:
+//
+//	string: "csv" or "ndjson"
+//	error: nil on success, error if the format is not supported
+func dumpFormat(format string, path string) (string, error) {
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".ndjson", ".jsonl":
+			format = "ndjson"
+		default:
+			format = "csv"
+		}
+	}
+	if format != "csv" && format != "ndjson" {
+		return "", fmt.Errorf("unknown dump format %q (expected csv or ndjson)", format)
+	}
+	return format, nil
+}
+
+// dumpCommand handles the dump command
+// It exports every chunk record of an index as CSV or NDJSON
+// Parameters:
+//
+//	indexFile: Path to the previously generated index file
+//	format: "csv", "ndjson" or "" to use the extension of out
+//	out: Destination path; empty writes to stdout
+//
+// Returns:
+//
+//	error: nil on success, error if operation fails
+func dumpCommand(indexFile string, format string, out string) error {
+	// Validate parameters
+	if indexFile == "" {
+		return fmt.Errorf("error: index file is required")
+		// Ensures an index file path was provided via -i flag
+	}
+	format, err := dumpFormat(format, out)
+	if err != nil {
+		return err
+	}
+
+	// Load the index from file into memory
+	index, err := loadIndex(indexFile)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	var file *os.File
+	if out != "" {
+		file, err = os.Create(out)
+		if err != nil {
+			return fmt.Errorf("error creating dump file: %w", err)
+		}
+		w = file
+	}
+	err = writeChunkRecords(w, format, dumpHeader(index), chunkRecords(index))
+	if file != nil {
+		if closeErr := file.Close(); err == nil && closeErr != nil {
+			err = fmt.Errorf("error writing dump: %w", closeErr)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if out != "" {
+		fmt.Printf("Dumped %d chunk(s) to %s\n", len(index.Chunks), out)
+	}
+	return nil
+}
+
+// dumpHeader collects the index settings written ahead of the chunk records
+func dumpHeader(index *Index) DumpHeader {
+	header := DumpHeader{
+		Schema:          dumpSchema,
+		ChunkSize:       index.ChunkSize,
+		HashBits:        index.HashBits,
+		CommonThreshold: index.CommonThreshold,
+		IDF:             index.IDF,
+	}
+	if header.HashBits == 0 {
+		header.HashBits = defaultHashBits
+	}
+	for _, hash := range index.Excluded {
+		header.Excluded = append(header.Excluded, hash.String())
+	}
+	for _, document := range index.Documents {
+		header.Documents = append(header.Documents, DumpDocument{Path: document.Path, Hash: document.Hash.String()})
+	}
+	return header
+}
+
+// chunkRecords lists the chunks of an index in index order
+func chunkRecords(index *Index) []ChunkRecord {
+	records := make([]ChunkRecord, len(index.Chunks))
+	for i, chunk := range index.Chunks {
+		records[i] = ChunkRecord{
+			Document: chunkDocument(index, chunk).Path,
+			Offset:   chunk.Offset,
+			Size:     chunk.Size,
+			Hash:     chunk.Hash.String(),
+		}
+	}
+	return records
+}
+
+// writeChunkRecords writes a metadata record followed by chunk records as CSV
+// (with a header row) or NDJSON
+func writeChunkRecords(w io.Writer, format string, header DumpHeader, records []ChunkRecord) error {
+	metadata, err := json.Marshal(header)
+	if err != nil {
+		return fmt.Errorf("error writing dump: %w", err)
+	}
+	if format == "ndjson" {
+		buffered := bufio.NewWriter(w)
+		buffered.Write(append(metadata, '\n'))
+		encoder := json.NewEncoder(buffered)
+		for _, record := range records {
+			if err := encoder.Encode(record); err != nil {
+				return fmt.Errorf("error writing dump: %w", err)
+			}
+		}
+		if err := buffered.Flush(); err != nil {
+			return fmt.Errorf("error writing dump: %w", err)
+		}
+		return nil
+	}
+
+	if _, err := fmt.Fprintf(w, "#%s\n", metadata); err != nil {
+		return fmt.Errorf("error writing dump: %w", err)
+	}
+	writer := csv.NewWriter(w)
+	writer.Write(chunkRecordHeader)
+	for _, record := range records {
+		writer.Write([]string{record.Document, strconv.FormatInt(record.Offset, 10), strconv.Itoa(record.Size), record.Hash})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("error writing dump: %w", err)
+	}
+	return nil
+}
+
+// readChunkRecords parses a CSV or NDJSON chunk dump
+// CSV columns are matched by the names in the header row, so their order is free
+// The metadata record is optional, so dumps written by other tools can be read
+// Parameters:
+//
+//	r: Source of the dump
+//	format: "csv" or "ndjson"
+//
+// Returns:
+//
+//	*DumpHeader: The metadata record, nil if the dump has none
+//	[]ChunkRecord: The records in file order
+//	error: nil on success, error naming the offending line if parsing fails
+func readChunkRecords(r io.Reader, format string) (*DumpHeader, []ChunkRecord, error) {
+	var metadata *DumpHeader
+	var records []ChunkRecord
+	if format == "ndjson" {
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), 256*1024*1024)
+		for line := 1; scanner.Scan(); line++ {
+			if strings.TrimSpace(scanner.Text()) == "" {
+				continue
+			}
+			if metadata == nil && len(records) == 0 {
+				header, err := parseDumpHeader(scanner.Bytes())
+				if err != nil {
+					return nil, nil, fmt.Errorf("error parsing dump line %d: %w", line, err)
+				}
+				if header != nil {
+					metadata = header
+					continue
+				}
+			}
+			var record ChunkRecord
+			if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+				return nil, nil, fmt.Errorf("error parsing dump line %d: %w", line, err)
+			}
+			records = append(records, record)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, nil, fmt.Errorf("error reading dump: %w", err)
+		}
+		return metadata, records, nil
+	}
+
+	// The metadata record is a "#" line ahead of the header row
+	buffered := bufio.NewReader(r)
+	line := 1
+	if first, err := buffered.Peek(1); err == nil && first[0] == '#' {
+		text, err := buffered.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, nil, fmt.Errorf("error reading dump: %w", err)
+		}
+		if metadata, err = parseDumpHeader(bytes.TrimPrefix(text, []byte("#"))); err != nil {
+			return nil, nil, fmt.Errorf("error parsing dump line 1: %w", err)
+		}
+		if metadata == nil {
+			return nil, nil, fmt.Errorf("error parsing dump line 1: metadata record has no schema")
+		}
+		line++
+	}
+	reader := csv.NewReader(buffered)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading dump header: %w", err)
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range chunkRecordHeader {
+		if _, ok := columns[name]; !ok {
+			return nil, nil, fmt.Errorf("error: dump header has no %q column", name)
+		}
+	}
+	for line++; ; line++ {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("error reading dump: %w", err)
+		}
+		offset, err := strconv.ParseInt(row[columns["offset"]], 10, 64)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error parsing dump line %d: invalid offset %q", line, row[columns["offset"]])
+		}
+		size, err := strconv.Atoi(row[columns["size"]])
+		if err != nil {
+			return nil, nil, fmt.Errorf("error parsing dump line %d: invalid size %q", line, row[columns["size"]])
+		}
+		records = append(records, ChunkRecord{
+			Document: row[columns["document"]],
+			Offset:   offset,
+			Size:     size,
+			Hash:     row[columns["hash"]],
+		})
+	}
+	return metadata, records, nil
+}
+
+// parseDumpHeader parses a dump line that may be a metadata record
+// A JSON object without a "schema" field is a chunk record and yields nil
+func parseDumpHeader(line []byte) (*DumpHeader, error) {
+	var header DumpHeader
+	if err := json.Unmarshal(line, &header); err != nil {
+		return nil, err
+	}
+	if header.Schema == "" {
+		return nil, nil
+	}
+	if header.Schema != dumpSchema {
+		return nil, fmt.Errorf("unsupported dump schema %q (expected %s)", header.Schema, dumpSchema)
+	}
+	if header.ChunkSize < 0 {
+		return nil, fmt.Errorf("invalid chunk size %d", header.ChunkSize)
+	}
+	if header.HashBits != 0 {
+		if err := validateHashBits(header.HashBits); err != nil {
+			return nil, err
+		}
+	}
+	return &header, nil
+}
+
+// importCommand handles the import command
+// It builds an index from a chunk dump, so fingerprints computed elsewhere can be loaded
+// Parameters:
+//
+//	dumpFile: Path to a CSV or NDJSON chunk dump
+//	format: "csv", "ndjson" or "" to use the extension of dumpFile
+//	outputFile: Path where the index file will be saved
+//	hashBits: Smallest fingerprint width to record; wider hashes in the dump raise it
+//
+// Returns:
+//
+//	error: nil on success, error if operation fails
+func importCommand(dumpFile string, format string, outputFile string, hashBits int) error {
+	// Validate parameters
+	if dumpFile == "" {
+		return fmt.Errorf("error: dump file is required")
+		// Ensures a dump file path was provided via -i flag
+	}
+	if hashBits == 0 {
+		hashBits = defaultHashBits
+	}
+	if err := validateHashBits(hashBits); err != nil {
+		return err
+	}
+	format, err := dumpFormat(format, dumpFile)
+	if err != nil {
+		return err
+	}
+
+	// Set default output filename if not provided
+	if outputFile == "" {
+		outputFile = strings.TrimSuffix(filepath.Base(dumpFile), filepath.Ext(dumpFile)) + ".idx"
+	}
+
+	file, err := os.Open(dumpFile)
+	if err != nil {
+		return fmt.Errorf("%w. Check the file path and try again", err)
+	}
+	defer file.Close()
+	header, records, err := readChunkRecords(file, format)
+	if err != nil {
+		return err
+	}
+	if header == nil {
+		fmt.Println("Warning: the dump has no metadata record; the chunk size is taken from the largest chunk and no weights or exclusions are restored")
+	}
+
+	index, missing, err := importIndex(header, records, hashBits)
+	if err != nil {
+		return err
+	}
+	for _, path := range missing {
+		fmt.Printf("Warning: %s cannot be read; its chunks have no line numbers or keywords\n", path)
+	}
+
+	// Save the index to file
+	if err := saveIndex(index, outputFile); err != nil {
+		return err
+	}
+	fmt.Printf("Imported %d chunks of %d document(s), saved to %s\n", len(index.Chunks), len(index.Documents), outputFile)
+	return nil
+}
+
+// importIndex builds an index from chunk records
+// Documents are numbered in order of first appearance and chunks are sorted by
+// document, then offset. The fingerprints are taken as given; when a document can
+// be read, its size, line table and keyword postings are rebuilt from the text so
+// lookup positions, search and compare work as for an index built by the index command
+// The chunk size, weights, exclusions and document fingerprints come from the
+// metadata record; without one the chunk size is the largest record size
+// No digest or chunk checksums are recorded: nothing ties the text on disk to the
+// imported fingerprints, so verify reports imported documents as unverifiable
+// Parameters:
+//
+//	header: Metadata record of the dump; nil if it has none
+//	records: Chunk records read from a dump
+//	hashBits: Smallest fingerprint width to record
+//
+// Returns:
+//
+//	*Index: Pointer to the created Index structure
+//	[]string: Documents that could not be read
+//	error: nil on success, error if a record is invalid
+func importIndex(header *DumpHeader, records []ChunkRecord, hashBits int) (*Index, []string, error) {
+	if len(records) == 0 {
+		return nil, nil, fmt.Errorf("error: the dump holds no chunks")
+	}
+
+	index := &Index{
+		HashToChunks: make(map[Fingerprint][]int),
+		Postings:     make(map[string][]Posting),
+	}
+	documentHashes := make(map[string]Fingerprint)
+	if header != nil {
+		index.CommonThreshold = header.CommonThreshold
+		index.IDF = header.IDF
+		hashBits = max(hashBits, header.HashBits)
+		for _, hex := range header.Excluded {
+			hash, err := parseFingerprint(hex)
+			if err != nil {
+				return nil, nil, fmt.Errorf("error: exclusion fingerprint: %w", err)
+			}
+			index.Excluded = append(index.Excluded, hash)
+		}
+		for _, document := range header.Documents {
+			hash, err := parseFingerprint(document.Hash)
+			if err != nil {
+				return nil, nil, fmt.Errorf("error: fingerprint of %s: %w", document.Path, err)
+			}
+			documentHashes[document.Path] = hash
+		}
+	}
+	docs := make(map[string]int)
+	for i, record := range records {
+		if record.Document == "" || record.Offset < 0 || record.Size <= 0 {
+			return nil, nil, fmt.Errorf("error: invalid chunk record %d: %+v", i+1, record)
+		}
+		hash, err := parseFingerprint(record.Hash)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error: chunk record %d: %w", i+1, err)
+		}
+		doc, ok := docs[record.Document]
+		if !ok {
+			doc = len(index.Documents)
+			docs[record.Document] = doc
+			index.Documents = append(index.Documents, Document{Path: record.Document})
+		}
+		index.Chunks = append(index.Chunks, ChunkInfo{Offset: record.Offset, Size: record.Size, Hash: hash, Doc: doc})
+
+		// Widen the index to hold the widest fingerprint
+		for len(hash.String()) > hashBits/4 {
+			hashBits *= 2
+		}
+		index.ChunkSize = max(index.ChunkSize, record.Size)
+	}
+	if header != nil && header.ChunkSize > 0 {
+		index.ChunkSize = header.ChunkSize
+	}
+	index.FilePath = index.Documents[0].Path
+	index.HashBits = hashBits
+
+	sort.SliceStable(index.Chunks, func(a, b int) bool {
+		if index.Chunks[a].Doc != index.Chunks[b].Doc {
+			return index.Chunks[a].Doc < index.Chunks[b].Doc
+		}
+		return index.Chunks[a].Offset < index.Chunks[b].Offset
+	})
+	for chunkIdx, chunk := range index.Chunks {
+		if chunkIdx > 0 {
+			previous := index.Chunks[chunkIdx-1]
+			if previous.Doc == chunk.Doc && previous.Offset+int64(previous.Size) > chunk.Offset {
+				return nil, nil, fmt.Errorf("error: overlapping chunks at byte offset %d of %s", chunk.Offset, index.Documents[chunk.Doc].Path)
+			}
+		}
+		index.HashToChunks[chunk.Hash] = append(index.HashToChunks[chunk.Hash], chunkIdx)
+		document := &index.Documents[chunk.Doc]
+		document.Size = max(document.Size, chunk.Offset+int64(chunk.Size))
+	}
+
+	// Recover line tables and postings from the documents that can still be read
+	var missing []string
+	for doc := range index.Documents {
+		document := &index.Documents[doc]
+		data, err := os.ReadFile(document.Path)
+		if err != nil {
+			missing = append(missing, document.Path)
+			continue
+		}
+		document.LineOffsets = appendLineOffsets([]int64{0}, data, 0)
+		if info, err := os.Stat(document.Path); err == nil {
+			document.Size = info.Size()
+			document.ModTime = info.ModTime()
+		}
+		first, last := documentChunks(index, doc)
+		for chunkIdx := first; chunkIdx <= last; chunkIdx++ {
+			chunk := &index.Chunks[chunkIdx]
+			start, end := min(chunk.Offset, int64(len(data))), min(chunk.Offset+int64(chunk.Size), int64(len(data)))
+			freqs, terms := textsearch.TermFrequencies(string(data[start:end]))
+			chunk.Terms = terms
+			for term, freq := range freqs {
+				index.Postings[term] = append(index.Postings[term], Posting{Chunk: chunkIdx, Freq: freq})
+			}
+		}
+	}
+	index.Vocabulary = textsearch.BuildVocabulary(index.Postings)
+	buildHierarchy(index)
+
+	// Without its text a document keeps the fingerprint it had when dumped
+	for _, path := range missing {
+		doc := docs[path]
+		index.Documents[doc].Hash = documentHashes[path]
+	}
+	markSuppressed(index)
+	return index, missing, nil
+}
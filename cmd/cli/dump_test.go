@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestChunkRecordsRoundTrip(t *testing.T) {
+	index, err := createCorpusIndex([]string{"../../resources/original.txt", "../../resources/plagirized.txt"}, 64, defaultHashBits)
+	if err != nil {
+		t.Fatalf("createCorpusIndex failed: %v", err)
+	}
+	// Weights, exclusions and the boilerplate threshold travel in the metadata record
+	weightIndex(index)
+	index.Excluded = []Fingerprint{index.Chunks[1].Hash}
+	index.CommonThreshold = 50
+	markSuppressed(index)
+
+	for _, format := range []string{"csv", "ndjson"} {
+		var buf bytes.Buffer
+		if err := writeChunkRecords(&buf, format, dumpHeader(index), chunkRecords(index)); err != nil {
+			t.Fatalf("writeChunkRecords(%s) failed: %v", format, err)
+		}
+		header, records, err := readChunkRecords(&buf, format)
+		if err != nil || header == nil {
+			t.Fatalf("readChunkRecords(%s) failed: %v (header %v)", format, err, header)
+		}
+		imported, missing, err := importIndex(header, records, defaultHashBits)
+		if err != nil || len(missing) != 0 {
+			t.Fatalf("importIndex(%s) failed: %v (missing %v)", format, err, missing)
+		}
+
+		// The imported index matches the original chunk for chunk
+		if len(imported.Chunks) != len(index.Chunks) || len(imported.Documents) != 2 || imported.ChunkSize != 64 || imported.HashBits != 64 {
+			t.Fatalf("Unexpected imported index (%s): %d chunks, %d documents, size %d, %d bits", format, len(imported.Chunks), len(imported.Documents), imported.ChunkSize, imported.HashBits)
+		}
+		for i, chunk := range index.Chunks {
+			// Imported chunks carry no checksum, as their text is not vouched for
+			chunk.Checksum = 0
+			if imported.Chunks[i] != chunk {
+				t.Errorf("Chunk %d differs (%s): %+v, want %+v", i, format, imported.Chunks[i], chunk)
+			}
+		}
+		if len(imported.IDF) != len(index.IDF) || len(imported.Excluded) != 1 || imported.Excluded[0] != index.Excluded[0] || imported.CommonThreshold != 50 {
+			t.Errorf("Index settings were not restored (%s): %d weights, exclusions %v, threshold %v", format, len(imported.IDF), imported.Excluded, imported.CommonThreshold)
+		}
+		for doc := range index.Documents {
+			if imported.Documents[doc].Path != index.Documents[doc].Path || imported.Documents[doc].Size != index.Documents[doc].Size || imported.Documents[doc].Hash != index.Documents[doc].Hash || len(imported.Documents[doc].LineOffsets) != len(index.Documents[doc].LineOffsets) {
+				t.Errorf("Document %d differs (%s)", doc, format)
+			}
+			if !reflect.DeepEqual(imported.Documents[doc].Sections, index.Documents[doc].Sections) {
+				t.Errorf("Document %d sections differ (%s)", doc, format)
+			}
+		}
+		for _, check := range verifyIndex(imported) {
+			if check.Status != documentUnverifiable {
+				t.Errorf("Imported document %s is %s, want %s", check.Path, check.Status, documentUnverifiable)
+			}
+		}
+		if len(imported.Postings) != len(index.Postings) || len(imported.Vocabulary) != len(index.Vocabulary) {
+			t.Errorf("Keyword postings were not rebuilt (%s)", format)
+		}
+	}
+}
+
+func TestImportIndex(t *testing.T) {
+	// Wide hashes raise the fingerprint width; unreadable documents are reported
+	records := []ChunkRecord{
+		{Document: "missing.txt", Offset: 10, Size: 10, Hash: "1" + strings.Repeat("0", 20)},
+		{Document: "missing.txt", Offset: 0, Size: 10, Hash: "ff"},
+	}
+	index, missing, err := importIndex(nil, records, defaultHashBits)
+	if err != nil {
+		t.Fatalf("importIndex failed: %v", err)
+	}
+	if index.HashBits != 128 || index.ChunkSize != 10 || index.Chunks[0].Offset != 0 || index.Documents[0].Size != 20 || len(missing) != 1 {
+		t.Errorf("Unexpected index: %d bits, chunk size %d, chunks %+v, missing %v", index.HashBits, index.ChunkSize, index.Chunks, missing)
+	}
+	if chunks := index.HashToChunks[index.Chunks[1].Hash]; len(chunks) != 1 || chunks[0] != 1 {
+		t.Errorf("Hash map not rebuilt after sorting: %v", index.HashToChunks)
+	}
+
+	// The metadata record sets the chunk size and the fingerprint of an unreadable document
+	header := &DumpHeader{Schema: dumpSchema, ChunkSize: 4096, HashBits: 256, Documents: []DumpDocument{{Path: "missing.txt", Hash: "abc"}}}
+	index, _, err = importIndex(header, records, defaultHashBits)
+	if err != nil {
+		t.Fatalf("importIndex failed: %v", err)
+	}
+	if index.ChunkSize != 4096 || index.HashBits != 256 || index.Documents[0].Hash.String() != "abc" {
+		t.Errorf("Metadata not applied: chunk size %d, %d bits, document hash %s", index.ChunkSize, index.HashBits, index.Documents[0].Hash)
+	}
+
+	invalid := [][]ChunkRecord{
+		nil,
+		{{Document: "a.txt", Offset: 0, Size: 0, Hash: "ff"}},
+		{{Document: "a.txt", Offset: 0, Size: 10, Hash: "not hex"}},
+		{{Document: "a.txt", Offset: 0, Size: 10, Hash: "ff"}, {Document: "a.txt", Offset: 5, Size: 10, Hash: "ff"}},
+	}
+	for _, records := range invalid {
+		if _, _, err := importIndex(nil, records, defaultHashBits); err == nil {
+			t.Errorf("Expected an error for records %+v", records)
+		}
+	}
+
+	if _, _, err := readChunkRecords(strings.NewReader("path,offset\nx,1\n"), "csv"); err == nil {
+		t.Errorf("Expected an error for a header without the required columns")
+	}
+	if _, _, err := readChunkRecords(strings.NewReader(`{"schema":"blitz.dump.v9"}`+"\n"), "ndjson"); err == nil {
+		t.Errorf("Expected an error for an unknown dump schema")
+	}
+
+	// Dumps from other tools have no metadata record
+	header, records, err = readChunkRecords(strings.NewReader(`{"document":"a.txt","offset":0,"size":4,"hash":"ff"}`+"\n"), "ndjson")
+	if err != nil || header != nil || len(records) != 1 {
+		t.Errorf("Unexpected dump without metadata: header %v, records %v, err %v", header, records, err)
+	}
+}
+
+func Test_dumpAndImportCommand(t *testing.T) {
+	indexFile := "test_dump.idx"
+	dumpFile := "test_dump.ndjson"
+	importedFile := "test_imported.idx"
+	defer os.Remove(indexFile)
+	defer os.Remove(dumpFile)
+	defer os.Remove(importedFile)
+
+	if err := indexCommand("../../resources/original.txt", 64, indexFile, IndexOptions{}); err != nil {
+		t.Fatalf("indexCommand failed: %v", err)
+	}
+	if err := dumpCommand(indexFile, "", dumpFile); err != nil {
+		t.Fatalf("dumpCommand failed: %v", err)
+	}
+	if err := importCommand(dumpFile, "", importedFile, 0); err != nil {
+		t.Fatalf("importCommand failed: %v", err)
+	}
+	original, _ := loadIndex(indexFile)
+	imported, err := loadIndex(importedFile)
+	if err != nil || len(imported.Chunks) != len(original.Chunks) {
+		t.Fatalf("Unexpected imported index: %v", err)
+	}
+
+	if err := dumpCommand(indexFile, "xml", ""); err == nil {
+		t.Errorf("Expected an error for an unknown dump format")
+	}
+	if err := importCommand("", "", importedFile, 0); err == nil {
+		t.Errorf("Expected an error without a dump file")
+	}
+}
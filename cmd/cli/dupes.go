@@ -0,0 +1,280 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CloneMember is one occurrence of a duplicated passage
+type CloneMember struct {
+	Doc int
+	// Doc is the index of the document holding the occurrence in Index.Documents
+
+	Start int64
+	// Start is the byte offset where the occurrence begins in its document
+
+	End int64
+	// End is the byte offset just past the occurrence
+}
+
+// CloneGroup is a passage that occurs more than once in the indexed documents
+type CloneGroup struct {
+	Members []CloneMember
+	// Members lists every occurrence, ordered by document and position
+
+	Chunks int
+	// Chunks is the number of consecutive chunks each occurrence spans
+
+	Distance int
+	// Distance is the largest Hamming distance between the fingerprints of
+	// chunks grouped together; 0 means every occurrence is an exact copy
+}
+
+// DupesOptions groups the optional settings of the dupes command
+type DupesOptions struct {
+	Format string
+	// Format selects an exported report ("html", "markdown", "csv", "json"
+	// or "sarif") instead of the plain listing
+
+	Out string
+	// Out is the path the report is written to; empty writes to stdout
+
+	MinSize int64
+	// MinSize is the smallest passage, in bytes, that is reported; 0 reports all
+}
+
+// dupesCommand handles the dupes command
+// It lists passages that occur more than once across the indexed documents
+// Parameters:
+//
+//	indexFile: Path to the previously generated index file
+//	opts: Optional report format, destination and minimum clone size
+//
+// Returns:
+//
+//	error: nil on success, error if operation fails
+func dupesCommand(indexFile string, opts DupesOptions) error {
+	// Validate parameters
+	if indexFile == "" {
+		return fmt.Errorf("error: index file is required")
+		// Ensures an index file path was provided via -i flag
+	}
+	if opts.MinSize < 0 {
+		return fmt.Errorf("error: minimum clone size must not be negative")
+	}
+	// SARIF is only offered by dupes, so it is resolved before the shared report formats
+	sarif := opts.Format == "sarif" || (opts.Format == "" && strings.EqualFold(filepath.Ext(opts.Out), ".sarif"))
+	format := ""
+	if !sarif {
+		var err error
+		if format, err = reportFormat(opts.Format, opts.Out); err != nil {
+			return err
+		}
+	}
+
+	// Load the index from file into memory
+	index, err := loadIndex(indexFile)
+	if err != nil {
+		return err
+	}
+	groups := filterClones(findDuplicates(index), opts.MinSize)
+
+	if sarif {
+		return writeSARIF(dupesSARIF(index, groups), opts.Out)
+	}
+	if format != "" {
+		report, err := dupesReport(index, indexFile, groups)
+		if err != nil {
+			return err
+		}
+		return writeReport(report, format, opts.Out)
+	}
+
+	if len(groups) == 0 {
+		fmt.Println("No duplicated passages found.")
+		return nil
+	}
+	fmt.Printf("Found %d clone group(s) in %s (chunk size %d bytes)\n", len(groups), indexFile, index.ChunkSize)
+	for i, group := range groups {
+		fmt.Printf("\nClone group %d: %d occurrence(s) of %d bytes, max distance %d\n", i+1, len(group.Members), group.Members[0].End-group.Members[0].Start, group.Distance)
+		for _, member := range group.Members {
+			doc := indexDocument(index, member.Doc)
+			line, column := lineColumn(doc, member.Start)
+			lastLine, _ := lineColumn(doc, max(member.End-1, member.Start))
+			fmt.Printf("  %s:%d:%d-%d (bytes %d-%d)\n", doc.Path, line, column, lastLine, member.Start, member.End)
+		}
+	}
+
+	// Print summary
+	fmt.Println("\n---")
+	fmt.Printf("\nDuplicated %d of %d bytes in %d clone group(s).\n", duplicatedBytes(groups), indexedBytes(index), len(groups))
+	return nil
+}
+
+// findDuplicates groups the chunks of an index whose fingerprints are near each other
+// Fingerprints within the index's Hamming threshold are clustered (transitively),
+// and clusters whose occurrences all continue with the chunks of another cluster
+// are merged into one longer passage
+// Suppressed (boilerplate) chunks are never reported
+// The clustering compares every pair of distinct fingerprints, so it is quadratic
+// in the number of distinct fingerprints
+// Parameters:
+//
+//	index: Pointer to the loaded Index structure
+//
+// Returns:
+//
+//	[]CloneGroup: Groups ordered by passage length, longest first
+func findDuplicates(index *Index) []CloneGroup {
+	// Distinct fingerprints of unsuppressed chunks, in order of first occurrence
+	var hashes []Fingerprint
+	seen := make(map[Fingerprint]bool)
+	for _, chunk := range index.Chunks {
+		if !chunk.Suppressed && !seen[chunk.Hash] {
+			seen[chunk.Hash] = true
+			hashes = append(hashes, chunk.Hash)
+		}
+	}
+
+	// Cluster near fingerprints with union-find
+	parent := make([]int, len(hashes))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	threshold := hammingThreshold(index)
+	for i := range hashes {
+		for j := i + 1; j < len(hashes); j++ {
+			if HammingDistance(hashes[i], hashes[j]) <= threshold {
+				parent[find(j)] = find(i)
+			}
+		}
+	}
+	clusters := make(map[int][]int)
+	for i := range hashes {
+		clusters[find(i)] = append(clusters[find(i)], i)
+	}
+
+	// One candidate group per cluster with at least two occurrences
+	type candidate struct {
+		chunks   []int
+		distance int
+	}
+	var candidates []*candidate
+	byChunks := make(map[string]*candidate)
+	for _, members := range clusters {
+		var chunks []int
+		distance := 0
+		for a, i := range members {
+			for _, chunkIdx := range index.HashToChunks[hashes[i]] {
+				if !index.Chunks[chunkIdx].Suppressed {
+					chunks = append(chunks, chunkIdx)
+				}
+			}
+			for _, j := range members[a+1:] {
+				distance = max(distance, HammingDistance(hashes[i], hashes[j]))
+			}
+		}
+		if len(chunks) < 2 {
+			continue
+		}
+		sort.Ints(chunks)
+		c := &candidate{chunks: chunks, distance: distance}
+		candidates = append(candidates, c)
+		byChunks[chunkKey(chunks)] = c
+	}
+	sort.Slice(candidates, func(a, b int) bool { return candidates[a].chunks[0] < candidates[b].chunks[0] })
+
+	// Grow each group while every occurrence continues into the next group
+	merged := make(map[*candidate]bool)
+	var groups []CloneGroup
+	for _, c := range candidates {
+		if merged[c] {
+			continue
+		}
+		group := CloneGroup{Chunks: 1, Distance: c.distance}
+		last := c.chunks
+		for {
+			next := make([]int, len(last))
+			for i, chunkIdx := range last {
+				if chunkIdx+1 >= len(index.Chunks) || index.Chunks[chunkIdx+1].Doc != index.Chunks[chunkIdx].Doc {
+					next = nil
+					break
+				}
+				next[i] = chunkIdx + 1
+			}
+			following, ok := byChunks[chunkKey(next)]
+			if next == nil || !ok || following == c || merged[following] {
+				break
+			}
+			merged[following] = true
+			group.Chunks++
+			group.Distance = max(group.Distance, following.distance)
+			last = next
+		}
+
+		for i, chunkIdx := range c.chunks {
+			first, final := index.Chunks[chunkIdx], index.Chunks[last[i]]
+			group.Members = append(group.Members, CloneMember{
+				Doc:   first.Doc,
+				Start: first.Offset,
+				End:   final.Offset + int64(final.Size),
+			})
+		}
+		groups = append(groups, group)
+	}
+
+	sort.SliceStable(groups, func(a, b int) bool {
+		return groups[a].Members[0].End-groups[a].Members[0].Start > groups[b].Members[0].End-groups[b].Members[0].Start
+	})
+	return groups
+}
+
+// filterClones drops the groups whose passages are shorter than minSize bytes
+func filterClones(groups []CloneGroup, minSize int64) []CloneGroup {
+	var kept []CloneGroup
+	for _, group := range groups {
+		if group.Members[0].End-group.Members[0].Start >= minSize {
+			kept = append(kept, group)
+		}
+	}
+	return kept
+}
+
+// chunkKey identifies a sorted set of chunk indices in a map
+func chunkKey(chunks []int) string {
+	parts := make([]string, len(chunks))
+	for i, chunkIdx := range chunks {
+		parts[i] = strconv.Itoa(chunkIdx)
+	}
+	return strings.Join(parts, ",")
+}
+
+// duplicatedBytes counts the bytes of every occurrence after the first of each group
+func duplicatedBytes(groups []CloneGroup) int64 {
+	var total int64
+	for _, group := range groups {
+		for _, member := range group.Members[1:] {
+			total += member.End - member.Start
+		}
+	}
+	return total
+}
+
+// indexedBytes is the total size of the indexed documents
+func indexedBytes(index *Index) int64 {
+	var total int64
+	for _, chunk := range index.Chunks {
+		total += int64(chunk.Size)
+	}
+	return total
+}
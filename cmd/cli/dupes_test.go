@@ -0,0 +1,92 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestFindDuplicates(t *testing.T) {
+	index, err := createCorpusIndex([]string{"../../resources/original.txt", "../../resources/plagirized.txt", "../../resources/t.txt"}, 64, defaultHashBits)
+	if err != nil {
+		t.Fatalf("createCorpusIndex failed: %v", err)
+	}
+
+	// The first two chunks are copied verbatim and merge into one passage
+	groups := findDuplicates(index)
+	var copied *CloneGroup
+	for i, group := range groups {
+		docs := make(map[int]bool)
+		for _, member := range group.Members {
+			docs[member.Doc] = true
+		}
+		if docs[2] && (docs[0] || docs[1]) {
+			t.Errorf("Unrelated document grouped with the copies: %+v", group)
+		}
+		if docs[0] && docs[1] && copied == nil {
+			copied = &groups[i]
+		}
+	}
+	if copied == nil {
+		t.Fatalf("Expected the copied document to be reported, got %+v", groups)
+	}
+	if len(copied.Members) != 2 || copied.Chunks != 2 || copied.Distance != 0 || copied.Members[0].Start != 0 || copied.Members[1].Start != 0 || copied.Members[0].End != 128 {
+		t.Errorf("Unexpected group for the copied passage %+v", copied)
+	}
+
+	// Suppressed chunks are left out
+	for i := range index.Chunks {
+		index.Chunks[i].Suppressed = true
+	}
+	if groups := findDuplicates(index); len(groups) != 0 {
+		t.Errorf("Expected no groups for suppressed chunks, got %+v", groups)
+	}
+}
+
+func TestFindDuplicatesWithinDocument(t *testing.T) {
+	index, err := createIndex("../../resources/code_dup.txt", 64)
+	if err != nil {
+		t.Fatalf("createIndex failed: %v", err)
+	}
+	data, _ := os.ReadFile("../../resources/code_dup.txt")
+
+	groups := findDuplicates(index)
+	if len(groups) == 0 {
+		t.Fatalf("Expected repeated passages in code_dup.txt")
+	}
+	for _, group := range groups {
+		if len(group.Members) < 2 {
+			t.Errorf("Group with a single occurrence: %+v", group)
+		}
+		for _, member := range group.Members[1:] {
+			if member.Start <= group.Members[0].Start || member.End > int64(len(data)) {
+				t.Errorf("Occurrences out of order or out of range: %+v", group.Members)
+			}
+		}
+	}
+}
+
+func Test_dupesCommand(t *testing.T) {
+	indexFile := "test_dupes.idx"
+	defer os.Remove(indexFile)
+	if err := indexCommand("../../resources/original.txt,../../resources/plagirized.txt", 64, indexFile, IndexOptions{}); err != nil {
+		t.Fatalf("indexCommand failed: %v", err)
+	}
+
+	if err := dupesCommand(indexFile, DupesOptions{}); err != nil {
+		t.Errorf("dupesCommand failed: %v", err)
+	}
+	if err := dupesCommand("", DupesOptions{}); err == nil {
+		t.Errorf("Expected an error without an index")
+	}
+
+	out := "test_dupes.md"
+	defer os.Remove(out)
+	if err := dupesCommand(indexFile, DupesOptions{Out: out}); err != nil {
+		t.Fatalf("dupesCommand failed: %v", err)
+	}
+	report, _ := os.ReadFile(out)
+	if !strings.HasPrefix(string(report), "# Duplicate passages report") || !strings.Contains(string(report), "plagirized.txt") {
+		t.Errorf("Unexpected Markdown report:\n%s", report)
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"math/bits"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultHashBits = 64
+	// defaultHashBits is the fingerprint width used when none is chosen
+	// 64-bit fingerprints are identical to those of simhash.Simhash
+
+	maxHashWords = 4
+	// maxHashWords is the number of 64-bit words in the widest (256-bit) fingerprint
+)
+
+// featurePattern matches the words simhash.NewWordFeatureSet uses as features
+// Kept identical so 64-bit fingerprints do not change with the wider implementation
+var featurePattern = regexp.MustCompile(`[\w']+(?:\://[\w\./]+){0,1}`)
+
+// Fingerprint is a SimHash value of 64, 128 or 256 bits
+// Word 0 holds the lowest 64 bits; words beyond the index's width are always zero,
+// so fingerprints of any width can be compared and used as map keys
+type Fingerprint [maxHashWords]uint64
+
+// String formats the fingerprint as hexadecimal without leading zeros
+// A 64-bit fingerprint prints exactly like the uint64 hashes of earlier versions
+func (f Fingerprint) String() string {
+	top := 0
+	for i := maxHashWords - 1; i > 0; i-- {
+		if f[i] != 0 {
+			top = i
+			break
+		}
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%x", f[top])
+	for i := top - 1; i >= 0; i-- {
+		fmt.Fprintf(&sb, "%016x", f[i])
+	}
+	return sb.String()
+}
+
+// parseFingerprint parses a hexadecimal fingerprint as printed by String
+// Parameters:
+//
+//	s: Up to 64 hexadecimal digits
+//
+// Returns:
+//
+//	Fingerprint: The parsed value
+//	error: nil on success, error if s is empty, too long or not hexadecimal
+func parseFingerprint(s string) (Fingerprint, error) {
+	var f Fingerprint
+	s = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(s)), "0x")
+	if s == "" || len(s) > 16*maxHashWords {
+		return f, fmt.Errorf("invalid fingerprint %q", s)
+	}
+	// Split into 16-digit words from the right
+	for i := 0; len(s) > 0; i++ {
+		cut := max(len(s)-16, 0)
+		word, err := strconv.ParseUint(s[cut:], 16, 64)
+		if err != nil {
+			return f, fmt.Errorf("invalid fingerprint %q", s)
+		}
+		f[i] = word
+		s = s[:cut]
+	}
+	return f, nil
+}
+
+// validateHashBits ensures the fingerprint width is one of the supported sizes
+func validateHashBits(width int) error {
+	switch width {
+	case 64, 128, 256:
+		return nil
+	}
+	return fmt.Errorf("invalid fingerprint width: %d. Use 64, 128 or 256 bits", width)
+}
+
+// indexHashBits returns the fingerprint width of an index
+// Indexes written before the width was recorded stored uint64 hashes; loadIndex
+// converts them (see legacyIndex) and leaves HashBits 0, which means 64 bits
+func indexHashBits(index *Index) int {
+	if index.HashBits == 0 {
+		return defaultHashBits
+	}
+	return index.HashBits
+}
+
+// hammingThreshold scales maxHammingDistance to the index's fingerprint width
+// The same share of differing bits counts as a near match at every width
+func hammingThreshold(index *Index) int {
+	return maxHammingDistance * indexHashBits(index) / 64
+}
+
+// HammingDistance calculates the bit-level distance between two fingerprints
+// It computes the number of differing bits between two values of any width
+// Parameters:
+//
+//	a: First fingerprint
+//	b: Second fingerprint
+//
+// Returns:
+//
+//	int: Number of bits that differ between a and b (0 to 256)
+func HammingDistance(a, b Fingerprint) int {
+	// XOR the two hashes to identify differing bits
+	// (0 where bits match, 1 where they differ)
+	// Then count the number of 1s in the result
+	distance := 0
+	for i := range a {
+		distance += bits.OnesCount64(a[i] ^ b[i])
+	}
+	return distance
+}
+
+// feature is a weighted word contributing to a fingerprint
+type feature struct {
+	term   []byte
+	weight int
+}
+
+// textFeatures splits text into equally weighted word features
+// Words are lowercased and matched the same way as simhash.NewWordFeatureSet
+func textFeatures(text []byte) []feature {
+	words := featurePattern.FindAll(bytes.ToLower(text), -1)
+	features := make([]feature, len(words))
+	for i, word := range words {
+		features[i] = feature{term: word, weight: 1}
+	}
+	return features
+}
+
+// simhashFeatures computes a SimHash fingerprint of the given width
+// Each word of the fingerprint is a 64-bit SimHash computed with its own hash of
+// every feature: word 0 uses FNV-1 of the feature as simhash does, and word k
+// uses FNV-1 of the feature followed by the byte k
+// Parameters:
+//
+//	features: Weighted features of the text
+//	width: Fingerprint width in bits (64, 128 or 256)
+//
+// Returns:
+//
+//	Fingerprint: The fingerprint; words beyond the width are zero
+func simhashFeatures(features []feature, width int) Fingerprint {
+	var f Fingerprint
+	hasher := fnv.New64()
+	for word := 0; word < width/64; word++ {
+		var vector [64]int
+		for _, feat := range features {
+			hasher.Reset()
+			hasher.Write(feat.term)
+			if word > 0 {
+				hasher.Write([]byte{byte(word)})
+			}
+			sum := hasher.Sum64()
+			for bit := 0; bit < 64; bit++ {
+				if sum>>bit&1 == 1 {
+					vector[bit] += feat.weight
+				} else {
+					vector[bit] -= feat.weight
+				}
+			}
+		}
+		for bit := 0; bit < 64; bit++ {
+			if vector[bit] >= 0 {
+				f[word] |= 1 << bit
+			}
+		}
+	}
+	return f
+}
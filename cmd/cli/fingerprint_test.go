@@ -0,0 +1,122 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/mfonda/simhash"
+)
+
+func TestSimhashFeatures(t *testing.T) {
+	texts := []string{
+		"The meeting was scheduled for noon.",
+		"See http://example.com/page for details, it's there",
+		"",
+	}
+	for _, text := range texts {
+		// 64-bit fingerprints match the simhash package exactly
+		want := simhash.Simhash(simhash.NewWordFeatureSet([]byte(text)))
+		if got := simhashFeatures(textFeatures([]byte(text)), 64); got != (Fingerprint{want}) {
+			t.Errorf("simhashFeatures(%q, 64) = %s, want %x", text, got, want)
+		}
+
+		// Wider fingerprints extend the 64-bit value
+		wide := simhashFeatures(textFeatures([]byte(text)), 256)
+		if wide[0] != want {
+			t.Errorf("simhashFeatures(%q, 256) low word = %x, want %x", text, wide[0], want)
+		}
+		if narrow := simhashFeatures(textFeatures([]byte(text)), 128); narrow[2] != 0 || narrow[3] != 0 || narrow[1] != wide[1] {
+			t.Errorf("simhashFeatures(%q, 128) = %s, want the low half of %s", text, narrow, wide)
+		}
+	}
+}
+
+func TestParseFingerprint(t *testing.T) {
+	tests := []struct {
+		text string
+		want Fingerprint
+	}{
+		{"1a2b3c", Fingerprint{0x1a2b3c}},
+		{"0x1A2B3C", Fingerprint{0x1a2b3c}},
+		{"1" + "0000000000000002", Fingerprint{2, 1}},
+		{"4" + "0000000000000003" + "0000000000000002" + "0000000000000001", Fingerprint{1, 2, 3, 4}},
+	}
+	for _, tt := range tests {
+		got, err := parseFingerprint(tt.text)
+		if err != nil || got != tt.want {
+			t.Errorf("parseFingerprint(%q) = %v, %v; want %v", tt.text, got, err, tt.want)
+		}
+		if again, _ := parseFingerprint(got.String()); again != got {
+			t.Errorf("String() of %v does not round-trip: %q", got, got.String())
+		}
+	}
+
+	for _, bad := range []string{"", "xyz", strings.Repeat("f", 65)} {
+		if _, err := parseFingerprint(bad); err == nil {
+			t.Errorf("parseFingerprint(%q) expected error", bad)
+		}
+	}
+}
+
+func TestWideIndex(t *testing.T) {
+	for _, width := range []int{128, 256} {
+		index, err := createIndexBits("../../resources/original.txt", 64, width)
+		if err != nil {
+			t.Fatalf("createIndexBits failed: %v", err)
+		}
+		if indexHashBits(index) != width || hammingThreshold(index) != maxHammingDistance*width/64 {
+			t.Errorf("Unexpected width %d or threshold %d", indexHashBits(index), hammingThreshold(index))
+		}
+
+		// Every chunk is found by its own text at full width
+		content, _ := os.ReadFile("../../resources/original.txt")
+		for _, chunk := range index.Chunks {
+			text := string(content[chunk.Offset : chunk.Offset+int64(chunk.Size)])
+			hash := queryFingerprint(index, text)
+			if hash != chunk.Hash {
+				t.Errorf("%d bits: query fingerprint %s differs from indexed %s", width, hash, chunk.Hash)
+			}
+			if width == 128 && (hash[1] == 0 || hash[2] != 0) {
+				t.Errorf("128 bits: unexpected words in %s", hash)
+			}
+		}
+	}
+
+	if err := indexCommand("../../resources/original.txt", 64, "test_wide.idx", IndexOptions{HashBits: 100}); err == nil {
+		t.Errorf("Expected error for unsupported width")
+	}
+}
+
+func Test_indexCommandWide(t *testing.T) {
+	indexFile := "test_wide.idx"
+	defer os.Remove(indexFile)
+
+	if err := indexCommand("../../resources/original.txt", 64, indexFile, IndexOptions{HashBits: 128}); err != nil {
+		t.Fatalf("indexCommand failed: %v", err)
+	}
+	index, err := loadIndex(indexFile)
+	if err != nil {
+		t.Fatalf("loadIndex failed: %v", err)
+	}
+	if index.HashBits != 128 {
+		t.Errorf("Expected width recorded in index, got %d", index.HashBits)
+	}
+
+	// Lookups accept the printed 128-bit value
+	hash, err := parseFingerprint(index.Chunks[0].Hash.String())
+	if err != nil {
+		t.Fatalf("parseFingerprint failed: %v", err)
+	}
+	if err := lookupCommand(indexFile, hash, LookupOptions{}); err != nil {
+		t.Errorf("lookupCommand failed: %v", err)
+	}
+
+	comparison, err := compareDocument(index, "../../resources/plagirized.txt", false)
+	if err != nil {
+		t.Fatalf("compareDocument failed: %v", err)
+	}
+	if len(comparison.Regions) == 0 {
+		t.Errorf("Expected the plagiarised copy to be found with 128-bit fingerprints")
+	}
+}
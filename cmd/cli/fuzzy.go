@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"trufast/internal/textsearch"
+)
+
+// FuzzyResult is a chunk containing at least one fuzzy term match
+type FuzzyResult struct {
+	Chunk ChunkInfo
+	// Chunk is the metadata of the matching chunk
+
+	Score float64
+	// Score rewards chunks matching more query terms with smaller edit distances
+
+	Terms []string
+	// Terms lists the vocabulary terms found in this chunk, sorted alphabetically
+}
+
+// fuzzyCommand handles the fuzzy command
+// It finds chunks containing words within a small edit distance of the query words
+// Parameters:
+//
+//	indexFile: Path to the previously generated index file
+//	query: Free-text query, possibly misspelled
+//	maxDistance: Maximum edit distance per word (1 or 2)
+//	limit: Maximum number of chunks to display
+//
+// Returns:
+//
+//	error: nil on success, error if operation fails
+func fuzzyCommand(indexFile string, query string, maxDistance int, limit int) error {
+	// Validate parameters
+	if indexFile == "" {
+		return fmt.Errorf("error: index file is required")
+	}
+	if strings.TrimSpace(query) == "" {
+		return fmt.Errorf("error: query text is required")
+	}
+	if maxDistance < 1 || maxDistance > textsearch.MaxEditDistance {
+		return fmt.Errorf("invalid edit distance: %d. Use 1 or 2", maxDistance)
+	}
+	if limit <= 0 {
+		return fmt.Errorf("invalid result limit: %d", limit)
+	}
+
+	// Load the index from file into memory
+	index, err := loadIndex(indexFile)
+	if err != nil {
+		return err
+	}
+
+	results, matches := fuzzySearch(index, query, maxDistance)
+
+	// Report how each query word was interpreted
+	for _, term := range textsearch.UniqueTerms(query) {
+		var found []string
+		for _, match := range matches[term] {
+			found = append(found, fmt.Sprintf("%s (%d)", match.Term, match.Distance))
+		}
+		if len(found) == 0 {
+			found = []string{"no match"}
+		}
+		fmt.Printf("%s => %s\n", term, strings.Join(found, ", "))
+	}
+
+	if len(results) == 0 {
+		fmt.Println("No matches found for query.")
+		return fmt.Errorf("no indexed word is within edit distance %d of the query", maxDistance)
+	}
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	// Display ranked chunks
+	for i, result := range results {
+		content, err := getChunkContent(chunkDocument(index, result.Chunk).Path, result.Chunk.Offset, result.Chunk.Size)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println("\n---")
+		fmt.Printf("#%d score: %.4f, byte offset: %d%s, terms: %s\n", i+1, result.Score, result.Chunk.Offset, documentLabel(index, result.Chunk), strings.Join(result.Terms, ", "))
+		fmt.Println("Chunk content:")
+		fmt.Println(content)
+	}
+
+	// Print summary
+	fmt.Println("\n---")
+	fmt.Printf("\nShowing %d chunk(s) with fuzzy matches.\n", len(results))
+	return nil
+}
+
+// fuzzySearch maps each query word to nearby vocabulary terms and ranks the chunks containing them
+// Parameters:
+//
+//	index: Pointer to the loaded Index structure
+//	query: Free-text query
+//	maxDistance: Maximum edit distance per word
+//
+// Returns:
+//
+//	[]FuzzyResult: Matching chunks ordered by descending score
+//	map[string][]textsearch.TermMatch: Vocabulary matches for each distinct query word
+func fuzzySearch(index *Index, query string, maxDistance int) ([]FuzzyResult, map[string][]textsearch.TermMatch) {
+	suppressed := func(chunk int) bool { return index.Chunks[chunk].Suppressed }
+	matches := textsearch.Fuzzy(termTree(index), index.Postings, query, maxDistance, suppressed)
+
+	results := make([]FuzzyResult, 0, len(matches.Scores))
+	for chunkIdx, score := range matches.Scores {
+		results = append(results, FuzzyResult{Chunk: index.Chunks[chunkIdx], Score: score, Terms: matches.Terms[chunkIdx]})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		if results[i].Chunk.Doc != results[j].Chunk.Doc {
+			return results[i].Chunk.Doc < results[j].Chunk.Doc
+		}
+		return results[i].Chunk.Offset < results[j].Chunk.Offset
+	})
+	return results, matches.Matches
+}
+
+// termTree returns the BK-tree over the index's vocabulary
+// The tree is built on the first fuzzy query and kept on the index, so later
+// queries reuse it and commands that never search by term do not pay for it
+func termTree(index *Index) *textsearch.BKTree {
+	if index.terms == nil {
+		index.terms = textsearch.NewBKTree(indexVocabulary(index))
+	}
+	return index.terms
+}
+
+// indexVocabulary returns the sorted vocabulary of an index
+// Indexes created before the vocabulary was stored fall back to the posting list keys
+func indexVocabulary(index *Index) []string {
+	if len(index.Vocabulary) > 0 {
+		return index.Vocabulary
+	}
+	return textsearch.BuildVocabulary(index.Postings)
+}
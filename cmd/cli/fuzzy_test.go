@@ -0,0 +1,70 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"trufast/internal/textsearch"
+)
+
+func TestFuzzySearch(t *testing.T) {
+	file := "test_fuzzy.txt"
+	content := "mowgli ran with the wolves " + "baloo taught the law"
+	os.WriteFile(file, []byte(content), 0644)
+	defer os.Remove(file)
+
+	index, err := createIndex(file, 27)
+	if err != nil {
+		t.Fatalf("createIndex failed: %v", err)
+	}
+
+	results, matches := fuzzySearch(index, "mowgly balu", 2)
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 chunks, got %d", len(results))
+	}
+	if !reflect.DeepEqual(matches["mowgly"], []textsearch.TermMatch{{Term: "mowgli", Distance: 1}}) {
+		t.Errorf("Unexpected matches for mowgly: %v", matches["mowgly"])
+	}
+	if results[0].Chunk.Offset != 0 || !reflect.DeepEqual(results[0].Terms, []string{"mowgli"}) {
+		t.Errorf("Expected closer match first, got %+v", results[0])
+	}
+
+	// The vocabulary tree is built once and reused by later queries
+	tree := index.terms
+	fuzzySearch(index, "wolfs", 1)
+	if tree == nil || index.terms != tree {
+		t.Errorf("Expected the BK-tree to be kept on the index")
+	}
+}
+
+func Test_fuzzyCommand(t *testing.T) {
+	file := "test_fuzzy_cmd.txt"
+	os.WriteFile(file, []byte("the jungle book"), 0644)
+	defer os.Remove(file)
+	indexFile := "test_fuzzy_cmd.idx"
+	defer os.Remove(indexFile)
+
+	if err := indexCommand(file, 64, indexFile, IndexOptions{}); err != nil {
+		t.Fatalf("indexCommand failed: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		query    string
+		distance int
+		wantErr  bool
+	}{
+		{"misspelled word", "jungel", 2, false},
+		{"too far", "jungel", 1, true},
+		{"distance out of range", "jungle", 3, true},
+		{"empty query", "", 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := fuzzyCommand(indexFile, tt.query, tt.distance, 5); (err != nil) != tt.wantErr {
+				t.Errorf("fuzzyCommand() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"math/bits"
+	"sort"
+)
+
+// sectionChunks is the number of chunks after which a section ends at the
+// next paragraph break; sections never grow beyond twice this size
+const sectionChunks = 8
+
+// DocumentMatch is an indexed document whose whole-document fingerprint is
+// close to that of a query document
+type DocumentMatch struct {
+	Doc int
+	// Doc is the index of the document in Index.Documents
+
+	Distance int
+	// Distance is the Hamming distance between the two document fingerprints
+}
+
+// buildHierarchy computes the document fingerprints and sections of an index
+// Document fingerprints are built from the term counts in the posting lists,
+// weighted with the index's IDF table when it has one, and section bounds from
+// the chunk fingerprints, so both must be rebuilt whenever the chunks or weights change
+// Parameters:
+//
+//	index: Pointer to the Index structure to update in place
+func buildHierarchy(index *Index) {
+	// Term counts of each chunk, recovered from the posting lists
+	chunkTerms := make([]map[string]int, len(index.Chunks))
+	for term, postings := range index.Postings {
+		for _, posting := range postings {
+			if chunkTerms[posting.Chunk] == nil {
+				chunkTerms[posting.Chunk] = make(map[string]int)
+			}
+			chunkTerms[posting.Chunk][term] = posting.Freq
+		}
+	}
+	merge := func(first, last int) map[string]int {
+		freqs := make(map[string]int)
+		for chunkIdx := first; chunkIdx <= last; chunkIdx++ {
+			for term, freq := range chunkTerms[chunkIdx] {
+				freqs[term] += freq
+			}
+		}
+		return freqs
+	}
+
+	for doc := range index.Documents {
+		document := &index.Documents[doc]
+		document.Sections = nil
+		first, last := documentChunks(index, doc)
+		if first > last {
+			document.Hash = Fingerprint{}
+			continue
+		}
+		document.Hash = termsFingerprint(index, merge(first, last))
+
+		// Close a section at a paragraph break once it is long enough
+		start := first
+		for chunkIdx := first; chunkIdx <= last; chunkIdx++ {
+			length := chunkIdx - start + 1
+			if chunkIdx < last && length < 2*sectionChunks && (length < sectionChunks || !containsParagraphBreak(document, index.Chunks[chunkIdx])) {
+				continue
+			}
+			section := Section{FirstChunk: start, LastChunk: chunkIdx, Intersection: index.Chunks[start].Hash}
+			for _, chunk := range index.Chunks[start : chunkIdx+1] {
+				for i := range chunk.Hash {
+					section.Union[i] |= chunk.Hash[i]
+					section.Intersection[i] &= chunk.Hash[i]
+				}
+			}
+			document.Sections = append(document.Sections, section)
+			start = chunkIdx + 1
+		}
+	}
+}
+
+// documentChunks returns the range of chunk indices belonging to a document
+// Chunks are ordered by document, so the range is contiguous; first > last
+// when the document has no chunks
+func documentChunks(index *Index, doc int) (int, int) {
+	first := sort.Search(len(index.Chunks), func(i int) bool { return index.Chunks[i].Doc >= doc })
+	last := sort.Search(len(index.Chunks), func(i int) bool { return index.Chunks[i].Doc > doc }) - 1
+	return first, last
+}
+
+// containsParagraphBreak reports whether a blank line starts inside the chunk
+func containsParagraphBreak(doc *Document, chunk ChunkInfo) bool {
+	end := chunk.Offset + int64(chunk.Size)
+	line := sort.Search(len(doc.LineOffsets), func(i int) bool { return doc.LineOffsets[i] >= chunk.Offset })
+	for ; line+1 < len(doc.LineOffsets) && doc.LineOffsets[line] < end; line++ {
+		// A line holding only its newline is blank
+		if doc.LineOffsets[line+1]-doc.LineOffsets[line] == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// termsFingerprint computes a fingerprint from term counts
+// Each term is one feature weighted by its count, times its IDF weight for
+// TF-IDF indexes; documents and sections of queries use the same function
+func termsFingerprint(index *Index, freqs map[string]int) Fingerprint {
+	features := make([]feature, 0, len(freqs))
+	unknown := maxIDF(index.IDF)
+	for term, freq := range freqs {
+		weight := freq
+		if index.IDF != nil {
+			weight *= termWeight(index.IDF, term, unknown)
+		}
+		features = append(features, feature{term: []byte(term), weight: weight})
+	}
+	return simhashFeatures(features, indexHashBits(index))
+}
+
+// sectionBound returns a lower bound on the Hamming distance between a
+// fingerprint and every chunk of a section
+// A bit set in the fingerprint but in no chunk, or clear in the fingerprint but
+// set in every chunk, differs from each chunk of the section
+// Sections of older indexes carry no bounds and always return 0
+func sectionBound(section Section, hash Fingerprint) int {
+	if section.Union == (Fingerprint{}) {
+		return 0
+	}
+	distance := 0
+	for i := range hash {
+		distance += bits.OnesCount64(hash[i]&^section.Union[i] | section.Intersection[i]&^hash[i])
+	}
+	return distance
+}
+
+// candidateChunks is the coarse stage of lookup and compare
+// It returns the chunks of every section that can contain a chunk within the
+// threshold of a fingerprint; a section is only skipped when its bound proves
+// that none of its chunks is close enough, so no match is ever lost
+// Parameters:
+//
+//	index: Pointer to the loaded Index structure
+//	hash: Fingerprint of the query text or query chunk
+//	threshold: Largest Hamming distance that counts as a match
+//
+// Returns:
+//
+//	[]int: Candidate chunk indices in index order
+//	bool: false when the index predates sections and every chunk must be searched
+func candidateChunks(index *Index, hash Fingerprint, threshold int) ([]int, bool) {
+	if len(index.Documents) == 0 {
+		return nil, false
+	}
+	var chunks []int
+	for doc, document := range index.Documents {
+		if len(document.Sections) == 0 {
+			if first, last := documentChunks(index, doc); first <= last {
+				return nil, false
+				// Index created before hierarchical fingerprints were stored
+			}
+			continue
+		}
+		for _, section := range document.Sections {
+			if sectionBound(section, hash) > threshold {
+				continue
+			}
+			for chunkIdx := section.FirstChunk; chunkIdx <= section.LastChunk; chunkIdx++ {
+				chunks = append(chunks, chunkIdx)
+			}
+		}
+	}
+	return chunks, true
+}
+
+// nearDuplicates finds the documents whose whole-document fingerprint is within
+// the Hamming threshold of the query document's
+// Parameters:
+//
+//	index: Pointer to the loaded Index structure
+//	query: In-memory single-document index of the query, built with buildHierarchy
+//
+// Returns:
+//
+//	[]DocumentMatch: Near-duplicate documents ordered by distance; nil when the
+//	                 index predates document fingerprints
+func nearDuplicates(index *Index, query *Index) []DocumentMatch {
+	if len(query.Documents) == 0 || len(query.Documents[0].Sections) == 0 {
+		return nil
+	}
+	queryHash := query.Documents[0].Hash
+	threshold := hammingThreshold(index)
+
+	var duplicates []DocumentMatch
+	for doc, document := range index.Documents {
+		if len(document.Sections) == 0 {
+			return nil
+			// Index created before hierarchical fingerprints were stored
+		}
+		if distance := HammingDistance(queryHash, document.Hash); distance <= threshold {
+			duplicates = append(duplicates, DocumentMatch{Doc: doc, Distance: distance})
+		}
+	}
+	sort.SliceStable(duplicates, func(i, j int) bool { return duplicates[i].Distance < duplicates[j].Distance })
+	return duplicates
+}
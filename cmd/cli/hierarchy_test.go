@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBuildHierarchy(t *testing.T) {
+	file := "test_sections.txt"
+	// Ten 40-byte paragraphs separated by blank lines
+	var sb strings.Builder
+	for i := 0; i < 10; i++ {
+		sb.WriteString(strings.Repeat(string(rune('a'+i)), 38) + "\n\n")
+	}
+	os.WriteFile(file, []byte(sb.String()), 0644)
+	defer os.Remove(file)
+
+	index, err := createIndex(file, 16)
+	if err != nil {
+		t.Fatalf("createIndex failed: %v", err)
+	}
+	doc := index.Documents[0]
+	if doc.Hash == (Fingerprint{}) || len(doc.Sections) < 2 {
+		t.Fatalf("Expected a document fingerprint and several sections, got %+v", doc)
+	}
+
+	// Sections tile the document's chunks and end at paragraph breaks
+	next := 0
+	for i, section := range doc.Sections {
+		if section.FirstChunk != next || section.LastChunk < section.FirstChunk {
+			t.Fatalf("Section %d covers chunks %d-%d, expected to start at %d", i, section.FirstChunk, section.LastChunk, next)
+		}
+		length := section.LastChunk - section.FirstChunk + 1
+		if length > 2*sectionChunks {
+			t.Errorf("Section %d has %d chunks", i, length)
+		}
+		if i < len(doc.Sections)-1 && !containsParagraphBreak(&doc, index.Chunks[section.LastChunk]) && length != 2*sectionChunks {
+			t.Errorf("Section %d ends at chunk %d without a paragraph break", i, section.LastChunk)
+		}
+		next = section.LastChunk + 1
+	}
+	if next != len(index.Chunks) {
+		t.Errorf("Sections cover %d of %d chunks", next, len(index.Chunks))
+	}
+}
+
+func TestNearDuplicates(t *testing.T) {
+	paths := []string{"../../resources/t.txt", "../../resources/original.txt"}
+	index, err := createCorpusIndex(paths, 64, 64)
+	if err != nil {
+		t.Fatalf("createCorpusIndex failed: %v", err)
+	}
+	for doc := range index.Documents {
+		if first, last := documentChunks(index, doc); first > last || index.Chunks[first].Doc != doc || index.Chunks[last].Doc != doc {
+			t.Errorf("documentChunks(%d) = %d-%d", doc, first, last)
+		}
+	}
+
+	// The plagiarised copy is a near-duplicate of the second document only
+	comparison, err := compareDocument(index, "../../resources/plagirized.txt", false)
+	if err != nil {
+		t.Fatalf("compareDocument failed: %v", err)
+	}
+	if len(comparison.Duplicates) != 1 || comparison.Duplicates[0].Doc != 1 {
+		t.Errorf("Expected document 1 as near-duplicate, got %+v", comparison.Duplicates)
+	}
+	if comparison.Originality != 0 {
+		t.Errorf("Expected originality 0, got %.1f", comparison.Originality)
+	}
+
+	// Indexes without sections are searched in full
+	for i := range index.Documents {
+		index.Documents[i].Sections = nil
+	}
+	query, _ := createIndex("../../resources/plagirized.txt", 64)
+	if duplicates := nearDuplicates(index, query); duplicates != nil {
+		t.Errorf("Expected no near-duplicates for an index without sections, got %v", duplicates)
+	}
+	if _, ok := candidateChunks(index, query.Chunks[0].Hash, hammingThreshold(index)); ok {
+		t.Errorf("Expected no coarse filtering for an index without sections")
+	}
+}
+
+func TestSharedPassage(t *testing.T) {
+	// filler returns n bytes of text made of words no other filler uses
+	filler := func(word string, n int) string {
+		var sb strings.Builder
+		for i := 0; sb.Len() < n; i++ {
+			fmt.Fprintf(&sb, "%s%d ", word, i)
+		}
+		return sb.String()[:n]
+	}
+	// A two-chunk passage placed on chunk boundaries in otherwise unrelated documents
+	passage := filler("tiger", 128)
+
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+		return path
+	}
+	paths := []string{
+		write("a.txt", filler("amber", 640)+passage+filler("ash", 320)),
+		write("b.txt", filler("birch", 384)+passage+filler("beech", 512)),
+		write("c.txt", filler("cedar", 1024)),
+	}
+	queryFile := write("query.txt", filler("cedar", 768)+passage)
+
+	index, err := createCorpusIndex(paths, 64, 64)
+	if err != nil {
+		t.Fatalf("createCorpusIndex failed: %v", err)
+	}
+	threshold := hammingThreshold(index)
+
+	// The coarse stage never drops a chunk within the threshold
+	query, err := createIndex(queryFile, 64)
+	if err != nil {
+		t.Fatalf("createIndex failed: %v", err)
+	}
+	for _, queryChunk := range query.Chunks {
+		candidates, ok := candidateChunks(index, queryChunk.Hash, threshold)
+		if !ok {
+			t.Fatalf("Expected sections in a new index")
+		}
+		kept := make(map[int]bool)
+		for _, chunkIdx := range candidates {
+			kept[chunkIdx] = true
+		}
+		for chunkIdx, chunk := range index.Chunks {
+			if HammingDistance(queryChunk.Hash, chunk.Hash) <= threshold && !kept[chunkIdx] {
+				t.Errorf("Chunk %d is within the threshold of query offset %d but was pruned", chunkIdx, queryChunk.Offset)
+			}
+		}
+	}
+
+	// lookup finds the passage in both documents holding it
+	chunks, _ := lookupQuery(index, queryFingerprint(index, passage[:64]))
+	docs := make(map[int]bool)
+	for _, chunk := range chunks {
+		docs[chunk.Doc] = true
+	}
+	if !docs[0] || !docs[1] {
+		t.Errorf("Expected lookup matches in documents 0 and 1, got %+v", chunks)
+	}
+
+	// compare finds it too, although only the third document resembles the query overall
+	comparison, err := compareDocument(index, queryFile, false)
+	if err != nil {
+		t.Fatalf("compareDocument failed: %v", err)
+	}
+	docs = make(map[int]bool)
+	for _, region := range comparison.Regions {
+		docs[region.SourceDoc] = true
+	}
+	if !docs[0] || !docs[1] || !docs[2] {
+		t.Errorf("Expected regions in all three documents, got %+v", comparison.Regions)
+	}
+	if comparison.Originality != 0 {
+		t.Errorf("Expected originality 0, got %.1f", comparison.Originality)
+	}
+}
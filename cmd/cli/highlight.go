@@ -0,0 +1,94 @@
+package main
+
+import (
+	"os"
+
+	"trufast/internal/textsearch"
+)
+
+const (
+	ansiHighlight = "\033[1;33m"
+	ansiReset     = "\033[0m"
+	// ANSI escape sequences used to highlight matches in a terminal
+)
+
+// MatchSpan locates the part of the indexed file that best aligns with a query
+type MatchSpan struct {
+	Start int64
+	// Start is the byte offset of the first matched byte in the original file
+
+	End int64
+	// End is the byte offset just past the last matched byte
+	// The span may extend into the neighbouring chunks
+
+	Score int
+	// Score is the local alignment score; higher means a closer match
+}
+
+// locateMatch finds the span of a chunk and its neighbours that best matches the query
+// A window of one chunk size before and after the chunk is searched so that
+// queries straddling a chunk boundary are still located in full
+// Parameters:
+//
+//	index: Pointer to the loaded Index structure
+//	chunk: The chunk returned by the lookup
+//	query: The query text
+//
+// Returns:
+//
+//	MatchSpan: Absolute byte offsets of the best aligned region
+//	bool: false if no query word occurs in the window
+//	error: nil on success, error if reading the file fails
+func locateMatch(index *Index, chunk ChunkInfo, query string) (MatchSpan, bool, error) {
+	// Read the chunk together with its neighbours
+	windowStart := max(chunk.Offset-int64(index.ChunkSize), 0)
+	windowSize := int(chunk.Offset-windowStart) + chunk.Size + index.ChunkSize
+	window, err := getChunkContent(chunkDocument(index, chunk).Path, windowStart, windowSize)
+	if err != nil {
+		return MatchSpan{}, false, err
+	}
+
+	start, end, score := textsearch.Align(query, window)
+	if score <= 0 {
+		return MatchSpan{}, false, nil
+		// No query word occurs in the window
+	}
+	return MatchSpan{Start: windowStart + int64(start), End: windowStart + int64(end), Score: score}, true, nil
+}
+
+// highlightRegion returns the text covering both the chunk and the match span,
+// with the span wrapped in the given markers
+// Parameters:
+//
+//	index: Pointer to the loaded Index structure
+//	chunk: The chunk returned by the lookup
+//	span: The located match
+//	markStart, markEnd: Markers inserted before and after the matched bytes
+//
+// Returns:
+//
+//	string: The highlighted text
+//	error: nil on success, error if reading the file fails
+func highlightRegion(index *Index, chunk ChunkInfo, span MatchSpan, markStart, markEnd string) (string, error) {
+	regionStart := min(chunk.Offset, span.Start)
+	regionEnd := max(chunk.Offset+int64(chunk.Size), span.End)
+	region, err := getChunkContent(chunkDocument(index, chunk).Path, regionStart, int(regionEnd-regionStart))
+	if err != nil {
+		return "", err
+	}
+
+	// Clamp in case the file is shorter than recorded
+	from := min(int(span.Start-regionStart), len(region))
+	to := min(int(span.End-regionStart), len(region))
+	return region[:from] + markStart + region[from:to] + markEnd + region[to:], nil
+}
+
+// isTerminal reports whether the file is attached to a terminal
+// Used to avoid writing ANSI escape sequences into pipes and files
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestLocateMatch(t *testing.T) {
+	file := "test_locate.txt"
+	content := "the first chunk " + "law of the jungle" + " is the last part"
+	os.WriteFile(file, []byte(content), 0644)
+	defer os.Remove(file)
+
+	index, err := createIndex(file, 16)
+	if err != nil {
+		t.Fatalf("createIndex failed: %v", err)
+	}
+
+	// The phrase straddles the second and third chunks
+	span, found, err := locateMatch(index, index.Chunks[1], "law of the jungle")
+	if err != nil || !found {
+		t.Fatalf("locateMatch() found = %v, err = %v", found, err)
+	}
+	if got := content[span.Start:span.End]; got != "law of the jungle" {
+		t.Errorf("locateMatch() span = %q", got)
+	}
+
+	highlighted, err := highlightRegion(index, index.Chunks[1], span, "[", "]")
+	if err != nil {
+		t.Fatalf("highlightRegion failed: %v", err)
+	}
+	if highlighted != "[law of the jungle]" {
+		t.Errorf("highlightRegion() = %q", highlighted)
+	}
+}
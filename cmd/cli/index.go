@@ -1,59 +1,101 @@
 package main
 
 import (
-	"bufio"
+	"crypto/sha256"
 	"encoding/gob"
+	"encoding/hex"
 	"fmt"
+	"hash/crc32"
 	"io"
 	"os"
 	"path/filepath"
 	"runtime"
-	"strconv"
+	"sort"
 	"sync"
 
-	"github.com/mfonda/simhash"
+	"trufast/internal/textsearch"
 )
 
+// IndexOptions groups the optional settings of the index command
+type IndexOptions struct {
+	CommonThreshold float64
+	// CommonThreshold suppresses fingerprints shared by more than this percentage
+	// of documents (0 disables suppression)
+
+	Weighted bool
+	// Weighted builds TF-IDF weighted fingerprints in a second pass over the corpus
+	// instead of weighting every word equally
+
+	HashBits int
+	// HashBits is the fingerprint width: 64, 128 or 256 bits (0 means 64)
+}
+
 // indexCommand handles the index command
 // It creates and saves an index from a text file using specified chunk size
 // Parameters:
 //
-//	inputFile: Path to the text file to index
+//	inputFile: Path to the text file to index; a comma-separated list of files
+//	           or a directory indexes every file as a separate document
 //	chunkSize: Size in bytes for each chunk
 //	outputFile: Path where the index file will be saved
+//	opts: Optional suppression and weighting settings
 //
 // Returns:
 //
 //	error: nil on success, error if operation fails
-func indexCommand(inputFile string, chunkSize int, outputFile string) error {
+func indexCommand(inputFile string, chunkSize int, outputFile string, opts IndexOptions) error {
 	// Validate parameters
 	if inputFile == "" {
 		return fmt.Errorf("error: input file is required")
 		// Ensures an input file path was provided via -i flag
 	}
+	if opts.CommonThreshold < 0 || opts.CommonThreshold > 100 {
+		return fmt.Errorf("invalid common threshold: %g. Provide a percentage between 0 and 100", opts.CommonThreshold)
+	}
+	if opts.HashBits == 0 {
+		opts.HashBits = defaultHashBits
+	}
+	if err := validateHashBits(opts.HashBits); err != nil {
+		return err
+	}
+
+	// Expand the list of documents to index
+	paths, err := expandInputs(inputFile)
+	if err != nil {
+		return err
+	}
 
 	// Set default output filename if not provided
 	if outputFile == "" {
-		outputFile = filepath.Base(inputFile) + ".idx"
+		outputFile = filepath.Base(paths[0]) + ".idx"
 		// Uses input filename with .idx extension (e.g., "text.txt" -> "text.idx")
 	}
 
 	// Validate chunk size
-	err := validateChunkSize(chunkSize)
+	err = validateChunkSize(chunkSize)
 	if err != nil {
 		return err
 		// Returns any error from chunk size validation
 	}
 
 	// Create the index
-	fmt.Printf("Indexing %s (chunk size: %d bytes)...\n", inputFile, chunkSize)
+	fmt.Printf("Indexing %s (chunk size: %d bytes, %d-bit fingerprints)...\n", inputFile, chunkSize, opts.HashBits)
 	// Inform user of indexing operation start
-	index, err := createIndex(inputFile, chunkSize)
+	index, err := createCorpusIndex(paths, chunkSize, opts.HashBits)
 	if err != nil {
 		return err
 		// Returns any error from index creation
 	}
 
+	// Second pass: reweight fingerprints with corpus-wide document frequencies
+	if opts.Weighted {
+		weightIndex(index)
+	}
+
+	// Mark boilerplate shared by many documents
+	index.CommonThreshold = opts.CommonThreshold
+	markSuppressed(index)
+
 	// Save the index to file
 	err = saveIndex(index, outputFile)
 	if err != nil {
@@ -114,15 +156,17 @@ func saveIndex(index *Index, outputPath string) error {
 		// Wraps encoding error with context
 	}
 
-	// Generate additional hash logs (side effect)
-	generateHashLogs(index)
-	// Note: Purpose unclear without implementation details
-
 	return nil
 	// Successful completion
 }
 
-// createIndex processes a file and creates an index
+// chunkResult carries the output of an indexing worker for a single chunk
+type chunkResult struct {
+	info  ChunkInfo
+	freqs map[string]int // Term frequencies within the chunk
+}
+
+// createIndex processes a file and creates an index with 64-bit fingerprints
 // It reads a file in chunks, computes SimHash values, and builds an Index structure
 // Parameters:
 //
@@ -134,6 +178,21 @@ func saveIndex(index *Index, outputPath string) error {
 //	*Index: Pointer to the created Index structure
 //	error: nil on success, error if file operations fail
 func createIndex(filePath string, chunkSize int) (*Index, error) {
+	return createIndexBits(filePath, chunkSize, defaultHashBits)
+}
+
+// createIndexBits is createIndex with a chosen fingerprint width
+// Parameters:
+//
+//	filePath: Path to the text file to index
+//	chunkSize: Size in bytes for each chunk
+//	hashBits: Fingerprint width in bits (64, 128 or 256)
+//
+// Returns:
+//
+//	*Index: Pointer to the created Index structure
+//	error: nil on success, error if file operations fail
+func createIndexBits(filePath string, chunkSize int, hashBits int) (*Index, error) {
 	// Open the input file
 	file, err := os.Open(filePath)
 	if err != nil {
@@ -146,7 +205,29 @@ func createIndex(filePath string, chunkSize int) (*Index, error) {
 	if err != nil {
 		return nil, fmt.Errorf("error getting file info: %w", err)
 	}
-	fileSize := info.Size()
+	index, err := buildIndex(file, filePath, info.Size(), chunkSize, hashBits)
+	if err != nil {
+		return nil, err
+	}
+	index.Documents[0].ModTime = info.ModTime()
+	return index, nil
+}
+
+// buildIndex chunks and fingerprints the text read from r
+// It does the work of createIndex for any reader, such as a preprocessed copy of a file
+// Parameters:
+//
+//	r: Source of the text
+//	filePath: Path recorded in the index for retrieving chunk content later
+//	fileSize: Expected number of bytes, used for capacity estimation
+//	chunkSize: Size in bytes for each chunk
+//	hashBits: Fingerprint width in bits (64, 128 or 256)
+//
+// Returns:
+//
+//	*Index: Pointer to the created Index structure
+//	error: nil on success, error if reading fails
+func buildIndex(r io.Reader, filePath string, fileSize int64, chunkSize int, hashBits int) (*Index, error) {
 	estimatedChunks := int(fileSize / int64(chunkSize))
 	if fileSize%int64(chunkSize) != 0 {
 		estimatedChunks++ // Account for partial final chunk
@@ -156,8 +237,10 @@ func createIndex(filePath string, chunkSize int) (*Index, error) {
 	index := &Index{
 		FilePath:     filePath,
 		ChunkSize:    chunkSize,
+		HashBits:     hashBits,
 		Chunks:       make([]ChunkInfo, 0, estimatedChunks),
-		HashToChunks: make(map[uint64][]int),
+		HashToChunks: make(map[Fingerprint][]int),
+		Postings:     make(map[string][]Posting),
 	}
 
 	// Set up worker pool for parallel processing
@@ -166,47 +249,57 @@ func createIndex(filePath string, chunkSize int) (*Index, error) {
 		data   []byte
 		offset int64
 	}, numWorkers*2) // Buffered channel for job queue
-	results := make(chan ChunkInfo, numWorkers*10) // Buffered channel for results
+	results := make(chan chunkResult, numWorkers*10) // Buffered channel for results
 	var wg sync.WaitGroup
 
-	// Start worker goroutines to compute hashes
+	// Start worker goroutines to compute hashes and term frequencies
 	for range numWorkers {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
 			for job := range jobs {
 				text := string(job.data)
-				hash := simhash.Simhash(simhash.NewWordFeatureSet([]byte(text)))
-				results <- ChunkInfo{
-					Offset: job.offset,
-					Size:   len(job.data),
-					Hash:   hash,
+				hash := simhashFeatures(textFeatures(job.data), hashBits)
+				freqs, terms := textsearch.TermFrequencies(text)
+				results <- chunkResult{
+					info: ChunkInfo{
+						Offset:   job.offset,
+						Size:     len(job.data),
+						Hash:     hash,
+						Terms:    terms,
+						Checksum: crc32.ChecksumIEEE(job.data),
+					},
+					freqs: freqs,
 				}
 			}
 		}()
 	}
 
-	// Process results concurrently
+	// Collect results concurrently; they arrive in completion order
+	collected := make([]chunkResult, 0, estimatedChunks)
 	var resultWg sync.WaitGroup
 	resultWg.Add(1)
 	go func() {
 		defer resultWg.Done()
-		for chunkInfo := range results {
-			chunkIdx := len(index.Chunks)
-			index.Chunks = append(index.Chunks, chunkInfo)
-			index.HashToChunks[chunkInfo.Hash] = append(index.HashToChunks[chunkInfo.Hash], chunkIdx)
+		for result := range results {
+			collected = append(collected, result)
 		}
 	}()
 
 	// Read file and dispatch chunks to workers
+	// Line starts are recorded while reading so hits can be reported as line:column
+	// The whole text is digested on the way so later edits to the file can be detected
+	lineOffsets := []int64{0}
+	digest := sha256.New()
 	buffer := make([]byte, chunkSize)
 	var offset int64 = 0
 	for {
-		n, err := file.Read(buffer)
+		// ReadFull keeps chunks at full size even for readers returning short reads
+		n, err := io.ReadFull(r, buffer)
 		if err == io.EOF {
 			break
 		}
-		if err != nil {
+		if err != nil && err != io.ErrUnexpectedEOF {
 			close(jobs)
 			return nil, fmt.Errorf("error reading file: %w", err)
 		}
@@ -214,6 +307,8 @@ func createIndex(filePath string, chunkSize int) (*Index, error) {
 		// Copy buffer to prevent race conditions
 		data := make([]byte, n)
 		copy(data, buffer[:n])
+		lineOffsets = appendLineOffsets(lineOffsets, data, offset)
+		digest.Write(data)
 
 		// Send chunk to workers
 		jobs <- struct {
@@ -230,59 +325,20 @@ func createIndex(filePath string, chunkSize int) (*Index, error) {
 	close(results)
 	resultWg.Wait()
 
-	return index, nil
-}
-
-// generateHashLogs creates a log file with hash values and offsets
-// It writes up to the first 10 chunk hashes from the index to a text file
-// Parameters:
-//
-//	index: Pointer to the Index structure containing chunk information
-func generateHashLogs(index *Index) {
-	// Check if hashlogs.txt exists and remove it if it does
-	if _, err := os.Stat("hashlogs.txt"); err == nil {
-		os.Remove("hashlogs.txt")
-		// Ensures fresh log file each time
-	}
-
-	// Open/Create file with write permissions
-	file, err := os.OpenFile("hashlogs.txt", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
-	if err != nil {
-		fmt.Println("Error creating file:", err)
-		return
-		// Prints error and exits if file creation fails
-	}
-	defer file.Close() // Ensure file closure
-
-	// Create buffered writer for efficient writing
-	writer := bufio.NewWriter(file)
-
-	// Select up to first 10 chunks
-	var firstTenHashes []ChunkInfo
-	if len(index.Chunks) > 10 {
-		firstTenHashes = index.Chunks[:9] // Takes first 9 (not 10, possible bug)
-	} else if len(index.Chunks) < 10 {
-		firstTenHashes = index.Chunks[:] // Takes all if less than 10
-	}
-	// Note: Condition for exactly 10 chunks is missing
-
-	// Write hash-offset pairs to file
-	for _, line := range firstTenHashes {
-		hash := fmt.Sprintf("%x", line.Hash)     // Convert uint64 hash to hexadecimal
-		offset := strconv.Itoa(int(line.Offset)) // Convert int64 offset to string
-		_, err := writer.WriteString(hash + "=>" + offset + "\n")
-		if err != nil {
-			fmt.Println("Error writing to file:", err)
-			return
-			// Prints error and exits if write fails
+	// Restore file order so chunk indices follow byte offsets
+	sort.Slice(collected, func(i, j int) bool {
+		return collected[i].info.Offset < collected[j].info.Offset
+	})
+	for chunkIdx, result := range collected {
+		index.Chunks = append(index.Chunks, result.info)
+		index.HashToChunks[result.info.Hash] = append(index.HashToChunks[result.info.Hash], chunkIdx)
+		for term, freq := range result.freqs {
+			index.Postings[term] = append(index.Postings[term], Posting{Chunk: chunkIdx, Freq: freq})
 		}
 	}
+	index.Vocabulary = textsearch.BuildVocabulary(index.Postings)
+	index.Documents = []Document{{Path: filePath, Size: offset, LineOffsets: lineOffsets, Digest: hex.EncodeToString(digest.Sum(nil))}}
+	buildHierarchy(index)
 
-	// Flush buffer to ensure all data is written to disk
-	err = writer.Flush()
-	if err != nil {
-		fmt.Println("Error flushing buffer:", err)
-		return
-		// Prints error and exits if flush fails
-	}
+	return index, nil
 }
@@ -1,8 +1,6 @@
 package main
 
 import (
-	"bufio"
-	"fmt"
 	"os"
 	"testing"
 )
@@ -16,43 +14,11 @@ func TestIndexCommand(t *testing.T) {
 	outputFile := "test.idx"
 	defer os.Remove(outputFile)
 
-	if err := indexCommand(file, 10, outputFile); err != nil {
+	if err := indexCommand(file, 10, outputFile, IndexOptions{}); err != nil {
 		t.Fatalf("indexCommand failed: %v", err)
 	}
 }
 
-// TestGenerateHashLogs verifies that generateHashLogs correctly creates a hash log file.
-func TestGenerateHashLogs(t *testing.T) {
-	index := &Index{
-		Chunks: []ChunkInfo{
-			{Hash: 0x1a2b3c, Offset: 100}, {Hash: 0x4d5e6f, Offset: 200},
-			{Hash: 0x7a8b9c, Offset: 300}, {Hash: 0xabcdef, Offset: 400},
-		},
-	}
-
-	generateHashLogs(index)
-	fileName := "hashlogs.txt"
-	defer os.Remove(fileName)
-
-	file, err := os.Open(fileName)
-	if err != nil {
-		t.Fatalf("Error opening log file: %v", err)
-	}
-	defer file.Close()
-
-	scanner := bufio.NewScanner(file)
-	for i, chunk := range index.Chunks {
-		if i >= 10 {
-			break
-		}
-		scanner.Scan()
-		expected := fmt.Sprintf("%x=>%d", chunk.Hash, chunk.Offset)
-		if scanner.Text() != expected {
-			t.Errorf("Mismatch in log content: expected %q, got %q", expected, scanner.Text())
-		}
-	}
-}
-
 func TestCreateIndex(t *testing.T) {
 	file := "test.txt"
 	content := "Hello, world! This is a test file."
@@ -0,0 +1,71 @@
+package main
+
+// legacyChunkInfo is ChunkInfo as written before wider fingerprints were supported
+// Only Hash changed type; the other fields decode unchanged
+type legacyChunkInfo struct {
+	Offset     int64
+	Size       int
+	Hash       uint64
+	Terms      int
+	Doc        int
+	Suppressed bool
+}
+
+// legacyIndex is Index as written before wider fingerprints were supported
+// Every fingerprint was a uint64; gob refuses to decode those into a Fingerprint,
+// so loadIndex falls back to this layout when the current one does not match
+type legacyIndex struct {
+	FilePath        string
+	ChunkSize       int
+	Chunks          []legacyChunkInfo
+	HashToChunks    map[uint64][]int
+	Postings        map[string][]Posting
+	Vocabulary      []string
+	Documents       []Document
+	Excluded        []uint64
+	CommonThreshold float64
+	IDF             map[string]float64
+}
+
+// upgrade converts a legacy index to the current layout
+// Each uint64 hash becomes the low word of a 64-bit Fingerprint, which is what
+// the same text fingerprints to today, so lookups behave exactly as before
+// Returns:
+//
+//	*Index: The converted index; HashBits is left 0, meaning 64 bits
+func (legacy *legacyIndex) upgrade() *Index {
+	index := &Index{
+		FilePath:        legacy.FilePath,
+		ChunkSize:       legacy.ChunkSize,
+		Postings:        legacy.Postings,
+		Vocabulary:      legacy.Vocabulary,
+		Documents:       legacy.Documents,
+		CommonThreshold: legacy.CommonThreshold,
+		IDF:             legacy.IDF,
+	}
+
+	if legacy.Chunks != nil {
+		index.Chunks = make([]ChunkInfo, len(legacy.Chunks))
+		for i, chunk := range legacy.Chunks {
+			index.Chunks[i] = ChunkInfo{
+				Offset:     chunk.Offset,
+				Size:       chunk.Size,
+				Hash:       Fingerprint{chunk.Hash},
+				Terms:      chunk.Terms,
+				Doc:        chunk.Doc,
+				Suppressed: chunk.Suppressed,
+			}
+		}
+	}
+	if legacy.HashToChunks != nil {
+		index.HashToChunks = make(map[Fingerprint][]int, len(legacy.HashToChunks))
+		for hash, chunks := range legacy.HashToChunks {
+			index.HashToChunks[Fingerprint{hash}] = chunks
+		}
+	}
+	for _, hash := range legacy.Excluded {
+		index.Excluded = append(index.Excluded, Fingerprint{hash})
+	}
+
+	return index
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// appendLineOffsets records the start of every line that begins inside data
+// Parameters:
+//
+//	offsets: Line starts found so far
+//	data: The bytes just read from the file
+//	base: Byte offset of data within the file
+//
+// Returns:
+//
+//	[]int64: offsets extended with the new line starts
+func appendLineOffsets(offsets []int64, data []byte, base int64) []int64 {
+	for i := 0; i < len(data); {
+		next := bytes.IndexByte(data[i:], '\n')
+		if next < 0 {
+			break
+		}
+		i += next + 1
+		offsets = append(offsets, base+int64(i))
+	}
+	return offsets
+}
+
+// lineColumn converts a byte offset into a 1-based line and byte column
+// Parameters:
+//
+//	doc: Pointer to the indexed document the offset refers to
+//	offset: Byte offset in the document
+//
+// Returns:
+//
+//	int: Line number, starting at 1
+//	int: Column in bytes from the start of the line, starting at 1
+func lineColumn(doc *Document, offset int64) (int, int) {
+	if len(doc.LineOffsets) == 0 {
+		return 1, int(offset) + 1
+		// Index created before line tables were stored
+	}
+	// Find the last line starting at or before offset
+	line := sort.Search(len(doc.LineOffsets), func(i int) bool {
+		return doc.LineOffsets[i] > offset
+	})
+	return line, int(offset-doc.LineOffsets[line-1]) + 1
+}
+
+// lineStart returns the byte offset where a 1-based line begins
+// Lines past the end of the document map to the end of the document
+func lineStart(doc *Document, line int) int64 {
+	if line < 1 {
+		return 0
+	}
+	if line > len(doc.LineOffsets) {
+		return doc.Size
+	}
+	return doc.LineOffsets[line-1]
+}
+
+// chunkContext reads the text surrounding a region of a document
+// Regions rarely start or end on a line boundary, so the leading context runs up
+// to the region's first byte and the trailing context starts just past its last
+// byte; together with the region they cover whole lines without gaps
+// Parameters:
+//
+//	doc: Pointer to the indexed document containing the region
+//	start, end: Byte range of the region
+//	before, after: Number of whole lines to include on either side
+//
+// Returns:
+//
+//	string: The before lines preceding the region's first line, plus the start of that line
+//	string: The rest of the region's last line, plus the after lines following it
+//	int: Line number of the first leading context line
+//	int: Line number of the first trailing context line
+//	error: nil on success, error if reading the file fails
+func chunkContext(doc *Document, start, end int64, before, after int) (string, string, int, int, error) {
+	if len(doc.LineOffsets) == 0 {
+		return "", "", 0, 0, fmt.Errorf("index has no line table; re-index the file to use context lines")
+	}
+
+	startLine, _ := lineColumn(doc, start)
+	firstLine := max(startLine-before, 1)
+	from := lineStart(doc, firstLine)
+	leading, err := getChunkContent(doc.Path, from, int(start-from))
+	if err != nil {
+		return "", "", 0, 0, err
+	}
+
+	endLine, _ := lineColumn(doc, max(end-1, start))
+	trailingLine, _ := lineColumn(doc, end)
+	trailing, err := getChunkContent(doc.Path, end, int(max(lineStart(doc, endLine+1+after)-end, 0)))
+	if err != nil {
+		return "", "", 0, 0, err
+	}
+	return leading, trailing, firstLine, trailingLine, nil
+}
+
+// numberLines prefixes each line of text with its line number, grep style
+// The first line of text is numbered firstLine; a final partial line is
+// terminated so the next output starts on a line of its own
+func numberLines(text string, firstLine int) string {
+	if text == "" {
+		return ""
+	}
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	var sb strings.Builder
+	for i, line := range lines {
+		fmt.Fprintf(&sb, "%d-%s", firstLine+i, line)
+	}
+	if !strings.HasSuffix(text, "\n") {
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestAppendLineOffsets(t *testing.T) {
+	offsets := []int64{0}
+	offsets = appendLineOffsets(offsets, []byte("ab\ncd"), 0)
+	offsets = appendLineOffsets(offsets, []byte("\n\nef\n"), 5)
+	want := []int64{0, 3, 6, 7, 10}
+	if !reflect.DeepEqual(offsets, want) {
+		t.Errorf("appendLineOffsets() = %v, want %v", offsets, want)
+	}
+}
+
+func TestLineColumn(t *testing.T) {
+	doc := &Document{Size: 12, LineOffsets: []int64{0, 3, 6, 7, 10}}
+	tests := []struct {
+		offset       int64
+		line, column int
+	}{
+		{0, 1, 1},
+		{2, 1, 3},
+		{3, 2, 1},
+		{6, 3, 1},
+		{8, 4, 2},
+		{10, 5, 1},
+	}
+
+	for _, tt := range tests {
+		line, column := lineColumn(doc, tt.offset)
+		if line != tt.line || column != tt.column {
+			t.Errorf("lineColumn(%d) = %d:%d; want %d:%d", tt.offset, line, column, tt.line, tt.column)
+		}
+	}
+}
+
+func TestChunkContext(t *testing.T) {
+	file := "test_context.txt"
+	content := "one\ntwo\nthree\nfour\nfive\nsix\n"
+	os.WriteFile(file, []byte(content), 0644)
+	defer os.Remove(file)
+
+	index, err := createIndex(file, 5)
+	if err != nil {
+		t.Fatalf("createIndex failed: %v", err)
+	}
+
+	// Region "ree\nfo" starts and ends mid-line on lines 3 and 4
+	leading, trailing, leadingLine, trailingLine, err := chunkContext(&index.Documents[0], 10, 16, 1, 2)
+	if err != nil {
+		t.Fatalf("chunkContext failed: %v", err)
+	}
+	if leading != "two\nth" || leadingLine != 2 {
+		t.Errorf("leading = %q from line %d", leading, leadingLine)
+	}
+	if trailing != "ur\nfive\nsix\n" || trailingLine != 4 {
+		t.Errorf("trailing = %q from line %d", trailing, trailingLine)
+	}
+	if leading+"ree\nfo"+trailing != content[4:] {
+		t.Errorf("Context leaves a gap around the region: %q + region + %q", leading, trailing)
+	}
+	if got, want := numberLines(leading, leadingLine), "2-two\n3-th\n"; got != want {
+		t.Errorf("numberLines() = %q, want %q", got, want)
+	}
+
+	// A region ending with its line's newline has no partial trailing line
+	_, trailing, _, trailingLine, err = chunkContext(&index.Documents[0], 8, 14, 0, 1)
+	if err != nil {
+		t.Fatalf("chunkContext failed: %v", err)
+	}
+	if trailing != "four\n" || trailingLine != 4 {
+		t.Errorf("trailing = %q from line %d", trailing, trailingLine)
+	}
+
+	// Context is clipped at the start and end of the file
+	leading, trailing, _, _, err = chunkContext(&index.Documents[0], 0, 3, 5, 10)
+	if err != nil {
+		t.Fatalf("chunkContext failed: %v", err)
+	}
+	if leading != "" || trailing != "\ntwo\nthree\nfour\nfive\nsix\n" {
+		t.Errorf("Unexpected clipped context: %q / %q", leading, trailing)
+	}
+}
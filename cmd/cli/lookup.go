@@ -4,29 +4,94 @@ import (
 	"encoding/gob"
 	"fmt"
 	"io"
-	"math/bits"
 	"os"
+
+	"trufast/internal/textsearch"
 )
 
+// maxHammingDistance is the threshold for similarity
+// 64-bit hashes differing in at most this many bits are treated as approximate matches
+// Wider fingerprints scale it with their width (see hammingThreshold)
+const maxHammingDistance = 10
+
+// LookupOptions groups the optional settings of the lookup command
+type LookupOptions struct {
+	QueryText string
+	// QueryText is the query text; when set, the best matching span is located
+	// inside each chunk and highlighted
+
+	DiffFormat string
+	// DiffFormat selects word-level diff output ("text", "html" or "json")
+	// When set, a diff of QueryText against each matched span is printed instead
+
+	Before int
+	// Before is the number of lines of context printed before each match
+
+	After int
+	// After is the number of lines of context printed after each match
+
+	Format string
+	// Format selects an exported report ("html", "markdown", "csv" or "json")
+	// instead of the plain listing
+
+	Out string
+	// Out is the path the report is written to; empty writes to stdout
+
+	Output string
+	// Output selects machine-readable output on stdout ("json", "ndjson" or "tsv")
+	// instead of the plain listing
+
+	Strict bool
+	// Strict refuses to show matches whose source text changed since indexing
+	// instead of warning about them
+}
+
 // lookupCommand handles the lookup command
 // It searches an index file for chunks matching a given SimHash value and displays their contents
 // Parameters:
 //
 //	indexFile: Path to the previously generated index file
-//	queryHash: SimHash value to search for (as uint64)
+//	queryHash: SimHash value to search for; the zero value fingerprints opts.QueryText
+//	opts: Optional query text, diff format, context line, output and strictness settings
 //
 // Returns:
 //
 //	error: nil on success, error if operation fails
-func lookupCommand(indexFile string, queryHash uint64) error {
+func lookupCommand(indexFile string, queryHash Fingerprint, opts LookupOptions) error {
 	// Validate parameters
 	if indexFile == "" {
 		return fmt.Errorf("error: index file is required")
 		// Ensures an index file path was provided via -i flag
 	}
-	if queryHash == 0 {
+	if queryHash == (Fingerprint{}) && opts.QueryText == "" {
 		return fmt.Errorf("error: simhash value is required")
-		// Ensures a non-zero hash value was provided via -h flag
+		// Ensures a non-zero hash value was provided via -h or -q flag
+	}
+	if err := validateDiffFormat(opts.DiffFormat); err != nil {
+		return err
+	}
+	if opts.DiffFormat != "" && opts.QueryText == "" {
+		return fmt.Errorf("error: query text is required for diff output")
+		// A diff needs the query text, not just its hash
+	}
+	if opts.Before < 0 || opts.After < 0 {
+		return fmt.Errorf("invalid context: %d lines before, %d after", opts.Before, opts.After)
+	}
+	format, err := reportFormat(opts.Format, opts.Out)
+	if err != nil {
+		return err
+	}
+	if format != "" && opts.DiffFormat != "" {
+		return fmt.Errorf("error: choose either a diff or a report format")
+	}
+	if err := validateOutputFormat(opts.Output); err != nil {
+		return err
+	}
+	if err := checkOutputFlags("lookup", format, opts.Output); err != nil {
+		return err
+	}
+	if opts.Output != "" && opts.DiffFormat != "" {
+		return fmt.Errorf("error: choose either an output mode or a diff format")
 	}
 
 	// Load the index from file into memory
@@ -35,6 +100,10 @@ func lookupCommand(indexFile string, queryHash uint64) error {
 		return err
 		// Returns any error from loading the index file
 	}
+	if queryHash == (Fingerprint{}) {
+		queryHash = queryFingerprint(index, opts.QueryText)
+		// Fingerprint the query text the same way chunks were fingerprinted
+	}
 
 	// Find chunks matching the query hash
 	matchingChunks, err := lookupQuery(index, queryHash)
@@ -43,6 +112,33 @@ func lookupCommand(indexFile string, queryHash uint64) error {
 		// Returns any error from the lookup operation
 	}
 
+	// Check the matched chunks still hold the indexed text
+	// Warnings go to stderr so machine-readable output stays parseable
+	if changed := changedChunks(index, matchingChunks); len(changed) > 0 {
+		if opts.Strict {
+			return fmt.Errorf("%d matching chunk(s) changed since indexing; run the verify command and re-index", len(changed))
+		}
+		for _, chunk := range changed {
+			fmt.Fprintf(os.Stderr, "Warning: chunk at byte offset %d of %s changed since indexing; its text may not match\n", chunk.Offset, chunkDocument(index, chunk).Path)
+		}
+	}
+
+	// Write machine-readable hits instead of the listing when requested
+	// An empty result is still written so pipelines see a well-formed document
+	if opts.Output != "" {
+		hits, err := lookupHits(index, queryHash, matchingChunks)
+		if err != nil {
+			return err
+		}
+		if err := writeHits(os.Stdout, opts.Output, lookupOutputSchema, hitHeader, hits); err != nil {
+			return err
+		}
+		if len(hits) == 0 {
+			return fmt.Errorf("SimHash not found. Ensure the file was indexed before looking up")
+		}
+		return nil
+	}
+
 	// Handle case where no matches are found
 	if len(matchingChunks) == 0 {
 		fmt.Println("No matches found for query.")
@@ -50,19 +146,77 @@ func lookupCommand(indexFile string, queryHash uint64) error {
 		// Returns error to indicate no matches, but still considers it a valid operation
 	}
 
+	// Export a report instead of printing the matches when requested
+	if format != "" {
+		report, err := lookupReport(index, indexFile, queryHash, matchingChunks, opts.QueryText)
+		if err != nil {
+			return err
+		}
+		return writeReport(report, format, opts.Out)
+	}
+
+	// Print word-level diffs instead of chunk contents when requested
+	if opts.DiffFormat != "" {
+		reports, err := diffMatches(index, matchingChunks, opts.QueryText)
+		if err != nil {
+			return err
+		}
+		return printDiffReports(reports, opts.DiffFormat)
+	}
+
+	// Highlight matches only when writing to a terminal
+	markStart, markEnd := "", ""
+	if isTerminal(os.Stdout) {
+		markStart, markEnd = ansiHighlight, ansiReset
+	}
+
 	// Display matching chunks
 	for i, chunk := range matchingChunks {
+		doc := chunkDocument(index, chunk)
+
 		// Retrieve the actual text content for each matching chunk
-		content, err := getChunkContent(index.FilePath, chunk.Offset, chunk.Size)
+		content, err := getChunkContent(doc.Path, chunk.Offset, chunk.Size)
 		if err != nil {
 			return err
 			// Returns any error from reading chunk content
 		}
 
+		// Region shown to the user; grows when the match spills into a neighbour
+		start, end := chunk.Offset, chunk.Offset+int64(chunk.Size)
+
+		// Locate the query inside the chunk and its neighbours
+		if opts.QueryText != "" {
+			span, found, err := locateMatch(index, chunk, opts.QueryText)
+			if err != nil {
+				return err
+			}
+			if found {
+				content, err = highlightRegion(index, chunk, span, markStart, markEnd)
+				if err != nil {
+					return err
+				}
+				start, end = min(start, span.Start), max(end, span.End)
+				line, column := lineColumn(doc, span.Start)
+				fmt.Printf("Best match at byte offsets: %d-%d (line %d, column %d)\n", span.Start, span.End, line, column)
+			}
+		}
+
 		// Print chunk information and content
-		fmt.Printf("Query found in chunk at byte offset: %d\n", chunk.Offset)
-		fmt.Println("Chunk content:")
-		fmt.Println(content)
+		line, column := lineColumn(doc, chunk.Offset)
+		fmt.Printf("Query found in chunk at byte offset: %d%s (line %d, column %d)\n", chunk.Offset, documentLabel(index, chunk), line, column)
+		if opts.Before > 0 || opts.After > 0 {
+			leading, trailing, leadingLine, trailingLine, err := chunkContext(doc, start, end, opts.Before, opts.After)
+			if err != nil {
+				return err
+			}
+			fmt.Print(numberLines(leading, leadingLine))
+			fmt.Println("Chunk content:")
+			fmt.Println(content)
+			fmt.Print(numberLines(trailing, trailingLine))
+		} else {
+			fmt.Println("Chunk content:")
+			fmt.Println(content)
+		}
 
 		// Add separator between multiple chunks (but not after the last one)
 		if i < len(matchingChunks)-1 {
@@ -77,6 +231,44 @@ func lookupCommand(indexFile string, queryHash uint64) error {
 	// Successful completion with at least one match
 }
 
+// diffMatches diffs the query against the best aligned region of each matching chunk
+// Falls back to the whole chunk when no query word occurs near it
+// Parameters:
+//
+//	index: Pointer to the loaded Index structure
+//	chunks: Chunks returned by the lookup
+//	queryText: The query text
+//
+// Returns:
+//
+//	[]DiffReport: One report per chunk, in the same order
+//	error: nil on success, error if reading the file fails
+func diffMatches(index *Index, chunks []ChunkInfo, queryText string) ([]DiffReport, error) {
+	reports := make([]DiffReport, 0, len(chunks))
+	for _, chunk := range chunks {
+		start, end := chunk.Offset, chunk.Offset+int64(chunk.Size)
+		span, found, err := locateMatch(index, chunk, queryText)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			start, end = span.Start, span.End
+		}
+
+		source, err := getChunkContent(chunkDocument(index, chunk).Path, start, int(end-start))
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, DiffReport{
+			Offset: chunk.Offset,
+			Start:  start,
+			End:    end,
+			Ops:    textsearch.DiffWords(source, queryText),
+		})
+	}
+	return reports, nil
+}
+
 // getChunkContent retrieves the content of a chunk from the original file
 // It reads a specific portion of a file based on offset and size
 // Parameters:
@@ -124,16 +316,17 @@ func getChunkContent(filePath string, offset int64, size int) (string, error) {
 
 // lookupQuery finds chunks that might contain the query text
 // It searches the index for chunks matching the query hash exactly or approximately
+// Approximate matches are only sought in sections that can hold one (see candidateChunks)
 // Parameters:
 //
 //	index: Pointer to the loaded Index structure containing chunk information
-//	queryHash: SimHash value (uint64) to search for
+//	queryHash: SimHash value to search for
 //
 // Returns:
 //
 //	[]ChunkInfo: Slice of matching chunk metadata
 //	error: nil on success (currently no error conditions defined)
-func lookupQuery(index *Index, queryHash uint64) ([]ChunkInfo, error) {
+func lookupQuery(index *Index, queryHash Fingerprint) ([]ChunkInfo, error) {
 	// Note: Commented code suggests original intent to compute SimHash from text
 	// queryHash := simhash.Simhash(simhash.NewWordFeatureSet([]byte(queryHashs)))
 	// Current implementation assumes queryHash is pre-computed
@@ -144,23 +337,37 @@ func lookupQuery(index *Index, queryHash uint64) ([]ChunkInfo, error) {
 	// Step 1: Look for exact matches
 	// Get all chunk indices that exactly match the query hash
 	for _, chunkIdx := range index.HashToChunks[queryHash] {
+		if index.Chunks[chunkIdx].Suppressed {
+			continue
+			// Skips expected matches (boilerplate)
+		}
 		matchingChunks = append(matchingChunks, index.Chunks[chunkIdx])
 		// Adds corresponding ChunkInfo from Chunks slice
 	}
 
 	// Step 2: If no exact matches, perform fuzzy matching
 	if len(matchingChunks) == 0 {
-		const maxHammingDistance = 10 // Threshold for similarity
-		// Hard-coded maximum Hamming distance for approximate matches
-
-		// Iterate through all hashes in the index
+		threshold := hammingThreshold(index)
+		// Coarse stage: only sections that can hold a close chunk are searched
+		if candidates, ok := candidateChunks(index, queryHash, threshold); ok {
+			for _, chunkIdx := range candidates {
+				chunk := index.Chunks[chunkIdx]
+				if !chunk.Suppressed && HammingDistance(queryHash, chunk.Hash) <= threshold {
+					matchingChunks = append(matchingChunks, chunk)
+				}
+			}
+			return matchingChunks, nil
+		}
+		// Indexes without sections: iterate through all hashes in the index
 		for hash, chunkIndices := range index.HashToChunks {
 			// Calculate Hamming distance between query and stored hash
 			distance := HammingDistance(queryHash, hash)
-			if distance <= maxHammingDistance {
+			if distance <= threshold {
 				// If sufficiently similar, add all chunks with this hash
 				for _, chunkIdx := range chunkIndices {
-					matchingChunks = append(matchingChunks, index.Chunks[chunkIdx])
+					if !index.Chunks[chunkIdx].Suppressed {
+						matchingChunks = append(matchingChunks, index.Chunks[chunkIdx])
+					}
 				}
 			}
 		}
@@ -171,23 +378,6 @@ func lookupQuery(index *Index, queryHash uint64) ([]ChunkInfo, error) {
 	// No error conditions currently implemented
 }
 
-// HammingDistance calculates the bit-level distance between two hashes
-// It computes the number of differing bits between two 64-bit values
-// Parameters:
-//
-//	a: First hash value (uint64)
-//	b: Second hash value (uint64)
-//
-// Returns:
-//
-//	int: Number of bits that differ between a and b (0 to 64)
-func HammingDistance(a, b uint64) int {
-	// XOR the two hashes to identify differing bits
-	// (0 where bits match, 1 where they differ)
-	// Then count the number of 1s in the result
-	return bits.OnesCount64(a ^ b)
-}
-
 // loadIndex loads an index from a file
 // It reads and deserializes an Index structure from a binary file
 // Parameters:
@@ -215,6 +405,10 @@ func loadIndex(indexPath string) (*Index, error) {
 
 	// Decode the file contents into the Index struct
 	if err := decoder.Decode(&index); err != nil {
+		// Indexes written before wider fingerprints stored uint64 hashes
+		if legacy, legacyErr := loadLegacyIndex(file); legacyErr == nil {
+			return legacy, nil
+		}
 		return nil, fmt.Errorf("error decoding index: %w", err)
 		// Wraps decoding error with context
 	}
@@ -222,3 +416,23 @@ func loadIndex(indexPath string) (*Index, error) {
 	// Return pointer to loaded index
 	return &index, nil
 }
+
+// loadLegacyIndex decodes an index written with uint64 fingerprints
+// Parameters:
+//
+//	file: The open index file; it is read again from the start
+//
+// Returns:
+//
+//	*Index: The index converted to the current layout
+//	error: nil on success, error if the file is not a legacy index either
+func loadLegacyIndex(file *os.File) (*Index, error) {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	var legacy legacyIndex
+	if err := gob.NewDecoder(file).Decode(&legacy); err != nil {
+		return nil, err
+	}
+	return legacy.upgrade(), nil
+}
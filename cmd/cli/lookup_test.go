@@ -2,6 +2,7 @@ package main
 
 import (
 	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 )
@@ -9,8 +10,9 @@ import (
 func Test_lookupCommand(t *testing.T) {
 	type args struct {
 		indexFile string
-		queryHash uint64
+		queryHash Fingerprint
 	}
+	dir := setupTestData(t)
 	tests := []struct {
 		name    string
 		args    args
@@ -20,31 +22,31 @@ func Test_lookupCommand(t *testing.T) {
 			name: "index file not found",
 			args: args{
 				indexFile: "testdata/nonexistent.gob",
-				queryHash: 123,
+				queryHash: Fingerprint{123},
 			},
 			wantErr: true,
 		},
 		{
 			name: "invalid index file",
 			args: args{
-				indexFile: "testdata/invalid_index.gob",
-				queryHash: 123,
+				indexFile: filepath.Join(dir, "invalid_index.gob"),
+				queryHash: Fingerprint{123},
 			},
 			wantErr: true,
 		},
 		{
 			name: "no matches found",
 			args: args{
-				indexFile: "testdata/valid_index.gob",
-				queryHash: 456,
+				indexFile: filepath.Join(dir, "valid_index.gob"),
+				queryHash: Fingerprint{456},
 			},
 			wantErr: true,
 		},
 		{
 			name: "empty index file",
 			args: args{
-				indexFile: "testdata/empty.gob",
-				queryHash: 123,
+				indexFile: filepath.Join(dir, "empty.gob"),
+				queryHash: Fingerprint{123},
 			},
 			wantErr: true,
 		},
@@ -52,25 +54,23 @@ func Test_lookupCommand(t *testing.T) {
 			name: "empty index file path",
 			args: args{
 				indexFile: "",
-				queryHash: 123,
+				queryHash: Fingerprint{123},
 			},
 			wantErr: true,
 		},
 		{
 			name: "zero query hash",
 			args: args{
-				indexFile: "testdata/valid_index.gob",
-				queryHash: 0,
+				indexFile: filepath.Join(dir, "valid_index.gob"),
+				queryHash: Fingerprint{},
 			},
 			wantErr: true,
 		},
 	}
 
-	setupTestData(t) // Setup test data
-
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if err := lookupCommand(tt.args.indexFile, tt.args.queryHash); (err != nil) != tt.wantErr {
+			if err := lookupCommand(tt.args.indexFile, tt.args.queryHash, LookupOptions{}); (err != nil) != tt.wantErr {
 				t.Errorf("lookupCommand() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
@@ -79,20 +79,21 @@ func Test_lookupCommand(t *testing.T) {
 
 func TestHammingDistance(t *testing.T) {
 	tests := []struct {
-		a, b   uint64
+		a, b   Fingerprint
 		expect int
 	}{
-		{0b0000, 0b0000, 0}, // No difference
-		{0b0000, 0b1111, 4}, // All bits different
-		{0b1010, 0b0101, 4}, // Completely inverted
-		{0b1100, 0b1010, 2}, // Three bits differ
-		{0b1111, 0b0111, 1}, // One bit differ
+		{Fingerprint{0b0000}, Fingerprint{0b0000}, 0},         // No difference
+		{Fingerprint{0b0000}, Fingerprint{0b1111}, 4},         // All bits different
+		{Fingerprint{0b1010}, Fingerprint{0b0101}, 4},         // Completely inverted
+		{Fingerprint{0b1100}, Fingerprint{0b1010}, 2},         // Three bits differ
+		{Fingerprint{0b1111}, Fingerprint{0b0111}, 1},         // One bit differ
+		{Fingerprint{1, 0, 0, 1}, Fingerprint{0, 1, 1, 1}, 3}, // Bits in every word count
 	}
 
 	for _, tt := range tests {
 		result := HammingDistance(tt.a, tt.b)
 		if result != tt.expect {
-			t.Errorf("HammingDistance(%b, %b) = %d; want %d", tt.a, tt.b, result, tt.expect)
+			t.Errorf("HammingDistance(%s, %s) = %d; want %d", tt.a, tt.b, result, tt.expect)
 		}
 	}
 }
@@ -100,7 +101,7 @@ func TestHammingDistance(t *testing.T) {
 func Test_lookupQuery(t *testing.T) {
 	type args struct {
 		index     *Index
-		queryHash uint64
+		queryHash Fingerprint
 	}
 	tests := []struct {
 		name    string
@@ -112,10 +113,10 @@ func Test_lookupQuery(t *testing.T) {
 			name: "exact match found",
 			args: args{
 				index: &Index{
-					HashToChunks: map[uint64][]int{100: {0, 1}},
+					HashToChunks: map[Fingerprint][]int{{100}: {0, 1}},
 					Chunks:       []ChunkInfo{{Offset: 10, Size: 100}, {Offset: 110, Size: 200}},
 				},
-				queryHash: 100,
+				queryHash: Fingerprint{100},
 			},
 			want:    []ChunkInfo{{Offset: 10, Size: 100}, {Offset: 110, Size: 200}},
 			wantErr: false,
@@ -124,10 +125,10 @@ func Test_lookupQuery(t *testing.T) {
 			name: "no exact match, fuzzy match found",
 			args: args{
 				index: &Index{
-					HashToChunks: map[uint64][]int{110: {2}},
+					HashToChunks: map[Fingerprint][]int{{110}: {2}},
 					Chunks:       []ChunkInfo{{Offset: 10, Size: 100}, {Offset: 110, Size: 200}, {Offset: 210, Size: 300}},
 				},
-				queryHash: 112, // Hamming distance 2
+				queryHash: Fingerprint{112}, // Hamming distance 2
 			},
 			want:    []ChunkInfo{{Offset: 210, Size: 300}},
 			wantErr: false,
@@ -135,8 +136,8 @@ func Test_lookupQuery(t *testing.T) {
 		{
 			name: "empty index",
 			args: args{
-				index:     &Index{HashToChunks: map[uint64][]int{}, Chunks: []ChunkInfo{}},
-				queryHash: 100,
+				index:     &Index{HashToChunks: map[Fingerprint][]int{}, Chunks: []ChunkInfo{}},
+				queryHash: Fingerprint{100},
 			},
 			want:    []ChunkInfo{},
 			wantErr: false,
@@ -145,10 +146,10 @@ func Test_lookupQuery(t *testing.T) {
 			name: "fuzzy match multiple chunks",
 			args: args{
 				index: &Index{
-					HashToChunks: map[uint64][]int{110: {2, 3}},
+					HashToChunks: map[Fingerprint][]int{{110}: {2, 3}},
 					Chunks:       []ChunkInfo{{Offset: 10, Size: 100}, {Offset: 110, Size: 200}, {Offset: 210, Size: 300}, {Offset: 310, Size: 400}},
 				},
-				queryHash: 112, // Hamming distance 2
+				queryHash: Fingerprint{112}, // Hamming distance 2
 			},
 			want:    []ChunkInfo{{Offset: 210, Size: 300}, {Offset: 310, Size: 400}},
 			wantErr: false,
@@ -213,6 +214,7 @@ func Test_loadIndex(t *testing.T) {
 	type args struct {
 		indexPath string
 	}
+	dir := setupTestData(t)
 	tests := []struct {
 		name    string
 		args    args
@@ -221,13 +223,25 @@ func Test_loadIndex(t *testing.T) {
 	}{
 		{
 			name:    "valid index file",
+			args:    args{indexPath: filepath.Join(dir, "valid_index.gob")},
+			want:    &Index{HashToChunks: map[Fingerprint][]int{{123}: {0, 1}}, Chunks: []ChunkInfo{{Offset: 10, Size: 100}, {Offset: 110, Size: 200}}},
+			wantErr: false,
+		},
+		{
+			name:    "checked-in index",
 			args:    args{indexPath: "testdata/valid_index.gob"},
-			want:    &Index{HashToChunks: map[uint64][]int{123: {0, 1}}, Chunks: []ChunkInfo{{Offset: 10, Size: 100}, {Offset: 110, Size: 200}}},
+			want:    &Index{HashToChunks: map[Fingerprint][]int{{123}: {0, 1}}, Chunks: []ChunkInfo{{Offset: 10, Size: 100}, {Offset: 110, Size: 200}}},
+			wantErr: false,
+		},
+		{
+			name:    "index written with uint64 hashes",
+			args:    args{indexPath: "testdata/legacy_index.gob"},
+			want:    &Index{HashToChunks: map[Fingerprint][]int{{123}: {0, 1}}, Chunks: []ChunkInfo{{Offset: 10, Size: 100}, {Offset: 110, Size: 200}}},
 			wantErr: false,
 		},
 		{
 			name:    "invalid index file",
-			args:    args{indexPath: "testdata/invalid_index.gob"},
+			args:    args{indexPath: filepath.Join(dir, "invalid_index.gob")},
 			want:    nil,
 			wantErr: true,
 		},
@@ -239,15 +253,12 @@ func Test_loadIndex(t *testing.T) {
 		},
 		{
 			name:    "empty file",
-			args:    args{indexPath: "testdata/empty.gob"},
+			args:    args{indexPath: filepath.Join(dir, "empty.gob")},
 			want:    nil,
 			wantErr: true,
 		},
 	}
 
-	// Setup test data
-	setupTestData(t)
-
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			got, err := loadIndex(tt.args.indexPath)
@@ -12,7 +12,13 @@ import (
 type Argumnets struct {
 	command string
 	// command specifies the operation to perform
-	// Valid values: "index" (create index) or "lookup" (search index)
+	// Valid values: "index" (create index), "lookup" (search index by hash),
+	// "search" (rank chunks against a text query), "fuzzy" (typo-tolerant word search)
+	// "compare" (check a whole document against the index), "dupes" (list passages
+	// that occur more than once in the index), "dump" (export chunk records),
+	// "import" (build an index from exported chunk records), "stats" (report
+	// the contents and health of an index) or "verify" (check an index against
+	// its source files)
 
 	inputFile string
 	// inputFile is the path to the input file
@@ -24,6 +30,10 @@ type Argumnets struct {
 	// Stored as string from command line, converted to int during processing
 	// Default value: "4096" (4KB)
 
+	queryFile string
+	// queryFile is the path to a whole document to check against an index
+	// Used in "compare" command only
+
 	outputFile string
 	// outputFile is the path for the index file
 	// For "index": destination path where the generated index will be saved
@@ -33,6 +43,63 @@ type Argumnets struct {
 	// queryHash is the SimHash value to search for
 	// Used in "lookup" command only
 	// Expected to be a hexadecimal string representation
+
+	queryText string
+	// queryText is the free-text query
+	// Used in "search" command only
+
+	searchMode string
+	// searchMode selects how "search" ranks chunks
+	// Valid values: "bm25" (keyword only) or "hybrid" (BM25 blended with SimHash)
+
+	limit int
+	// limit is the maximum number of ranked results to display
+	// Used in "search" and "fuzzy" commands
+
+	editDistance int
+	// editDistance is the maximum number of edits allowed per query word
+	// Used in "fuzzy" command only
+
+	diffFormat string
+	// diffFormat selects word-level diff output for "lookup" and "compare"
+	// Valid values: "" (no diff), "text", "html" or "json"
+
+	before int
+	// before is the number of context lines printed before each lookup match
+
+	after int
+	// after is the number of context lines printed after each lookup match
+
+	commonThreshold float64
+	// commonThreshold suppresses fingerprints shared by more than this percentage of documents
+
+	weighted bool
+	// weighted builds TF-IDF weighted fingerprints when indexing
+
+	hashBits int
+	// hashBits is the fingerprint width chosen when indexing (64, 128 or 256)
+
+	ignoreQuotes bool
+	// ignoreQuotes leaves quotations in the query document out of "compare"
+
+	reportFormat string
+	// reportFormat exports "lookup", "compare" and "dupes" results as a report
+	// Valid values: "" (plain listing), "html", "markdown", "csv" or "json",
+	// and "sarif" for "dupes"
+	// For "dump" and "import" it is the chunk record format: "csv" or "ndjson"
+
+	reportOut string
+	// reportOut is the file the report or "dump" records are written to; empty writes to stdout
+
+	output string
+	// output selects machine-readable "lookup", "compare" and "stats" output
+	// Valid values: "" (plain listing), "json", "ndjson" or "tsv"
+
+	strict bool
+	// strict makes "lookup" refuse matches whose source text changed since indexing
+
+	minClone int64
+	// minClone is the smallest duplicated passage, in bytes, reported by "dupes"
 }
 
 // main is the entry point of the text indexing application.
@@ -43,11 +110,15 @@ func main() {
 	var args Argumnets
 
 	// Define command-line flags
-	flag.StringVar(&args.command, "c", "", "Command (index or lookup)")
-	// -c: Specifies the operation to perform (either "index" or "lookup")
+	flag.StringVar(&args.command, "c", "", "Command (index, lookup, search, fuzzy, compare, dupes, exclude, dump, import, stats or verify)")
+	// -c: Specifies the operation to perform ("index", "lookup", "search", "fuzzy", "compare", "dupes", "exclude", "dump", "import", "stats" or "verify")
+
+	flag.StringVar(&args.inputFile, "i", "", "Input file, comma-separated files or directory, or index file path")
+	// -i: Path to input text file(s) (for indexing) or index file (for lookup)
 
-	flag.StringVar(&args.inputFile, "i", "", "Input file or index file path")
-	// -i: Path to input text file (for indexing) or index file (for lookup)
+	flag.StringVar(&args.queryFile, "f", "", "Query document to compare against the index, or boilerplate files to exclude")
+	// -f: Path to the document checked by the compare command
+	// For exclude, comma-separated files or a directory of expected text
 
 	flag.StringVar(&args.chunkSize, "s", "4096", "Size of each chunk in bytes (default: 4096 bytes).")
 	// -s: Size of text chunks in bytes (defaults to 4096 if not specified)
@@ -58,6 +129,55 @@ func main() {
 	flag.StringVar(&args.queryHash, "h", "", "The SimHash value of the chunk to search for")
 	// -h: SimHash value to search for (used in lookup command)
 
+	flag.StringVar(&args.queryText, "q", "", "The text to search for")
+	// -q: Free-text query (used in search and fuzzy commands)
+	// In lookup it is fingerprinted when -h is omitted and used to highlight the match
+
+	flag.StringVar(&args.searchMode, "m", "bm25", "Search ranking mode (bm25 or hybrid)")
+	// -m: Ranking mode for search command
+
+	flag.IntVar(&args.limit, "n", 10, "Maximum number of search results")
+	// -n: Number of ranked results to display (used in search and fuzzy commands)
+
+	flag.IntVar(&args.editDistance, "d", 1, "Maximum edit distance per word for fuzzy search (1 or 2)")
+	// -d: Typo tolerance for fuzzy command
+
+	flag.StringVar(&args.diffFormat, "diff", "", "Show a word diff of the query against each match (text, html or json)")
+	// -diff: Diff output format for lookup (requires -q) and compare commands
+
+	flag.IntVar(&args.before, "B", 0, "Number of context lines to print before each lookup match")
+	// -B: Leading context lines, as in grep
+
+	flag.IntVar(&args.after, "A", 0, "Number of context lines to print after each lookup match")
+	// -A: Trailing context lines, as in grep
+
+	flag.Float64Var(&args.commonThreshold, "x", 0, "Suppress fingerprints shared by more than this percentage of documents (0 disables)")
+	// -x: Common-chunk threshold for the index command
+
+	flag.BoolVar(&args.weighted, "tfidf", false, "Weight fingerprint features by corpus TF-IDF when indexing")
+	// -tfidf: Two-pass weighted SimHash for the index command
+
+	flag.IntVar(&args.hashBits, "bits", 64, "Fingerprint width in bits when indexing (64, 128 or 256)")
+	// -bits: Wider fingerprints reduce collisions on very large corpora
+
+	flag.BoolVar(&args.ignoreQuotes, "noquotes", false, "Ignore text within quotation marks and block quotes of the query document")
+	// -noquotes: Quotation handling for the compare command
+
+	flag.StringVar(&args.reportFormat, "format", "", "Export lookup, compare or dupes results as a report (html, markdown, csv or json; sarif for dupes), or the dump/import record format (csv or ndjson)")
+	// -format: Report format; inferred from the -out extension when omitted
+
+	flag.StringVar(&args.reportOut, "out", "", "File to write the report to (default: stdout)")
+	// -out: Report destination for lookup, compare and dupes, and record destination for dump
+
+	flag.StringVar(&args.output, "output", "", "Machine-readable lookup, compare or stats output on stdout (json, ndjson or tsv)")
+	// -output: Stable fields for scripts; accepted as --output too
+
+	flag.BoolVar(&args.strict, "strict", false, "Refuse lookup matches whose source text changed since indexing")
+	// -strict: Without it, lookup only warns about changed chunks
+
+	flag.Int64Var(&args.minClone, "min", 0, "Smallest duplicated passage in bytes reported by dupes (0 reports all)")
+	// -min: Minimum clone size for the dupes command
+
 	// Parse all defined flags from command line
 	flag.Parse()
 
@@ -70,33 +190,126 @@ func main() {
 		return
 	}
 
+	// -format and -output both accept json, with different meanings
+	if err := checkOutputFlags(args.command, args.reportFormat, args.output); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
 	// Process the specified command
 	switch args.command {
 	case "index":
 		// Execute indexing operation
 		// Creates an index file from the input text file using specified chunk size
-		err = indexCommand(args.inputFile, chunkSize, args.outputFile)
+		err = indexCommand(args.inputFile, chunkSize, args.outputFile, IndexOptions{
+			CommonThreshold: args.commonThreshold,
+			Weighted:        args.weighted,
+			HashBits:        args.hashBits,
+		})
 
 	case "lookup":
-		// Convert query hash from hexadecimal string to uint64
-		numHash, errr := strconv.ParseUint(args.queryHash, 16, 64)
-		if errr != nil {
-			fmt.Println("Error: Invalid SimHash value")
-			fmt.Println("Ensure the file was indexed before looking up.")
-			return
+		// With only -q the hash stays 0 and lookup fingerprints the query text
+		// with the index's own weighting once the index is loaded
+		var numHash Fingerprint
+		if args.queryHash != "" || args.queryText == "" {
+			// Convert query hash from hexadecimal string to a fingerprint
+			var errr error
+			numHash, errr = parseFingerprint(args.queryHash)
+			if errr != nil {
+				fmt.Println("Error: Invalid SimHash value")
+				fmt.Println("Ensure the file was indexed before looking up.")
+				return
+			}
 		}
 		// Execute lookup operation using the provided hash
-		err = lookupCommand(args.inputFile, numHash)
+		err = lookupCommand(args.inputFile, numHash, LookupOptions{
+			QueryText:  args.queryText,
+			DiffFormat: args.diffFormat,
+			Before:     args.before,
+			After:      args.after,
+			Format:     args.reportFormat,
+			Out:        args.reportOut,
+			Output:     args.output,
+			Strict:     args.strict,
+		})
+
+	case "search":
+		// Execute keyword or hybrid search using the provided query text
+		err = searchCommand(args.inputFile, args.queryText, args.searchMode, args.limit)
+
+	case "fuzzy":
+		// Execute typo-tolerant word search using the provided query text
+		err = fuzzyCommand(args.inputFile, args.queryText, args.editDistance, args.limit)
+
+	case "compare":
+		// Align a whole query document with the index and score its originality
+		err = compareCommand(args.inputFile, args.queryFile, CompareOptions{
+			DiffFormat:   args.diffFormat,
+			IgnoreQuotes: args.ignoreQuotes,
+			Format:       args.reportFormat,
+			Out:          args.reportOut,
+			Output:       args.output,
+		})
+
+	case "dupes":
+		// List passages that occur more than once across the indexed documents
+		err = dupesCommand(args.inputFile, DupesOptions{
+			Format:  args.reportFormat,
+			Out:     args.reportOut,
+			MinSize: args.minClone,
+		})
+
+	case "exclude":
+		// Register boilerplate whose fingerprints are subtracted from all results
+		err = excludeCommand(args.inputFile, args.queryFile)
+
+	case "dump":
+		// Export every chunk record of the index
+		err = dumpCommand(args.inputFile, args.reportFormat, args.reportOut)
+
+	case "import":
+		// Build an index from chunk records computed elsewhere
+		err = importCommand(args.inputFile, args.reportFormat, args.outputFile, args.hashBits)
+
+	case "stats":
+		// Report what the index contains and whether it looks healthy
+		err = statsCommand(args.inputFile, args.output)
+
+	case "verify":
+		// Report documents and chunks that changed since indexing
+		err = verifyCommand(args.inputFile)
 
 	default:
 		// Display usage information if invalid or no command is provided
 		fmt.Println("TextIndex - Fast & Scalable Text Indexer")
 		fmt.Println("\nUsage:")
-		fmt.Println("  Index:  textindex -c index -i <input_file.txt> -s <chunk_size> -o <index_file.idx>")
-		fmt.Println("  Lookup: textindex -c lookup -i <index_file.idx> -h <simhash_value>")
+		fmt.Println("  Index:   textindex -c index -i <input_file.txt>|<file,file,...>|<dir> -s <chunk_size> -o <index_file.idx> [-x <percent>] [-tfidf] [-bits 64|128|256]")
+		fmt.Println("  Lookup:  textindex -c lookup -i <index_file.idx> -h <simhash_value> | -q <query_text> [-diff text|html|json] [-B <lines>] [-A <lines>] [-format html|markdown|csv|json] [-out <file>] [-output json|ndjson|tsv] [-strict]")
+		fmt.Println("  Search:  textindex -c search -i <index_file.idx> -q <query_text> [-m bm25|hybrid] [-n <limit>]")
+		fmt.Println("  Fuzzy:   textindex -c fuzzy -i <index_file.idx> -q <query_text> [-d 1|2] [-n <limit>]")
+		fmt.Println("  Compare: textindex -c compare -i <index_file.idx> -f <query_file.txt> [-diff text|html|json] [-noquotes] [-format html|markdown|csv|json] [-out <file>] [-output json|ndjson|tsv]")
+		fmt.Println("  Dupes:   textindex -c dupes -i <index_file.idx> [-min <bytes>] [-format html|markdown|csv|json|sarif] [-out <file>]")
+		fmt.Println("  Exclude: textindex -c exclude -i <index_file.idx> -f <boilerplate.txt>|<file,file,...>|<dir>")
+		fmt.Println("  Dump:    textindex -c dump -i <index_file.idx> [-format csv|ndjson] [-out <file>]")
+		fmt.Println("  Import:  textindex -c import -i <chunks.csv>|<chunks.ndjson> -o <index_file.idx> [-format csv|ndjson] [-bits 64|128|256]")
+		fmt.Println("  Stats:   textindex -c stats -i <index_file.idx> [-output json|ndjson|tsv]")
+		fmt.Println("  Verify:  textindex -c verify -i <index_file.idx>")
 		fmt.Println("\nExamples:")
 		fmt.Println("  textindex -c index -i jungle_book_by_kipling.txt -s 512 -o jungle_book.index")
 		fmt.Println("  textindex -c lookup -i jungle_book.index -h 8u9ryi3rujoef")
+		fmt.Println("  textindex -c lookup -i jungle_book.index -q \"law of the jungle\" --output ndjson")
+		fmt.Println("  textindex -c search -i jungle_book.index -q \"law of the jungle\" -m hybrid")
+		fmt.Println("  textindex -c fuzzy -i jungle_book.index -q \"mowgly\" -d 2")
+		fmt.Println("  textindex -c compare -i jungle_book.index -f essay.txt")
+		fmt.Println("  textindex -c compare -i jungle_book.index -f essay.txt -out essay_report.html")
+		fmt.Println("  textindex -c dupes -i essays.index -format csv")
+		fmt.Println("  textindex -c dupes -i src.index -min 512 -out clones.sarif")
+		fmt.Println("  textindex -c index -i essays/ -s 256 -o essays.index -x 50")
+		fmt.Println("  textindex -c exclude -i essays.index -f assignment_template.txt")
+		fmt.Println("  textindex -c stats -i jungle_book.index")
+		fmt.Println("  textindex -c verify -i jungle_book.index")
+		fmt.Println("  textindex -c dump -i jungle_book.index -out chunks.csv")
+		fmt.Println("  textindex -c import -i chunks.ndjson -o jungle_book.index")
 		return
 	}
 
@@ -1,5 +1,11 @@
 package main
 
+import (
+	"time"
+
+	"trufast/internal/textsearch"
+)
+
 // ChunkInfo holds information about a text chunk
 // It represents metadata for a single chunk of text from the indexed file
 type ChunkInfo struct {
@@ -13,17 +19,88 @@ type ChunkInfo struct {
 	// Typically matches the configured chunk size, except possibly for the last chunk
 	// Type int is sufficient as individual chunks are unlikely to exceed 2GB
 
-	Hash uint64
+	Hash Fingerprint
 	// Hash is the SimHash value calculated for this chunk
-	// Stored as uint64 to accommodate 64-bit hash values
+	// Stored as a Fingerprint to accommodate 64, 128 or 256-bit values (see Index.HashBits)
+	// Older indexes stored a uint64 here and are converted on load (see legacyIndex)
 	// Used for quick comparison and lookup operations
+
+	Terms int
+	// Terms is the number of word tokens found in the chunk
+	// Used as the document length when computing BM25 scores
+
+	Doc int
+	// Doc is the index of the document this chunk belongs to in Index.Documents
+	// Offset is relative to the start of that document
+
+	Suppressed bool
+	// Suppressed marks expected matches (boilerplate) that are left out of results
+	// Set for chunks matching a registered exclusion fingerprint or whose
+	// fingerprint occurs in more than Index.CommonThreshold percent of documents
+
+	Checksum uint32
+	// Checksum is the CRC-32 (IEEE) of the chunk's bytes at indexing time
+	// Used to detect chunks whose source text changed after indexing
+	// Only meaningful when the chunk's document has a Digest
+}
+
+// Document describes one indexed file
+type Document struct {
+	Path string
+	// Path is the path to the text file as given when indexing
+
+	Size int64
+	// Size is the length of the file in bytes at indexing time
+
+	LineOffsets []int64
+	// LineOffsets holds the byte offset at which each line of the file starts
+	// The first entry is always 0; entry i is the start of line i+1
+	// Used to translate byte offsets into line and column numbers
+
+	Hash Fingerprint
+	// Hash is the SimHash value of the whole document
+	// Used to find near-duplicate documents without comparing chunks
+
+	Sections []Section
+	// Sections groups the document's chunks into runs ending at paragraph breaks
+	// Used to skip runs of chunks that cannot match a fingerprint (see candidateChunks)
+	// Empty for indexes created before hierarchical fingerprints were stored
+
+	ModTime time.Time
+	// ModTime is the modification time of the file at indexing time
+
+	Digest string
+	// Digest is the hex SHA-256 of the file's content at indexing time
+	// Empty for indexes created before checksums were stored, which cannot be verified
 }
 
+// Section is a run of consecutive chunks of one document
+type Section struct {
+	FirstChunk int
+	// FirstChunk is the index of the section's first chunk in Index.Chunks
+
+	LastChunk int
+	// LastChunk is the index of the section's last chunk in Index.Chunks
+
+	Union Fingerprint
+	// Union has every bit set that is set in any chunk fingerprint of the section
+
+	Intersection Fingerprint
+	// Intersection has the bits set in every chunk fingerprint of the section
+	// Together with Union it bounds the distance from a fingerprint to the
+	// section's chunks (see sectionBound); both are zero in older indexes
+}
+
+// Posting records how often a term occurs in a single chunk
+// It is one entry of an inverted index posting list, shared with the web server
+type Posting = textsearch.Posting
+
 // Index represents the in-memory index of chunks
-// It maintains a complete index structure for a text file
+// It maintains a complete index structure for one or more text files
 type Index struct {
 	FilePath string
 	// FilePath is the path to the original text file that was indexed
+	// For a multi-document index it is the first document
 	// Useful for reference and potential file operations
 
 	ChunkSize int
@@ -31,15 +108,51 @@ type Index struct {
 	// Stored as int since it's provided by the user and validated
 	// Typically matches the -s flag value (default 4096)
 
+	HashBits int
+	// HashBits is the width of every fingerprint in the index: 64, 128 or 256
+	// Chosen at index time with the -bits flag; 0 in older indexes means 64
+
 	Chunks []ChunkInfo
 	// Chunks is a slice containing all chunk metadata
-	// Each element describes one chunk of the original file
-	// Ordered by position in the file
+	// Each element describes one chunk of an indexed file
+	// Ordered by document, then by position in the file
 
-	HashToChunks map[uint64][]int
+	HashToChunks map[Fingerprint][]int
 	// HashToChunks maps SimHash values to slice of chunk indices
 	// Key: Hash value of a chunk
 	// Value: Slice of indices into the Chunks array
 	// Enables fast lookup of chunks by their hash value
 	// Multiple chunks may share the same hash (hence the slice)
+
+	Postings map[string][]Posting
+	// Postings is an inverted index from lowercase terms to the chunks containing them
+	// Key: Term as produced by textsearch.Tokenize
+	// Value: Posting list ordered by chunk index
+	// Enables keyword (BM25) search alongside SimHash lookups
+
+	Vocabulary []string
+	// Vocabulary lists every distinct term seen while indexing, sorted alphabetically
+	// Used to resolve misspelled query words to indexed terms
+
+	Documents []Document
+	// Documents lists the indexed files in the order given when indexing
+	// Empty for indexes created before multi-document support; see indexDocument
+
+	Excluded []Fingerprint
+	// Excluded holds fingerprints of registered boilerplate (licence headers,
+	// templates, front matter); chunks near any of them are suppressed
+
+	CommonThreshold float64
+	// CommonThreshold is the percentage of documents above which a shared
+	// fingerprint is treated as boilerplate and suppressed (0 disables it)
+
+	IDF map[string]float64
+	// IDF maps terms to their inverse document frequency across all chunks
+	// Set by TF-IDF indexing; chunk hashes are then weighted SimHash values and
+	// query text must be fingerprinted with the same table (see queryFingerprint)
+	// Nil for indexes using plain, equally weighted SimHash
+
+	terms *textsearch.BKTree
+	// terms is a BK-tree over Vocabulary used to resolve misspelled query words
+	// It is not stored in the index file; see termTree
 }
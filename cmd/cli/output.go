@@ -0,0 +1,262 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Schema identifiers written in the "schema" field of -output json documents
+// They change only when a field is renamed or removed; new fields may be added
+const (
+	lookupOutputSchema  = "blitz.lookup.v1"
+	compareOutputSchema = "blitz.compare.v1"
+	statsOutputSchema   = "blitz.stats.v1"
+)
+
+// Hit is one match in machine-readable output
+// The JSON names are the stable field names of the output schema and
+// double as the TSV column headers
+type Hit struct {
+	Source string `json:"source"`
+	// Source is the path of the indexed document holding the match
+
+	Offset int64 `json:"offset"`
+	// Offset is the byte offset where the match begins
+
+	Size int64 `json:"size"`
+	// Size is the length of the match in bytes
+
+	Line int `json:"line"`
+	// Line is the 1-based line the match starts on
+
+	Column int `json:"column"`
+	// Column is the 1-based byte column the match starts at
+
+	Hash string `json:"hash"`
+	// Hash is the hexadecimal fingerprint of the chunk the match starts in
+
+	Distance float64 `json:"distance"`
+	// Distance is the Hamming distance between the query and the match
+
+	Content string `json:"content"`
+	// Content is the matched text
+}
+
+// CompareHit is one aligned region of a compare run in machine-readable output
+type CompareHit struct {
+	Hit
+
+	QueryOffset int64 `json:"query_offset"`
+	// QueryOffset is the byte offset of the aligned text in the query document
+
+	QuerySize int64 `json:"query_size"`
+	// QuerySize is the length of the aligned text in the query document
+
+	QueryLine int `json:"query_line"`
+	// QueryLine is the line of the query document the aligned text starts on
+
+	Chunks int `json:"chunks"`
+	// Chunks is the number of aligned chunk pairs in the region
+}
+
+// hitHeader is the TSV header of lookup output
+var hitHeader = []string{"source", "offset", "size", "line", "column", "hash", "distance", "content"}
+
+// compareHitHeader is the TSV header of compare output
+var compareHitHeader = append(append([]string{}, hitHeader...), "query_offset", "query_size", "query_line", "chunks")
+
+// tsvRow returns the TSV fields of a hit in hitHeader order
+func (h Hit) tsvRow() []string {
+	return []string{
+		h.Source,
+		strconv.FormatInt(h.Offset, 10),
+		strconv.FormatInt(h.Size, 10),
+		strconv.Itoa(h.Line),
+		strconv.Itoa(h.Column),
+		h.Hash,
+		strconv.FormatFloat(h.Distance, 'f', -1, 64),
+		h.Content,
+	}
+}
+
+// tsvRow returns the TSV fields of a compare hit in compareHitHeader order
+func (h CompareHit) tsvRow() []string {
+	return append(h.Hit.tsvRow(),
+		strconv.FormatInt(h.QueryOffset, 10),
+		strconv.FormatInt(h.QuerySize, 10),
+		strconv.Itoa(h.QueryLine),
+		strconv.Itoa(h.Chunks),
+	)
+}
+
+// tsvRecord is a hit that can be written as a TSV row
+type tsvRecord interface {
+	tsvRow() []string
+}
+
+// validateOutputFormat ensures the requested machine-readable output is supported
+// An empty format keeps the plain listing
+func validateOutputFormat(format string) error {
+	switch format {
+	case "", "json", "ndjson", "tsv":
+		return nil
+	}
+	return fmt.Errorf("unknown output format %q (expected json, ndjson or tsv)", format)
+}
+
+// checkOutputFlags rejects -output and -format combinations that would mean two things at once
+// -format json (or an -out file ending in .json) writes a report document, while -output json
+// writes hits for scripts on stdout, so only one of them may be given
+// Parameters:
+//
+//	command: The command being run
+//	format: The -format flag, or the report format implied by -out
+//	output: The -output flag
+//
+// Returns:
+//
+//	error: nil if the flags agree, error naming the conflict otherwise
+func checkOutputFlags(command string, format string, output string) error {
+	switch {
+	case output != "" && format != "":
+		return fmt.Errorf("error: -output %s cannot be combined with a report (-format %s or -out); -output prints hits for scripts on stdout, -format writes a report document", output, format)
+	case output != "" && command != "lookup" && command != "compare" && command != "stats":
+		return fmt.Errorf("error: -output only applies to lookup, compare and stats; use -format with %s", command)
+	case format != "" && command == "stats":
+		return fmt.Errorf("error: stats has no report format; use -output %s", format)
+	}
+	return nil
+}
+
+// tsvEscape makes a field safe for a TSV cell
+// Backslashes, tabs, carriage returns and newlines are written as \\, \t, \r and \n
+var tsvEscape = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\r", `\r`, "\n", `\n`)
+
+// writeHits writes hits as machine-readable output
+// Parameters:
+//
+//	w: Destination of the output
+//	format: "json" (one document with a schema and a hits array),
+//	        "ndjson" (one hit per line) or "tsv" (a header row then one hit per row)
+//	schema: Schema identifier written in json documents
+//	header: TSV column names
+//	hits: The hits to write
+//
+// Returns:
+//
+//	error: nil on success, error if writing fails
+func writeHits[T tsvRecord](w io.Writer, format string, schema string, header []string, hits []T) error {
+	var err error
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if hits == nil {
+			hits = []T{}
+		}
+		err = encoder.Encode(struct {
+			Schema string `json:"schema"`
+			Hits   []T    `json:"hits"`
+		}{schema, hits})
+	case "ndjson":
+		encoder := json.NewEncoder(w)
+		for _, hit := range hits {
+			if err = encoder.Encode(hit); err != nil {
+				break
+			}
+		}
+	default:
+		var sb strings.Builder
+		sb.WriteString(strings.Join(header, "\t") + "\n")
+		for _, hit := range hits {
+			row := hit.tsvRow()
+			for i := range row {
+				row[i] = tsvEscape.Replace(row[i])
+			}
+			sb.WriteString(strings.Join(row, "\t") + "\n")
+		}
+		_, err = io.WriteString(w, sb.String())
+	}
+	if err != nil {
+		return fmt.Errorf("error writing output: %w", err)
+	}
+	return nil
+}
+
+// lookupHits describes the chunks returned by a lookup
+// Parameters:
+//
+//	index: Pointer to the loaded Index structure
+//	queryHash: The fingerprint that was looked up
+//	chunks: Chunks returned by the lookup
+//
+// Returns:
+//
+//	[]Hit: One hit per chunk, in the same order
+//	error: nil on success, error if reading a chunk fails
+func lookupHits(index *Index, queryHash Fingerprint, chunks []ChunkInfo) ([]Hit, error) {
+	hits := make([]Hit, 0, len(chunks))
+	for _, chunk := range chunks {
+		doc := chunkDocument(index, chunk)
+		content, err := getChunkContent(doc.Path, chunk.Offset, chunk.Size)
+		if err != nil {
+			return nil, err
+		}
+		line, column := lineColumn(doc, chunk.Offset)
+		hits = append(hits, Hit{
+			Source:   doc.Path,
+			Offset:   chunk.Offset,
+			Size:     int64(chunk.Size),
+			Line:     line,
+			Column:   column,
+			Hash:     chunk.Hash.String(),
+			Distance: float64(HammingDistance(queryHash, chunk.Hash)),
+			Content:  content,
+		})
+	}
+	return hits, nil
+}
+
+// compareHits describes the aligned regions of a compare run
+// Parameters:
+//
+//	index: Pointer to the loaded Index structure
+//	comparison: The outcome of compareDocument
+//
+// Returns:
+//
+//	[]CompareHit: One hit per region, in query order
+//	error: nil on success, error if reading a passage fails
+func compareHits(index *Index, comparison *Comparison) ([]CompareHit, error) {
+	queryDoc := indexDocument(comparison.Query, 0)
+	hits := make([]CompareHit, 0, len(comparison.Regions))
+	for _, region := range comparison.Regions {
+		doc := indexDocument(index, region.SourceDoc)
+		content, err := getChunkContent(doc.Path, region.SourceStart, int(region.SourceEnd-region.SourceStart))
+		if err != nil {
+			return nil, err
+		}
+		line, column := lineColumn(doc, region.SourceStart)
+		queryLine, _ := lineColumn(queryDoc, region.QueryStart)
+		hits = append(hits, CompareHit{
+			Hit: Hit{
+				Source:   doc.Path,
+				Offset:   region.SourceStart,
+				Size:     region.SourceEnd - region.SourceStart,
+				Line:     line,
+				Column:   column,
+				Hash:     index.Chunks[chunkAt(index, region.SourceDoc, region.SourceStart)].Hash.String(),
+				Distance: region.Distance,
+				Content:  content,
+			},
+			QueryOffset: region.QueryStart,
+			QuerySize:   region.QueryEnd - region.QueryStart,
+			QueryLine:   queryLine,
+			Chunks:      region.Chunks,
+		})
+	}
+	return hits, nil
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestWriteHits(t *testing.T) {
+	index, err := createIndex("../../resources/original.txt", 64)
+	if err != nil {
+		t.Fatalf("createIndex failed: %v", err)
+	}
+	chunk := index.Chunks[0]
+	hits, err := lookupHits(index, chunk.Hash, []ChunkInfo{chunk})
+	if err != nil {
+		t.Fatalf("lookupHits failed: %v", err)
+	}
+	if len(hits) != 1 || hits[0].Source != "../../resources/original.txt" || hits[0].Offset != 0 || hits[0].Size != 64 ||
+		hits[0].Line != 1 || hits[0].Column != 1 || hits[0].Hash != chunk.Hash.String() || hits[0].Distance != 0 || len(hits[0].Content) != 64 {
+		t.Fatalf("Unexpected hits %+v", hits)
+	}
+
+	// JSON wraps the hits with the schema name
+	var buf bytes.Buffer
+	if err := writeHits(&buf, "json", lookupOutputSchema, hitHeader, hits); err != nil {
+		t.Fatalf("writeHits failed: %v", err)
+	}
+	var document struct {
+		Schema string           `json:"schema"`
+		Hits   []map[string]any `json:"hits"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &document); err != nil || document.Schema != "blitz.lookup.v1" || len(document.Hits) != 1 {
+		t.Fatalf("Unexpected JSON output (%v):\n%s", err, buf.String())
+	}
+	for _, field := range hitHeader {
+		if _, ok := document.Hits[0][field]; !ok {
+			t.Errorf("JSON hit is missing field %q", field)
+		}
+	}
+
+	// NDJSON writes one object per line and nothing for no hits
+	buf.Reset()
+	if err := writeHits(&buf, "ndjson", lookupOutputSchema, hitHeader, append(hits, hits...)); err != nil {
+		t.Fatalf("writeHits failed: %v", err)
+	}
+	if lines := strings.Split(strings.TrimSpace(buf.String()), "\n"); len(lines) != 2 || !json.Valid([]byte(lines[1])) {
+		t.Errorf("Unexpected NDJSON output:\n%s", buf.String())
+	}
+	buf.Reset()
+	writeHits(&buf, "ndjson", lookupOutputSchema, hitHeader, []Hit{})
+	if buf.Len() != 0 {
+		t.Errorf("Expected no NDJSON output without hits, got %q", buf.String())
+	}
+
+	// TSV escapes tabs and newlines so each hit stays on one row
+	buf.Reset()
+	hits[0].Content = "a\tb\nc\\d"
+	if err := writeHits(&buf, "tsv", lookupOutputSchema, hitHeader, hits); err != nil {
+		t.Fatalf("writeHits failed: %v", err)
+	}
+	rows := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(rows) != 2 || rows[0] != strings.Join(hitHeader, "\t") || !strings.HasSuffix(rows[1], `a\tb\nc\\d`) || len(strings.Split(rows[1], "\t")) != len(hitHeader) {
+		t.Errorf("Unexpected TSV output:\n%s", buf.String())
+	}
+
+	if err := validateOutputFormat("xml"); err == nil {
+		t.Errorf("Expected an error for an unknown output format")
+	}
+}
+
+func TestCompareHits(t *testing.T) {
+	index, err := createIndex("../../resources/original.txt", 64)
+	if err != nil {
+		t.Fatalf("createIndex failed: %v", err)
+	}
+	comparison, err := compareDocument(index, "../../resources/plagirized.txt", false)
+	if err != nil {
+		t.Fatalf("compareDocument failed: %v", err)
+	}
+	hits, err := compareHits(index, comparison)
+	if err != nil {
+		t.Fatalf("compareHits failed: %v", err)
+	}
+	if len(hits) != 1 || hits[0].QueryOffset != 0 || hits[0].QuerySize != comparison.TotalBytes || hits[0].QueryLine != 1 || hits[0].Hash != index.Chunks[0].Hash.String() {
+		t.Fatalf("Unexpected hits %+v", hits)
+	}
+
+	var buf bytes.Buffer
+	if err := writeHits(&buf, "tsv", compareOutputSchema, compareHitHeader, hits); err != nil {
+		t.Fatalf("writeHits failed: %v", err)
+	}
+	rows := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(rows) != 2 || len(strings.Split(rows[1], "\t")) != len(compareHitHeader) {
+		t.Errorf("Unexpected TSV output:\n%s", buf.String())
+	}
+}
+
+func TestCheckOutputFlags(t *testing.T) {
+	tests := []struct {
+		command string
+		format  string
+		output  string
+		wantErr bool
+	}{
+		{"lookup", "json", "", false},
+		{"lookup", "", "json", false},
+		{"lookup", "json", "json", true},
+		{"compare", "html", "tsv", true},
+		{"stats", "", "tsv", false},
+		{"stats", "json", "", true},
+		{"dupes", "sarif", "", false},
+		{"dupes", "", "json", true},
+		{"dump", "ndjson", "ndjson", true},
+	}
+	for _, tt := range tests {
+		err := checkOutputFlags(tt.command, tt.format, tt.output)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("checkOutputFlags(%q, %q, %q) error = %v, wantErr %v", tt.command, tt.format, tt.output, err, tt.wantErr)
+		}
+	}
+}
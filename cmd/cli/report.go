@@ -0,0 +1,356 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"trufast/internal/reporting"
+)
+
+// Report is the outcome of a lookup, compare or dupes run in a form that can be exported
+type Report struct {
+	Title string `json:"title"`
+	// Title is the heading of the report
+
+	Command string `json:"command"`
+	// Command is the command that produced the report ("lookup", "compare" or "dupes")
+
+	Index string `json:"index"`
+	// Index is the path of the index file that was queried
+
+	Query string `json:"query,omitempty"`
+	// Query is the query text or query document, if any
+
+	Generated time.Time `json:"generated"`
+	// Generated is the time the report was created
+
+	Summary []ReportStat `json:"summary"`
+	// Summary holds the headline figures, in display order
+
+	Regions []ReportRegion `json:"regions"`
+	// Regions lists every matched passage
+}
+
+// ReportStat is one headline figure of a report
+type ReportStat struct {
+	Name string `json:"name"`
+	// Name describes the figure
+
+	Value string `json:"value"`
+	// Value is the figure, already formatted
+}
+
+// ReportRegion is one matched passage of an indexed document
+type ReportRegion struct {
+	Group int `json:"group"`
+	// Group numbers the match the passage belongs to: the lookup result, the
+	// compare region or the clone group, starting at 1
+
+	Path string `json:"path"`
+	// Path is the indexed document holding the passage
+
+	Start int64 `json:"start"`
+	// Start is the byte offset where the passage begins
+
+	End int64 `json:"end"`
+	// End is the byte offset just past the passage
+
+	Line int `json:"line"`
+	// Line is the 1-based line the passage starts on
+
+	Column int `json:"column"`
+	// Column is the 1-based byte column the passage starts at
+
+	EndLine int `json:"end_line"`
+	// EndLine is the line holding the last byte of the passage
+
+	MatchPath string `json:"match_path,omitempty"`
+	// MatchPath is the query document aligned with the passage (compare only)
+
+	MatchStart int64 `json:"match_start,omitempty"`
+	// MatchStart is the byte offset of the aligned text in the query document
+
+	MatchEnd int64 `json:"match_end,omitempty"`
+	// MatchEnd is the byte offset just past the aligned text in the query document
+
+	MatchLine int `json:"match_line,omitempty"`
+	// MatchLine is the line of the query document the aligned text starts on
+
+	Distance float64 `json:"distance"`
+	// Distance is the Hamming distance between the fingerprints of the match
+
+	Text string `json:"text"`
+	// Text is the matched passage
+}
+
+// validateReportFormat ensures the requested report format is supported
+// An empty format keeps the plain listing
+func validateReportFormat(format string) error {
+	switch format {
+	case "", "html", "markdown", "csv", "json":
+		return nil
+	}
+	return fmt.Errorf("unknown report format %q (expected html, markdown, csv or json)", format)
+}
+
+// reportFormat resolves the -format and -out flags
+// Without -format, the format is taken from the extension of the -out file
+// Parameters:
+//
+//	format: Value of the -format flag
+//	out: Value of the -out flag
+//
+// Returns:
+//
+//	string: The report format, or "" for the plain listing
+//	error: nil on success, error if the format is unknown or cannot be inferred
+func reportFormat(format string, out string) (string, error) {
+	if format == "" && out != "" {
+		switch strings.ToLower(filepath.Ext(out)) {
+		case ".html", ".htm":
+			format = "html"
+		case ".md", ".markdown":
+			format = "markdown"
+		case ".csv":
+			format = "csv"
+		case ".json":
+			format = "json"
+		default:
+			return "", fmt.Errorf("error: cannot tell the report format of %q, use -format", out)
+		}
+	}
+	if err := validateReportFormat(format); err != nil {
+		return "", err
+	}
+	return format, nil
+}
+
+// writeReport renders a report and writes it to a file or stdout
+// Parameters:
+//
+//	report: The report to write
+//	format: "html", "markdown", "csv" or "json"
+//	out: Destination path; empty writes to stdout
+//
+// Returns:
+//
+//	error: nil on success, error if rendering or writing fails
+func writeReport(report *Report, format string, out string) error {
+	var w io.Writer = os.Stdout
+	var file *os.File
+	if out != "" {
+		var err error
+		file, err = os.Create(out)
+		if err != nil {
+			return fmt.Errorf("error creating report file: %w", err)
+		}
+		w = file
+	}
+
+	var err error
+	switch format {
+	case "html", "markdown":
+		err = reporting.Write(w, format, report.displayTable())
+	case "csv":
+		err = reporting.Write(w, format, report.csvTable())
+	default:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(report)
+	}
+	if file != nil {
+		if closeErr := file.Close(); err == nil && closeErr != nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("error writing report: %w", err)
+	}
+	if out != "" {
+		fmt.Printf("Report saved to %s\n", out)
+	}
+	return nil
+}
+
+// displayTable lays out a report for reading, as an HTML page or Markdown summary
+func (report *Report) displayTable() reporting.Table {
+	table := reporting.Table{
+		Title:     report.Title,
+		Generated: report.Generated,
+		Summary:   [][2]string{{"Index", report.Index}},
+		Columns:   []string{"#", "Document", "Lines", "Bytes"},
+	}
+	if report.Query != "" {
+		table.Summary = append(table.Summary, [2]string{"Query", report.Query})
+	}
+	for _, stat := range report.Summary {
+		table.Summary = append(table.Summary, [2]string{stat.Name, stat.Value})
+	}
+	compare := report.Command == "compare"
+	if compare {
+		table.Columns = append(table.Columns, "Query bytes")
+	}
+	table.Columns = append(table.Columns, "Distance", "Text")
+
+	for _, region := range report.Regions {
+		row := []string{
+			strconv.Itoa(region.Group),
+			region.Path,
+			fmt.Sprintf("%d-%d", region.Line, region.EndLine),
+			fmt.Sprintf("%d-%d", region.Start, region.End),
+		}
+		if compare {
+			row = append(row, fmt.Sprintf("%d-%d (line %d)", region.MatchStart, region.MatchEnd, region.MatchLine))
+		}
+		table.Rows = append(table.Rows, append(row, strconv.FormatFloat(region.Distance, 'f', 1, 64), region.Text))
+	}
+	return table
+}
+
+// csvTable lays out a report for spreadsheets and scripts: one row per matched
+// region with every field of ReportRegion in its own column
+func (report *Report) csvTable() reporting.Table {
+	table := reporting.Table{
+		Columns: []string{"group", "path", "start", "end", "line", "column", "end_line", "match_path", "match_start", "match_end", "match_line", "distance", "text"},
+	}
+	for _, region := range report.Regions {
+		table.Rows = append(table.Rows, []string{
+			strconv.Itoa(region.Group),
+			region.Path,
+			strconv.FormatInt(region.Start, 10),
+			strconv.FormatInt(region.End, 10),
+			strconv.Itoa(region.Line),
+			strconv.Itoa(region.Column),
+			strconv.Itoa(region.EndLine),
+			region.MatchPath,
+			strconv.FormatInt(region.MatchStart, 10),
+			strconv.FormatInt(region.MatchEnd, 10),
+			strconv.Itoa(region.MatchLine),
+			strconv.FormatFloat(region.Distance, 'f', 1, 64),
+			region.Text,
+		})
+	}
+	return table
+}
+
+// reportRegion describes a passage of an indexed document for a report
+// The text of the passage is read from the document
+func reportRegion(index *Index, group int, doc int, start, end int64, distance float64) (ReportRegion, error) {
+	document := indexDocument(index, doc)
+	text, err := getChunkContent(document.Path, start, int(end-start))
+	if err != nil {
+		return ReportRegion{}, err
+	}
+	line, column := lineColumn(document, start)
+	endLine, _ := lineColumn(document, max(end-1, start))
+	return ReportRegion{
+		Group:    group,
+		Path:     document.Path,
+		Start:    start,
+		End:      end,
+		Line:     line,
+		Column:   column,
+		EndLine:  endLine,
+		Distance: distance,
+		Text:     text,
+	}, nil
+}
+
+// lookupReport builds the report of a lookup
+// When the query text is known, each region is the best aligned span instead of the whole chunk
+func lookupReport(index *Index, indexFile string, queryHash Fingerprint, chunks []ChunkInfo, queryText string) (*Report, error) {
+	report := &Report{
+		Title:     "Lookup report",
+		Command:   "lookup",
+		Index:     indexFile,
+		Query:     queryText,
+		Generated: time.Now(),
+		Summary: []ReportStat{
+			{"Fingerprint", queryHash.String()},
+			{"Matching chunks", strconv.Itoa(len(chunks))},
+			{"Chunk size", fmt.Sprintf("%d bytes", index.ChunkSize)},
+		},
+	}
+	for i, chunk := range chunks {
+		start, end := chunk.Offset, chunk.Offset+int64(chunk.Size)
+		if queryText != "" {
+			span, found, err := locateMatch(index, chunk, queryText)
+			if err != nil {
+				return nil, err
+			}
+			if found {
+				start, end = span.Start, span.End
+			}
+		}
+		region, err := reportRegion(index, i+1, chunk.Doc, start, end, float64(HammingDistance(queryHash, chunk.Hash)))
+		if err != nil {
+			return nil, err
+		}
+		report.Regions = append(report.Regions, region)
+	}
+	return report, nil
+}
+
+// compareReport builds the report of a compare run
+// Each region is the source passage, with the aligned query text recorded alongside
+func compareReport(index *Index, indexFile string, queryFile string, comparison *Comparison) (*Report, error) {
+	report := &Report{
+		Title:     "Comparison report",
+		Command:   "compare",
+		Index:     indexFile,
+		Query:     queryFile,
+		Generated: time.Now(),
+		Summary: []ReportStat{
+			{"Matched bytes", fmt.Sprintf("%d of %d", comparison.MatchedBytes, comparison.TotalBytes)},
+			{"Regions", strconv.Itoa(len(comparison.Regions))},
+			{"Originality", fmt.Sprintf("%.1f%%", comparison.Originality)},
+		},
+	}
+	for _, duplicate := range comparison.Duplicates {
+		report.Summary = append(report.Summary, ReportStat{"Near-duplicate of", fmt.Sprintf("%s (document distance %d)", indexDocument(index, duplicate.Doc).Path, duplicate.Distance)})
+	}
+
+	queryDoc := indexDocument(comparison.Query, 0)
+	for i, match := range comparison.Regions {
+		region, err := reportRegion(index, i+1, match.SourceDoc, match.SourceStart, match.SourceEnd, match.Distance)
+		if err != nil {
+			return nil, err
+		}
+		region.MatchPath = queryFile
+		region.MatchStart, region.MatchEnd = match.QueryStart, match.QueryEnd
+		region.MatchLine, _ = lineColumn(queryDoc, match.QueryStart)
+		report.Regions = append(report.Regions, region)
+	}
+	return report, nil
+}
+
+// dupesReport builds the report of a dupes run, one region per occurrence
+func dupesReport(index *Index, indexFile string, groups []CloneGroup) (*Report, error) {
+	report := &Report{
+		Title:     "Duplicate passages report",
+		Command:   "dupes",
+		Index:     indexFile,
+		Generated: time.Now(),
+		Summary: []ReportStat{
+			{"Clone groups", strconv.Itoa(len(groups))},
+			{"Duplicated bytes", fmt.Sprintf("%d of %d", duplicatedBytes(groups), indexedBytes(index))},
+			{"Chunk size", fmt.Sprintf("%d bytes", index.ChunkSize)},
+		},
+	}
+	for i, group := range groups {
+		for _, member := range group.Members {
+			region, err := reportRegion(index, i+1, member.Doc, member.Start, member.End, float64(group.Distance))
+			if err != nil {
+				return nil, err
+			}
+			report.Regions = append(report.Regions, region)
+		}
+	}
+	return report, nil
+}
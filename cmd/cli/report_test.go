@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestReportFormat(t *testing.T) {
+	tests := []struct {
+		format, out string
+		want        string
+		wantErr     bool
+	}{
+		{"", "", "", false},
+		{"csv", "", "csv", false},
+		{"", "report.HTML", "html", false},
+		{"", "summary.md", "markdown", false},
+		{"json", "report.txt", "json", false},
+		{"", "report.txt", "", true},
+		{"xml", "", "", true},
+	}
+	for _, tt := range tests {
+		got, err := reportFormat(tt.format, tt.out)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("reportFormat(%q, %q) = %q, %v", tt.format, tt.out, got, err)
+		}
+	}
+}
+
+func TestCompareReport(t *testing.T) {
+	index, err := createIndex("../../resources/original.txt", 64)
+	if err != nil {
+		t.Fatalf("createIndex failed: %v", err)
+	}
+	comparison, err := compareDocument(index, "../../resources/plagirized.txt", false)
+	if err != nil {
+		t.Fatalf("compareDocument failed: %v", err)
+	}
+	report, err := compareReport(index, "original.idx", "../../resources/plagirized.txt", comparison)
+	if err != nil {
+		t.Fatalf("compareReport failed: %v", err)
+	}
+	if len(report.Regions) != 1 {
+		t.Fatalf("Expected one region, got %+v", report.Regions)
+	}
+	region := report.Regions[0]
+	if region.Group != 1 || region.Line != 1 || region.EndLine != 4 || region.MatchPath == "" || !strings.HasPrefix(region.Text, "The quick brown fox") {
+		t.Errorf("Unexpected region %+v", region)
+	}
+
+	out := "test_report"
+	defer os.Remove(out)
+	read := func(format string) string {
+		t.Helper()
+		if err := writeReport(report, format, out); err != nil {
+			t.Fatalf("writeReport(%s) failed: %v", format, err)
+		}
+		data, _ := os.ReadFile(out)
+		return string(data)
+	}
+
+	var decoded Report
+	if err := json.Unmarshal([]byte(read("json")), &decoded); err != nil || len(decoded.Regions) != 1 || decoded.Command != "compare" {
+		t.Errorf("JSON report = %+v, %v", decoded, err)
+	}
+
+	rows, err := csv.NewReader(strings.NewReader(read("csv"))).ReadAll()
+	if err != nil || len(rows) != 2 || rows[0][0] != "group" || rows[1][12] != region.Text {
+		t.Errorf("CSV report = %q, %v", rows, err)
+	}
+
+	markdown := read("markdown")
+	if !strings.Contains(markdown, "- **Originality:** 0.0%") || !strings.Contains(markdown, "| 1 | ../../resources/original.txt | 1-4 |") {
+		t.Errorf("Markdown report:\n%s", markdown)
+	}
+
+	// The HTML report stands alone and escapes document text
+	report.Regions[0].Text = "<script>alert(1)</script>"
+	page := read("html")
+	if !strings.Contains(page, "<style>") || strings.Contains(page, "<script>") || !strings.Contains(page, "&lt;script&gt;") {
+		t.Errorf("HTML report:\n%s", page)
+	}
+}
+
+func TestLookupReport(t *testing.T) {
+	index, err := createIndex("../../resources/original.txt", 64)
+	if err != nil {
+		t.Fatalf("createIndex failed: %v", err)
+	}
+	query := "The meeting was scheduled for noon, and everyone was expected to arrive on time."
+	hash := queryFingerprint(index, query)
+	chunks, _ := lookupQuery(index, hash)
+	report, err := lookupReport(index, "original.idx", hash, chunks, query)
+	if err != nil || len(report.Regions) == 0 {
+		t.Fatalf("lookupReport = %+v, %v", report, err)
+	}
+	if text := report.Regions[0].Text; !strings.HasPrefix(text, "The meeting was scheduled") || report.Regions[0].Line != 3 {
+		t.Errorf("Expected the aligned span as the region text, got %q", text)
+	}
+}
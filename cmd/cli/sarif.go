@@ -0,0 +1,258 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+// sarifVersion and sarifSchema identify the SARIF revision written by the dupes command
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+)
+
+// Rule IDs of the dupes findings
+const (
+	ruleExactClone = "blitz/exact-clone"
+	// ruleExactClone marks passages whose occurrences all have the same fingerprints
+
+	ruleNearClone = "blitz/near-clone"
+	// ruleNearClone marks passages whose occurrences differ within the Hamming threshold
+)
+
+// SarifLog is the root of a SARIF 2.1.0 document
+// Only the properties written by Blitz are modelled
+type SarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []SarifRun `json:"runs"`
+}
+
+// SarifRun is one run of the tool and the results it found
+type SarifRun struct {
+	Tool    SarifTool     `json:"tool"`
+	Results []SarifResult `json:"results"`
+}
+
+// SarifTool describes Blitz and the rules it reports
+type SarifTool struct {
+	Driver SarifDriver `json:"driver"`
+}
+
+// SarifDriver is the component of the tool that produced the results
+type SarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri,omitempty"`
+	Rules          []SarifRule `json:"rules"`
+}
+
+// SarifRule describes one kind of finding
+type SarifRule struct {
+	ID                   string           `json:"id"`
+	Name                 string           `json:"name"`
+	ShortDescription     SarifMessage     `json:"shortDescription"`
+	FullDescription      SarifMessage     `json:"fullDescription"`
+	DefaultConfiguration SarifRuleDefault `json:"defaultConfiguration"`
+}
+
+// SarifRuleDefault holds the severity a rule is reported with
+type SarifRuleDefault struct {
+	Level string `json:"level"`
+}
+
+// SarifMessage is a plain-text message
+type SarifMessage struct {
+	Text string `json:"text"`
+}
+
+// SarifResult is one finding: an occurrence of a clone group
+type SarifResult struct {
+	RuleID           string          `json:"ruleId"`
+	RuleIndex        int             `json:"ruleIndex"`
+	Level            string          `json:"level"`
+	Message          SarifMessage    `json:"message"`
+	Locations        []SarifLocation `json:"locations"`
+	RelatedLocations []SarifLocation `json:"relatedLocations,omitempty"`
+	// RelatedLocations are the other occurrences of the same clone group
+
+	PartialFingerprints map[string]string `json:"partialFingerprints,omitempty"`
+	// PartialFingerprints lets viewers track a finding across runs
+}
+
+// SarifLocation points at a region of a file
+type SarifLocation struct {
+	ID               int                   `json:"id,omitempty"`
+	PhysicalLocation SarifPhysicalLocation `json:"physicalLocation"`
+	Message          *SarifMessage         `json:"message,omitempty"`
+}
+
+// SarifPhysicalLocation is a file and a region inside it
+type SarifPhysicalLocation struct {
+	ArtifactLocation SarifArtifactLocation `json:"artifactLocation"`
+	Region           SarifRegion           `json:"region"`
+}
+
+// SarifArtifactLocation identifies a file by URI
+// Relative URIs are resolved against uriBaseId
+type SarifArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+// SarifRegion is a span of a file
+// Lines and columns are 1-based; EndColumn is just past the last byte
+type SarifRegion struct {
+	StartLine   int   `json:"startLine"`
+	StartColumn int   `json:"startColumn"`
+	EndLine     int   `json:"endLine"`
+	EndColumn   int   `json:"endColumn"`
+	ByteOffset  int64 `json:"byteOffset"`
+	ByteLength  int64 `json:"byteLength"`
+}
+
+// sarifRules lists the rules in the order their indices are reported
+var sarifRules = []SarifRule{
+	{
+		ID:                   ruleExactClone,
+		Name:                 "ExactClone",
+		ShortDescription:     SarifMessage{"Duplicated passage"},
+		FullDescription:      SarifMessage{"The passage occurs more than once in the indexed documents with identical fingerprints."},
+		DefaultConfiguration: SarifRuleDefault{"warning"},
+	},
+	{
+		ID:                   ruleNearClone,
+		Name:                 "NearClone",
+		ShortDescription:     SarifMessage{"Near-duplicated passage"},
+		FullDescription:      SarifMessage{"The passage occurs more than once in the indexed documents with fingerprints within the Hamming threshold of each other."},
+		DefaultConfiguration: SarifRuleDefault{"note"},
+	},
+}
+
+// dupesSARIF converts clone groups into a SARIF log
+// Every occurrence of a group is a result, with the other occurrences as related locations
+// Parameters:
+//
+//	index: Pointer to the loaded Index structure
+//	groups: Clone groups found in the index
+//
+// Returns:
+//
+//	*SarifLog: The log, holding a single run
+func dupesSARIF(index *Index, groups []CloneGroup) *SarifLog {
+	run := SarifRun{
+		Tool: SarifTool{Driver: SarifDriver{
+			Name:           "Blitz",
+			InformationURI: "https://github.com/benardopiyo/Blitz",
+			Rules:          sarifRules,
+		}},
+		Results: []SarifResult{},
+	}
+
+	for g, group := range groups {
+		ruleIndex := 0
+		if group.Distance > 0 {
+			ruleIndex = 1
+		}
+		rule := sarifRules[ruleIndex]
+
+		locations := make([]SarifLocation, len(group.Members))
+		for i, member := range group.Members {
+			locations[i] = sarifLocation(index, member)
+		}
+		for i, member := range group.Members {
+			var related []SarifLocation
+			for j, other := range locations {
+				if j == i {
+					continue
+				}
+				other.ID = j + 1
+				region := other.PhysicalLocation.Region
+				other.Message = &SarifMessage{fmt.Sprintf("Occurrence %d of clone group %d (line %d)", j+1, g+1, region.StartLine)}
+				related = append(related, other)
+			}
+			run.Results = append(run.Results, SarifResult{
+				RuleID:    rule.ID,
+				RuleIndex: ruleIndex,
+				Level:     rule.DefaultConfiguration.Level,
+				Message: SarifMessage{fmt.Sprintf("Passage of %d bytes is duplicated in %d other place(s) (clone group %d, max distance %d)",
+					member.End-member.Start, len(group.Members)-1, g+1, group.Distance)},
+				Locations:           []SarifLocation{locations[i]},
+				RelatedLocations:    related,
+				PartialFingerprints: map[string]string{"cloneGroupFingerprint/v1": index.Chunks[chunkAt(index, member.Doc, member.Start)].Hash.String()},
+			})
+		}
+	}
+
+	return &SarifLog{Schema: sarifSchema, Version: sarifVersion, Runs: []SarifRun{run}}
+}
+
+// sarifLocation describes one occurrence of a clone group
+func sarifLocation(index *Index, member CloneMember) SarifLocation {
+	doc := indexDocument(index, member.Doc)
+	line, column := lineColumn(doc, member.Start)
+	last := max(member.End-1, member.Start)
+	endLine, endColumn := lineColumn(doc, last)
+	return SarifLocation{PhysicalLocation: SarifPhysicalLocation{
+		ArtifactLocation: sarifArtifact(doc.Path),
+		Region: SarifRegion{
+			StartLine:   line,
+			StartColumn: column,
+			EndLine:     endLine,
+			EndColumn:   endColumn + 1,
+			ByteOffset:  member.Start,
+			ByteLength:  member.End - member.Start,
+		},
+	}}
+}
+
+// sarifArtifact turns an indexed path into a SARIF artifact location
+// Absolute paths become file URIs; relative paths stay relative to the source root
+func sarifArtifact(path string) SarifArtifactLocation {
+	slashed := filepath.ToSlash(path)
+	if filepath.IsAbs(path) {
+		return SarifArtifactLocation{URI: (&url.URL{Scheme: "file", Path: slashed}).String()}
+	}
+	return SarifArtifactLocation{URI: (&url.URL{Path: slashed}).String(), URIBaseID: "%SRCROOT%"}
+}
+
+// writeSARIF writes a SARIF log to a file or stdout
+// Parameters:
+//
+//	log: The log to write
+//	out: Destination path; empty writes to stdout
+//
+// Returns:
+//
+//	error: nil on success, error if writing fails
+func writeSARIF(log *SarifLog, out string) error {
+	var w io.Writer = os.Stdout
+	var file *os.File
+	if out != "" {
+		var err error
+		file, err = os.Create(out)
+		if err != nil {
+			return fmt.Errorf("error creating report file: %w", err)
+		}
+		w = file
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	err := encoder.Encode(log)
+	if file != nil {
+		if closeErr := file.Close(); err == nil && closeErr != nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("error writing report: %w", err)
+	}
+	if out != "" {
+		fmt.Printf("Report saved to %s\n", out)
+	}
+	return nil
+}
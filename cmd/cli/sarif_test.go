@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+func TestDupesSARIF(t *testing.T) {
+	index, err := createCorpusIndex([]string{"../../resources/original.txt", "../../resources/plagirized.txt"}, 64, defaultHashBits)
+	if err != nil {
+		t.Fatalf("createCorpusIndex failed: %v", err)
+	}
+	groups := findDuplicates(index)
+	if len(groups) == 0 {
+		t.Fatalf("Expected the copied passage to be found")
+	}
+
+	log := dupesSARIF(index, groups)
+	if log.Version != "2.1.0" || len(log.Runs) != 1 || len(log.Runs[0].Tool.Driver.Rules) != 2 {
+		t.Fatalf("Unexpected SARIF log %+v", log)
+	}
+	results := log.Runs[0].Results
+	members := 0
+	for _, group := range groups {
+		members += len(group.Members)
+	}
+	if len(results) != members {
+		t.Fatalf("Expected one result per occurrence (%d), got %d", members, len(results))
+	}
+	first := results[0]
+	if first.RuleID != ruleExactClone || len(first.Locations) != 1 || len(first.RelatedLocations) != len(groups[0].Members)-1 {
+		t.Errorf("Unexpected first result %+v", first)
+	}
+	location := first.Locations[0].PhysicalLocation
+	if location.ArtifactLocation.URI != "../../resources/original.txt" || location.ArtifactLocation.URIBaseID != "%SRCROOT%" {
+		t.Errorf("Unexpected artifact location %+v", location.ArtifactLocation)
+	}
+	if region := location.Region; region.StartLine != 1 || region.StartColumn != 1 || region.ByteOffset != 0 || region.ByteLength != 128 || region.EndLine < region.StartLine {
+		t.Errorf("Unexpected region %+v", region)
+	}
+	if related := first.RelatedLocations[0]; related.PhysicalLocation.ArtifactLocation.URI != "../../resources/plagirized.txt" || related.Message == nil {
+		t.Errorf("Unexpected related location %+v", related)
+	}
+
+	if uri := sarifArtifact("/srv/docs/a b.txt").URI; uri != "file:///srv/docs/a%20b.txt" {
+		t.Errorf("Unexpected URI for an absolute path: %s", uri)
+	}
+}
+
+func Test_dupesCommandSARIF(t *testing.T) {
+	indexFile := "test_dupes_sarif.idx"
+	defer os.Remove(indexFile)
+	if err := indexCommand("../../resources/original.txt,../../resources/plagirized.txt", 64, indexFile, IndexOptions{}); err != nil {
+		t.Fatalf("indexCommand failed: %v", err)
+	}
+
+	out := "test_dupes.sarif"
+	defer os.Remove(out)
+	if err := dupesCommand(indexFile, DupesOptions{Out: out}); err != nil {
+		t.Fatalf("dupesCommand failed: %v", err)
+	}
+	var log SarifLog
+	data, _ := os.ReadFile(out)
+	if err := json.Unmarshal(data, &log); err != nil || len(log.Runs) != 1 || len(log.Runs[0].Results) == 0 {
+		t.Fatalf("Unexpected SARIF output (%v):\n%s", err, data)
+	}
+
+	// A minimum size above every passage leaves an empty run
+	if err := dupesCommand(indexFile, DupesOptions{Format: "sarif", Out: out, MinSize: 1 << 20}); err != nil {
+		t.Fatalf("dupesCommand failed: %v", err)
+	}
+	data, _ = os.ReadFile(out)
+	if err := json.Unmarshal(data, &log); err != nil || len(log.Runs[0].Results) != 0 {
+		t.Errorf("Expected no results above the minimum size (%v):\n%s", err, data)
+	}
+
+	if err := dupesCommand(indexFile, DupesOptions{MinSize: -1}); err == nil {
+		t.Errorf("Expected an error for a negative minimum size")
+	}
+}
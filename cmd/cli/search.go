@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"trufast/internal/textsearch"
+)
+
+// hybridWeight is the share of the hybrid score taken from BM25
+// The remaining share comes from SimHash similarity
+const hybridWeight = 0.7
+
+// SearchResult is a single ranked hit returned by a keyword or hybrid search
+type SearchResult struct {
+	Chunk ChunkInfo
+	// Chunk is the metadata of the matching chunk
+
+	Score float64
+	// Score is the ranking score; higher is better
+	// Raw BM25 score in "bm25" mode, a value between 0 and 1 in "hybrid" mode
+}
+
+// searchCommand handles the search command
+// It ranks indexed chunks against a free-text query and displays the best hits
+// Parameters:
+//
+//	indexFile: Path to the previously generated index file
+//	query: Free-text query to search for
+//	mode: Ranking mode, either "bm25" or "hybrid"
+//	limit: Maximum number of results to display
+//
+// Returns:
+//
+//	error: nil on success, error if operation fails
+func searchCommand(indexFile string, query string, mode string, limit int) error {
+	// Validate parameters
+	if indexFile == "" {
+		return fmt.Errorf("error: index file is required")
+		// Ensures an index file path was provided via -i flag
+	}
+	if strings.TrimSpace(query) == "" {
+		return fmt.Errorf("error: query text is required")
+		// Ensures query text was provided via -q flag
+	}
+	if limit <= 0 {
+		return fmt.Errorf("invalid result limit: %d", limit)
+	}
+
+	// Load the index from file into memory
+	index, err := loadIndex(indexFile)
+	if err != nil {
+		return err
+	}
+
+	// Rank chunks with the requested mode
+	var results []SearchResult
+	switch mode {
+	case "bm25":
+		results = bm25Search(index, query)
+	case "hybrid":
+		results = hybridSearch(index, query)
+	default:
+		return fmt.Errorf("unknown search mode %q (expected bm25 or hybrid)", mode)
+	}
+
+	// Handle case where nothing matches
+	if len(results) == 0 {
+		fmt.Println("No matches found for query.")
+		return fmt.Errorf("no chunk contains the query terms")
+	}
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	// Display ranked chunks
+	for i, result := range results {
+		content, err := getChunkContent(chunkDocument(index, result.Chunk).Path, result.Chunk.Offset, result.Chunk.Size)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("#%d score: %.4f, byte offset: %d%s\n", i+1, result.Score, result.Chunk.Offset, documentLabel(index, result.Chunk))
+		fmt.Println("Chunk content:")
+		fmt.Println(content)
+
+		// Add separator between multiple chunks (but not after the last one)
+		if i < len(results)-1 {
+			fmt.Println("\n---")
+		}
+	}
+
+	// Print summary
+	fmt.Println("\n---")
+	fmt.Printf("\nShowing %d ranked chunk(s).\n", len(results))
+	return nil
+}
+
+// bm25Scores computes the Okapi BM25 score of every chunk containing a query term
+// Boilerplate chunks are left out of the results
+// Parameters:
+//
+//	index: Pointer to the loaded Index structure
+//	query: Free-text query
+//
+// Returns:
+//
+//	map[int]float64: BM25 score keyed by chunk index; chunks without query terms are absent
+func bm25Scores(index *Index, query string) map[int]float64 {
+	length := func(chunk int) int { return index.Chunks[chunk].Terms }
+	suppressed := func(chunk int) bool { return index.Chunks[chunk].Suppressed }
+	return textsearch.BM25Scores(index.Postings, len(index.Chunks), length, query, suppressed)
+}
+
+// bm25Search ranks chunks by their BM25 score for the query
+// Parameters:
+//
+//	index: Pointer to the loaded Index structure
+//	query: Free-text query
+//
+// Returns:
+//
+//	[]SearchResult: Matching chunks ordered by descending score
+func bm25Search(index *Index, query string) []SearchResult {
+	scores := bm25Scores(index, query)
+	results := make([]SearchResult, 0, len(scores))
+	for chunkIdx, score := range scores {
+		results = append(results, SearchResult{Chunk: index.Chunks[chunkIdx], Score: score})
+	}
+	sortResults(results)
+	return results
+}
+
+// hybridSearch ranks chunks by a blend of BM25 and SimHash similarity
+// BM25 scores are normalised by the best score so both signals lie in [0, 1]
+// Chunks are candidates if they contain a query term or their hash is within
+// the fuzzy Hamming threshold of the query fingerprint
+// Parameters:
+//
+//	index: Pointer to the loaded Index structure
+//	query: Free-text query
+//
+// Returns:
+//
+//	[]SearchResult: Matching chunks ordered by descending hybrid score
+func hybridSearch(index *Index, query string) []SearchResult {
+	scores := bm25Scores(index, query)
+	queryHash := queryFingerprint(index, query)
+
+	// Normalise BM25 scores against the best hit
+	var maxScore float64
+	for _, score := range scores {
+		maxScore = math.Max(maxScore, score)
+	}
+
+	// Gather candidates from both signals
+	candidates := make(map[int]bool, len(scores))
+	for chunkIdx := range scores {
+		candidates[chunkIdx] = true
+	}
+	threshold := hammingThreshold(index)
+	for hash, chunkIndices := range index.HashToChunks {
+		if HammingDistance(queryHash, hash) <= threshold {
+			for _, chunkIdx := range chunkIndices {
+				if !index.Chunks[chunkIdx].Suppressed {
+					candidates[chunkIdx] = true
+				}
+			}
+		}
+	}
+
+	results := make([]SearchResult, 0, len(candidates))
+	for chunkIdx := range candidates {
+		chunk := index.Chunks[chunkIdx]
+		var keyword float64
+		if maxScore > 0 {
+			keyword = scores[chunkIdx] / maxScore
+		}
+		similarity := 1 - float64(HammingDistance(queryHash, chunk.Hash))/float64(indexHashBits(index))
+		results = append(results, SearchResult{
+			Chunk: chunk,
+			Score: hybridWeight*keyword + (1-hybridWeight)*similarity,
+		})
+	}
+	sortResults(results)
+	return results
+}
+
+// sortResults orders results by descending score, breaking ties by document and file position
+func sortResults(results []SearchResult) {
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		if results[i].Chunk.Doc != results[j].Chunk.Doc {
+			return results[i].Chunk.Doc < results[j].Chunk.Doc
+		}
+		return results[i].Chunk.Offset < results[j].Chunk.Offset
+	})
+}
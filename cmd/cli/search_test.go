@@ -0,0 +1,90 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestBM25Search(t *testing.T) {
+	file := "test_search.txt"
+	content := "wolves hunt in packs. " + "bears sleep all winter" + "wolves and a wolf pack"
+	os.WriteFile(file, []byte(content), 0644)
+	defer os.Remove(file)
+
+	index, err := createIndex(file, 22)
+	if err != nil {
+		t.Fatalf("createIndex failed: %v", err)
+	}
+
+	results := bm25Search(index, "wolves")
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+	for _, result := range results {
+		if result.Score <= 0 {
+			t.Errorf("Expected positive score, got %f", result.Score)
+		}
+		if result.Chunk.Offset == 22 {
+			t.Errorf("Chunk without the term should not match: %+v", result)
+		}
+	}
+
+	if results := bm25Search(index, "tigers"); len(results) != 0 {
+		t.Errorf("Expected no results for unknown term, got %d", len(results))
+	}
+}
+
+func TestHybridSearch(t *testing.T) {
+	file := "test_hybrid.txt"
+	content := "alpha beta gamma delta " + "epsilon zeta eta theta "
+	os.WriteFile(file, []byte(content), 0644)
+	defer os.Remove(file)
+
+	index, err := createIndex(file, 23)
+	if err != nil {
+		t.Fatalf("createIndex failed: %v", err)
+	}
+
+	results := hybridSearch(index, "zeta eta")
+	if len(results) == 0 {
+		t.Fatalf("Expected results, got none")
+	}
+	if results[0].Chunk.Offset != 23 {
+		t.Errorf("Expected best hit at offset 23, got %d", results[0].Chunk.Offset)
+	}
+	if results[0].Score <= 0 || results[0].Score > 1 {
+		t.Errorf("Hybrid score out of range: %f", results[0].Score)
+	}
+}
+
+func Test_searchCommand(t *testing.T) {
+	file := "test_search_cmd.txt"
+	os.WriteFile(file, []byte("keyword search over indexed chunks"), 0644)
+	defer os.Remove(file)
+	indexFile := "test_search_cmd.idx"
+	defer os.Remove(indexFile)
+
+	if err := indexCommand(file, 16, indexFile, IndexOptions{}); err != nil {
+		t.Fatalf("indexCommand failed: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		query   string
+		mode    string
+		wantErr bool
+	}{
+		{"bm25 match", "indexed", "bm25", false},
+		{"hybrid match", "search over", "hybrid", false},
+		{"no match", "missing", "bm25", true},
+		{"empty query", " ", "bm25", true},
+		{"unknown mode", "indexed", "vector", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := searchCommand(indexFile, tt.query, tt.mode, 5); (err != nil) != tt.wantErr {
+				t.Errorf("searchCommand() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
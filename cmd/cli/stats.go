@@ -0,0 +1,451 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"strings"
+	"unsafe"
+)
+
+const (
+	statsSamplePairs = 20000
+	// statsSamplePairs is the number of chunk pairs whose Hamming distance is
+	// sampled for the distance histogram
+
+	statsSampleChunks = 1000
+	// statsSampleChunks is the number of chunks checked for a near-duplicate
+	// when estimating the duplication ratio
+
+	distanceBuckets = 16
+	// distanceBuckets is the number of equal-width buckets of the distance histogram
+
+	sizeBuckets = 4
+	// sizeBuckets splits chunk sizes up to the configured chunk size into quarters
+
+	bitSkewLimit = 0.1
+	// bitSkewLimit is how far from one half the share of fingerprints setting a
+	// bit may drift before the bit is reported as unbalanced
+)
+
+// IndexStats describes the contents and health of an index
+type IndexStats struct {
+	Documents int `json:"documents"`
+	// Documents is the number of indexed documents
+
+	Chunks int `json:"chunks"`
+	// Chunks is the number of indexed chunks
+
+	ChunkSize int `json:"chunk_size"`
+	// ChunkSize is the configured chunk size in bytes
+
+	HashBits int `json:"hash_bits"`
+	// HashBits is the fingerprint width
+
+	Weighted bool `json:"weighted"`
+	// Weighted reports TF-IDF weighted fingerprints
+
+	Suppressed int `json:"suppressed"`
+	// Suppressed is the number of chunks left out of results as boilerplate
+
+	MinChunk int `json:"min_chunk"`
+	// MinChunk is the size of the smallest chunk in bytes
+
+	MaxChunk int `json:"max_chunk"`
+	// MaxChunk is the size of the largest chunk in bytes
+
+	MeanChunk float64 `json:"mean_chunk"`
+	// MeanChunk is the mean chunk size in bytes
+
+	ChunkSizes []StatsBucket `json:"chunk_sizes"`
+	// ChunkSizes counts chunks by size, in quarters of the configured chunk size
+
+	DistinctHashes int `json:"distinct_hashes"`
+	// DistinctHashes is the number of keys of HashToChunks
+
+	CollisionBuckets int `json:"collision_buckets"`
+	// CollisionBuckets is the number of fingerprints shared by more than one chunk
+
+	CollidingChunks int `json:"colliding_chunks"`
+	// CollidingChunks is the number of chunks whose fingerprint is shared
+
+	LargestBucket int `json:"largest_bucket"`
+	// LargestBucket is the number of chunks sharing the most common fingerprint
+
+	BitBalance []float64 `json:"bit_balance"`
+	// BitBalance holds, for each fingerprint bit, the share of chunks setting it
+	// Well mixed fingerprints set every bit about half of the time
+
+	UnbalancedBits int `json:"unbalanced_bits"`
+	// UnbalancedBits is the number of bits whose share is off one half by more than bitSkewLimit
+
+	SampledPairs int `json:"sampled_pairs"`
+	// SampledPairs is the number of chunk pairs in the distance histogram
+
+	Distances []StatsBucket `json:"distances"`
+	// Distances is the histogram of Hamming distances between sampled chunk pairs
+
+	ExactDuplication float64 `json:"exact_duplication"`
+	// ExactDuplication is the share of chunks repeating the fingerprint of an earlier chunk
+
+	EstimatedDuplication float64 `json:"estimated_duplication"`
+	// EstimatedDuplication is the share of sampled chunks with another chunk
+	// within the Hamming threshold
+
+	MemoryBytes int64 `json:"memory_bytes"`
+	// MemoryBytes estimates the size of the loaded index in memory
+
+	DiskBytes int64 `json:"disk_bytes"`
+	// DiskBytes is the size of the index file
+
+	Warnings []string `json:"warnings"`
+	// Warnings lists health problems found in the index
+}
+
+// StatsBucket is one bar of a histogram; the range is inclusive
+type StatsBucket struct {
+	Low int `json:"low"`
+	// Low is the smallest value counted in the bucket
+
+	High int `json:"high"`
+	// High is the largest value counted in the bucket
+
+	Count int `json:"count"`
+	// Count is the number of values in the bucket
+}
+
+// statsCommand handles the stats command
+// It reports what an index contains and flags signs of a poorly working index
+// Parameters:
+//
+//	indexFile: Path to the previously generated index file
+//	output: "" for the plain listing, or "json", "ndjson" or "tsv"
+//
+// Returns:
+//
+//	error: nil on success, error if operation fails
+func statsCommand(indexFile string, output string) error {
+	// Validate parameters
+	if indexFile == "" {
+		return fmt.Errorf("error: index file is required")
+		// Ensures an index file path was provided via -i flag
+	}
+	if err := validateOutputFormat(output); err != nil {
+		return err
+	}
+
+	// Load the index from file into memory
+	index, err := loadIndex(indexFile)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(indexFile)
+	if err != nil {
+		return fmt.Errorf("error reading index file: %w", err)
+	}
+
+	stats := indexStats(index)
+	stats.DiskBytes = info.Size()
+	if output != "" {
+		return writeStats(os.Stdout, output, stats)
+	}
+
+	fmt.Printf("Index %s\n", indexFile)
+	fmt.Printf("  Documents:          %d\n", stats.Documents)
+	fmt.Printf("  Chunks:             %d (%d suppressed)\n", stats.Chunks, stats.Suppressed)
+	fmt.Printf("  Fingerprints:       %d-bit", stats.HashBits)
+	if stats.Weighted {
+		fmt.Print(", TF-IDF weighted")
+	}
+	fmt.Println()
+	fmt.Printf("  Chunk size:         %d bytes configured, %d-%d actual, mean %.1f\n", stats.ChunkSize, stats.MinChunk, stats.MaxChunk, stats.MeanChunk)
+	printHistogram(stats.ChunkSizes, stats.Chunks, "bytes")
+	fmt.Printf("  Distinct hashes:    %d\n", stats.DistinctHashes)
+	fmt.Printf("  Collision buckets:  %d holding %d chunk(s), largest %d\n", stats.CollisionBuckets, stats.CollidingChunks, stats.LargestBucket)
+	fmt.Printf("  Unbalanced bits:    %d of %d (share set outside %.1f-%.1f)\n", stats.UnbalancedBits, stats.HashBits, 0.5-bitSkewLimit, 0.5+bitSkewLimit)
+	fmt.Printf("  Hamming distances:  %d sampled pair(s)\n", stats.SampledPairs)
+	printHistogram(stats.Distances, stats.SampledPairs, "bits")
+	fmt.Printf("  Duplication:        %.1f%% exact, %.1f%% estimated within distance %d\n", 100*stats.ExactDuplication, 100*stats.EstimatedDuplication, hammingThreshold(index))
+	fmt.Printf("  Footprint:          %s in memory (estimated), %s on disk\n", formatBytes(stats.MemoryBytes), formatBytes(stats.DiskBytes))
+
+	if len(stats.Warnings) == 0 {
+		fmt.Println("\nNo problems found.")
+		return nil
+	}
+	fmt.Println("\nWarnings:")
+	for _, warning := range stats.Warnings {
+		fmt.Printf("  - %s\n", warning)
+	}
+	return nil
+}
+
+// indexStats computes the statistics of an index
+// Pairwise distances and the duplication ratio are estimated from a fixed-seed
+// sample, so repeated runs on the same index agree
+// Parameters:
+//
+//	index: Pointer to the loaded Index structure
+//
+// Returns:
+//
+//	*IndexStats: The statistics; DiskBytes is left to the caller
+func indexStats(index *Index) *IndexStats {
+	width := indexHashBits(index)
+	stats := &IndexStats{
+		Documents:      len(index.Documents),
+		Chunks:         len(index.Chunks),
+		ChunkSize:      index.ChunkSize,
+		HashBits:       width,
+		Weighted:       index.IDF != nil,
+		DistinctHashes: len(index.HashToChunks),
+		BitBalance:     make([]float64, width),
+		Warnings:       []string{},
+	}
+	if stats.Documents == 0 && len(index.Chunks) > 0 {
+		stats.Documents = 1
+		// Indexes created before multi-document support describe one file
+	}
+	if len(index.Chunks) == 0 {
+		stats.Warnings = append(stats.Warnings, "the index holds no chunks")
+		stats.MemoryBytes = indexMemory(index)
+		return stats
+	}
+
+	// Chunk sizes, in quarters of the configured size plus one bucket for larger chunks
+	quarter := max(index.ChunkSize/sizeBuckets, 1)
+	for i := range sizeBuckets {
+		stats.ChunkSizes = append(stats.ChunkSizes, StatsBucket{Low: i*quarter + 1, High: (i + 1) * quarter})
+	}
+	stats.ChunkSizes[sizeBuckets-1].High = index.ChunkSize
+	stats.MinChunk = index.Chunks[0].Size
+	total := 0
+	for _, chunk := range index.Chunks {
+		stats.MinChunk = min(stats.MinChunk, chunk.Size)
+		stats.MaxChunk = max(stats.MaxChunk, chunk.Size)
+		total += chunk.Size
+		if chunk.Suppressed {
+			stats.Suppressed++
+		}
+		bucket := min((chunk.Size-1)/quarter, sizeBuckets-1)
+		if chunk.Size > index.ChunkSize {
+			if len(stats.ChunkSizes) == sizeBuckets {
+				stats.ChunkSizes = append(stats.ChunkSizes, StatsBucket{Low: index.ChunkSize + 1})
+			}
+			bucket = sizeBuckets
+			stats.ChunkSizes[bucket].High = max(stats.ChunkSizes[bucket].High, chunk.Size)
+		}
+		stats.ChunkSizes[bucket].Count++
+
+		for bit := range width {
+			if chunk.Hash[bit/64]&(1<<(bit%64)) != 0 {
+				stats.BitBalance[bit]++
+			}
+		}
+	}
+	stats.MeanChunk = float64(total) / float64(len(index.Chunks))
+
+	// Exact collisions in HashToChunks
+	for _, chunks := range index.HashToChunks {
+		if len(chunks) > 1 {
+			stats.CollisionBuckets++
+			stats.CollidingChunks += len(chunks)
+		}
+		stats.LargestBucket = max(stats.LargestBucket, len(chunks))
+	}
+	stats.ExactDuplication = float64(len(index.Chunks)-len(index.HashToChunks)) / float64(len(index.Chunks))
+
+	// Share of chunks setting each bit
+	for bit := range stats.BitBalance {
+		stats.BitBalance[bit] /= float64(len(index.Chunks))
+		if math.Abs(stats.BitBalance[bit]-0.5) > bitSkewLimit {
+			stats.UnbalancedBits++
+		}
+	}
+
+	// Sampled pairwise distances; every pair is measured when there are few
+	step := max(width/distanceBuckets, 1)
+	for low := 0; low <= width; low += step {
+		stats.Distances = append(stats.Distances, StatsBucket{Low: low, High: min(low+step-1, width)})
+	}
+	random := rand.New(rand.NewPCG(1, 2))
+	n := len(index.Chunks)
+	if pairs := n * (n - 1) / 2; pairs <= statsSamplePairs {
+		for i := range n {
+			for j := i + 1; j < n; j++ {
+				stats.Distances[HammingDistance(index.Chunks[i].Hash, index.Chunks[j].Hash)/step].Count++
+			}
+		}
+		stats.SampledPairs = pairs
+	} else {
+		for range statsSamplePairs {
+			i, j := random.IntN(n), random.IntN(n-1)
+			if j >= i {
+				j++
+			}
+			stats.Distances[HammingDistance(index.Chunks[i].Hash, index.Chunks[j].Hash)/step].Count++
+		}
+		stats.SampledPairs = statsSamplePairs
+	}
+
+	// Share of sampled chunks with a near-duplicate elsewhere in the index
+	threshold := hammingThreshold(index)
+	sample := min(n, statsSampleChunks)
+	near := 0
+	for s := range sample {
+		i := s
+		if n > statsSampleChunks {
+			i = random.IntN(n)
+		}
+		hash := index.Chunks[i].Hash
+		if len(index.HashToChunks[hash]) > 1 {
+			near++
+			continue
+		}
+		for other := range index.HashToChunks {
+			if other != hash && HammingDistance(hash, other) <= threshold {
+				near++
+				break
+			}
+		}
+	}
+	stats.EstimatedDuplication = float64(near) / float64(sample)
+	stats.MemoryBytes = indexMemory(index)
+
+	// Health checks
+	if stats.UnbalancedBits > width/8 {
+		stats.Warnings = append(stats.Warnings, fmt.Sprintf("%d of %d fingerprint bits are unbalanced; chunks may be too small to fingerprint well", stats.UnbalancedBits, width))
+	}
+	if stats.LargestBucket > max(len(index.Chunks)/10, 1) && stats.LargestBucket > 2 {
+		stats.Warnings = append(stats.Warnings, fmt.Sprintf("%d chunks share one fingerprint; consider excluding boilerplate or a larger chunk size", stats.LargestBucket))
+	}
+	if stats.Distances[0].Count > stats.SampledPairs/2 {
+		stats.Warnings = append(stats.Warnings, "most sampled chunk pairs are near-identical; fingerprints barely tell chunks apart")
+	}
+	for doc := range index.Documents {
+		if len(index.Documents[doc].LineOffsets) == 0 {
+			stats.Warnings = append(stats.Warnings, "the index has no line tables; rebuild it to report line numbers")
+			break
+		}
+	}
+	if len(index.Postings) == 0 {
+		stats.Warnings = append(stats.Warnings, "the index has no keyword postings; search and fuzzy will find nothing")
+	}
+	return stats
+}
+
+// indexMemory estimates the bytes held by a loaded index
+// Struct sizes are exact; map and slice overheads are approximated by their entries
+func indexMemory(index *Index) int64 {
+	var fingerprint Fingerprint
+	size := int64(unsafe.Sizeof(*index))
+	size += int64(len(index.Chunks)) * int64(unsafe.Sizeof(ChunkInfo{}))
+	for _, chunks := range index.HashToChunks {
+		size += int64(unsafe.Sizeof(fingerprint)) + int64(unsafe.Sizeof(chunks)) + int64(len(chunks))*int64(unsafe.Sizeof(0))
+	}
+	for term, postings := range index.Postings {
+		size += int64(len(term)) + int64(unsafe.Sizeof(term)) + int64(unsafe.Sizeof(postings)) + int64(len(postings))*int64(unsafe.Sizeof(Posting{}))
+	}
+	for _, term := range index.Vocabulary {
+		size += int64(len(term)) + int64(unsafe.Sizeof(term))
+	}
+	for _, doc := range index.Documents {
+		size += int64(unsafe.Sizeof(doc)) + int64(len(doc.Path)) + int64(len(doc.LineOffsets))*8 + int64(len(doc.Sections))*int64(unsafe.Sizeof(Section{}))
+	}
+	for term := range index.IDF {
+		size += int64(len(term)) + int64(unsafe.Sizeof(term)) + 8
+	}
+	size += int64(len(index.Excluded)) * int64(unsafe.Sizeof(fingerprint))
+	return size
+}
+
+// printHistogram prints the non-empty buckets of a histogram with proportional bars
+func printHistogram(buckets []StatsBucket, total int, unit string) {
+	for _, bucket := range buckets {
+		if bucket.Count == 0 {
+			continue
+		}
+		share := float64(bucket.Count) / float64(max(total, 1))
+		fmt.Printf("    %6d-%-6d %s %8d %5.1f%% %s\n", bucket.Low, bucket.High, unit, bucket.Count, 100*share, strings.Repeat("#", int(math.Round(share*40))))
+	}
+}
+
+// formatBytes prints a byte count with a binary unit
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	value, suffix := float64(n), "KMGT"
+	for i := range suffix {
+		value /= unit
+		if value < unit || i == len(suffix)-1 {
+			return fmt.Sprintf("%.1f %ciB", value, suffix[i])
+		}
+	}
+	return ""
+}
+
+// writeStats writes index statistics as machine-readable output
+// json writes one document with the stats schema, ndjson the same object on one
+// line, and tsv a "metric" and "value" row per figure with histograms flattened
+// into one row per bucket
+func writeStats(w io.Writer, format string, stats *IndexStats) error {
+	var err error
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(struct {
+			Schema string `json:"schema"`
+			*IndexStats
+		}{statsOutputSchema, stats})
+	case "ndjson":
+		err = json.NewEncoder(w).Encode(stats)
+	default:
+		var sb strings.Builder
+		row := func(metric string, value string) {
+			sb.WriteString(metric + "\t" + tsvEscape.Replace(value) + "\n")
+		}
+		row("metric", "value")
+		row("documents", strconv.Itoa(stats.Documents))
+		row("chunks", strconv.Itoa(stats.Chunks))
+		row("chunk_size", strconv.Itoa(stats.ChunkSize))
+		row("hash_bits", strconv.Itoa(stats.HashBits))
+		row("weighted", strconv.FormatBool(stats.Weighted))
+		row("suppressed", strconv.Itoa(stats.Suppressed))
+		row("min_chunk", strconv.Itoa(stats.MinChunk))
+		row("max_chunk", strconv.Itoa(stats.MaxChunk))
+		row("mean_chunk", strconv.FormatFloat(stats.MeanChunk, 'f', -1, 64))
+		for _, bucket := range stats.ChunkSizes {
+			row(fmt.Sprintf("chunk_sizes.%d-%d", bucket.Low, bucket.High), strconv.Itoa(bucket.Count))
+		}
+		row("distinct_hashes", strconv.Itoa(stats.DistinctHashes))
+		row("collision_buckets", strconv.Itoa(stats.CollisionBuckets))
+		row("colliding_chunks", strconv.Itoa(stats.CollidingChunks))
+		row("largest_bucket", strconv.Itoa(stats.LargestBucket))
+		for bit, share := range stats.BitBalance {
+			row(fmt.Sprintf("bit_balance.%d", bit), strconv.FormatFloat(share, 'f', -1, 64))
+		}
+		row("unbalanced_bits", strconv.Itoa(stats.UnbalancedBits))
+		row("sampled_pairs", strconv.Itoa(stats.SampledPairs))
+		for _, bucket := range stats.Distances {
+			row(fmt.Sprintf("distances.%d-%d", bucket.Low, bucket.High), strconv.Itoa(bucket.Count))
+		}
+		row("exact_duplication", strconv.FormatFloat(stats.ExactDuplication, 'f', -1, 64))
+		row("estimated_duplication", strconv.FormatFloat(stats.EstimatedDuplication, 'f', -1, 64))
+		row("memory_bytes", strconv.FormatInt(stats.MemoryBytes, 10))
+		row("disk_bytes", strconv.FormatInt(stats.DiskBytes, 10))
+		for _, warning := range stats.Warnings {
+			row("warning", warning)
+		}
+		_, err = io.WriteString(w, sb.String())
+	}
+	if err != nil {
+		return fmt.Errorf("error writing output: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestIndexStats(t *testing.T) {
+	index, err := createCorpusIndex([]string{"../../resources/original.txt", "../../resources/plagirized.txt"}, 64, defaultHashBits)
+	if err != nil {
+		t.Fatalf("createCorpusIndex failed: %v", err)
+	}
+
+	stats := indexStats(index)
+	if stats.Documents != 2 || stats.Chunks != len(index.Chunks) || stats.HashBits != 64 || stats.DistinctHashes != len(index.HashToChunks) {
+		t.Fatalf("Unexpected counts %+v", stats)
+	}
+	sizes := 0
+	for _, bucket := range stats.ChunkSizes {
+		sizes += bucket.Count
+	}
+	if sizes != stats.Chunks || stats.MaxChunk != 64 || stats.MinChunk > stats.MaxChunk {
+		t.Errorf("Chunk size histogram does not cover every chunk: %+v", stats.ChunkSizes)
+	}
+
+	// The copied chunks collide exactly
+	if stats.CollisionBuckets == 0 || stats.LargestBucket != 2 || stats.ExactDuplication <= 0 || stats.EstimatedDuplication < stats.ExactDuplication {
+		t.Errorf("Expected the copied chunks to be counted as duplicates: %+v", stats)
+	}
+
+	// Few chunks: every pair is measured
+	pairs, counted := stats.Chunks*(stats.Chunks-1)/2, 0
+	for _, bucket := range stats.Distances {
+		counted += bucket.Count
+	}
+	if stats.SampledPairs != pairs || counted != pairs || stats.Distances[0].Count == 0 {
+		t.Errorf("Unexpected distance histogram (%d pairs): %+v", pairs, stats.Distances)
+	}
+	if len(stats.BitBalance) != 64 || stats.MemoryBytes <= 0 {
+		t.Errorf("Unexpected bit balance or footprint: %d bits, %d bytes", len(stats.BitBalance), stats.MemoryBytes)
+	}
+
+	// An empty index reports a warning instead of failing
+	if empty := indexStats(&Index{}); empty.Chunks != 0 || len(empty.Warnings) == 0 {
+		t.Errorf("Expected a warning for an empty index, got %+v", empty)
+	}
+}
+
+func Test_statsCommand(t *testing.T) {
+	indexFile := "test_stats.idx"
+	defer os.Remove(indexFile)
+	if err := indexCommand("../../resources/original.txt", 64, indexFile, IndexOptions{}); err != nil {
+		t.Fatalf("indexCommand failed: %v", err)
+	}
+
+	if err := statsCommand(indexFile, ""); err != nil {
+		t.Errorf("statsCommand failed: %v", err)
+	}
+	if err := statsCommand("", ""); err == nil {
+		t.Errorf("Expected an error without an index")
+	}
+	if err := statsCommand(indexFile, "xml"); err == nil {
+		t.Errorf("Expected an error for an unknown output format")
+	}
+
+	index, _ := loadIndex(indexFile)
+	stats := indexStats(index)
+	var buf bytes.Buffer
+	if err := writeStats(&buf, "json", stats); err != nil {
+		t.Fatalf("writeStats failed: %v", err)
+	}
+	var document map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &document); err != nil || document["schema"] != "blitz.stats.v1" || document["chunks"] != float64(stats.Chunks) {
+		t.Errorf("Unexpected JSON stats (%v):\n%s", err, buf.String())
+	}
+
+	buf.Reset()
+	if err := writeStats(&buf, "tsv", stats); err != nil {
+		t.Fatalf("writeStats failed: %v", err)
+	}
+	if !strings.HasPrefix(buf.String(), "metric\tvalue\ndocuments\t1\n") || !strings.Contains(buf.String(), "\nbit_balance.63\t") {
+		t.Errorf("Unexpected TSV stats:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash/crc32"
+	"os"
+)
+
+// Statuses of a document checked by verify
+const (
+	documentOK           = "ok"
+	documentTouched      = "touched"
+	documentModified     = "modified"
+	documentMissing      = "missing"
+	documentUnverifiable = "unverifiable"
+)
+
+// Statuses of a chunk whose document changed
+const (
+	chunkModified = "modified"
+	// chunkModified means the bytes in the chunk's range differ from the indexed text
+
+	chunkMissing = "missing"
+	// chunkMissing means the chunk's range now lies past the end of the file
+
+	chunkStale = "stale"
+	// chunkStale means the bytes are unchanged but earlier edits moved them to
+	// another line, so reported positions are wrong
+)
+
+// DocumentCheck is the outcome of checking one indexed document against its file
+type DocumentCheck struct {
+	Path string
+	// Path is the indexed path of the document
+
+	Status string
+	// Status is documentOK, documentTouched (newer modification time, same content),
+	// documentModified, documentMissing or documentUnverifiable
+
+	Detail string
+	// Detail explains the status, such as the old and new size
+
+	Chunks []ChunkCheck
+	// Chunks lists the chunks of a modified document that no longer match
+}
+
+// ChunkCheck is a chunk that no longer matches its source file
+type ChunkCheck struct {
+	ChunkInfo
+	// ChunkInfo is the indexed chunk
+
+	Line int
+	// Line is the line the chunk started on at indexing time
+
+	Status string
+	// Status is chunkModified, chunkMissing or chunkStale
+}
+
+// contentDigest returns the hex SHA-256 of a document's content, as stored in Document.Digest
+func contentDigest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// verifyCommand handles the verify command
+// It checks every indexed document against its file and reports drift
+// Parameters:
+//
+//	indexFile: Path to the previously generated index file
+//
+// Returns:
+//
+//	error: nil when every document matches, error if any drifted or the operation fails
+func verifyCommand(indexFile string) error {
+	// Validate parameters
+	if indexFile == "" {
+		return fmt.Errorf("error: index file is required")
+		// Ensures an index file path was provided via -i flag
+	}
+
+	// Load the index from file into memory
+	index, err := loadIndex(indexFile)
+	if err != nil {
+		return err
+	}
+
+	checks := verifyIndex(index)
+	fmt.Printf("Verified %d document(s) of %s\n", len(checks), indexFile)
+	counts := make(map[string]int)
+	for _, check := range checks {
+		counts[check.Status]++
+		fmt.Printf("  %-12s %s", check.Status, check.Path)
+		if check.Detail != "" {
+			fmt.Printf(" (%s)", check.Detail)
+		}
+		fmt.Println()
+		for _, chunk := range check.Chunks {
+			counts["chunk "+chunk.Status]++
+			fmt.Printf("    %-10s chunk at byte offset %d (line %d, %d bytes)\n", chunk.Status, chunk.Offset, chunk.Line, chunk.Size)
+		}
+	}
+
+	// Print summary
+	fmt.Println("\n---")
+	fmt.Printf("\n%d ok, %d touched, %d modified, %d missing, %d unverifiable document(s); %d modified, %d missing, %d stale chunk(s).\n",
+		counts[documentOK], counts[documentTouched], counts[documentModified], counts[documentMissing], counts[documentUnverifiable],
+		counts["chunk "+chunkModified], counts["chunk "+chunkMissing], counts["chunk "+chunkStale])
+	if counts[documentModified]+counts[documentMissing] > 0 {
+		return fmt.Errorf("the index is out of date with its source files; re-run the index command")
+	}
+	return nil
+}
+
+// verifyIndex checks every document of an index against its file
+func verifyIndex(index *Index) []DocumentCheck {
+	if len(index.Documents) == 0 {
+		return []DocumentCheck{{Path: index.FilePath, Status: documentUnverifiable, Detail: "indexed before checksums were stored"}}
+	}
+	checks := make([]DocumentCheck, len(index.Documents))
+	for doc := range index.Documents {
+		checks[doc] = verifyDocument(index, doc)
+	}
+	return checks
+}
+
+// verifyDocument compares one indexed document with its file
+// The whole-file digest decides whether anything changed; only then are the
+// chunks compared one by one against their checksums and line positions
+// Parameters:
+//
+//	index: Pointer to the loaded Index structure
+//	doc: Position of the document in index.Documents
+//
+// Returns:
+//
+//	DocumentCheck: The status of the document and its drifted chunks
+func verifyDocument(index *Index, doc int) DocumentCheck {
+	document := &index.Documents[doc]
+	check := DocumentCheck{Path: document.Path, Status: documentOK}
+	if document.Digest == "" {
+		check.Status, check.Detail = documentUnverifiable, "indexed before checksums were stored"
+		return check
+	}
+
+	info, err := os.Stat(document.Path)
+	if err != nil {
+		check.Status, check.Detail = documentMissing, err.Error()
+		return check
+	}
+	data, err := os.ReadFile(document.Path)
+	if err != nil {
+		check.Status, check.Detail = documentMissing, err.Error()
+		return check
+	}
+	if contentDigest(data) == document.Digest {
+		if !document.ModTime.IsZero() && !info.ModTime().Equal(document.ModTime) {
+			check.Status, check.Detail = documentTouched, "modification time changed, content unchanged"
+		}
+		return check
+	}
+
+	check.Status = documentModified
+	check.Detail = fmt.Sprintf("%d bytes when indexed, %d now", document.Size, len(data))
+	current := &Document{LineOffsets: appendLineOffsets([]int64{0}, data, 0)}
+	first, last := documentChunks(index, doc)
+	for chunkIdx := first; chunkIdx <= last; chunkIdx++ {
+		chunk := index.Chunks[chunkIdx]
+		line, _ := lineColumn(document, chunk.Offset)
+		status := ""
+		end := chunk.Offset + int64(chunk.Size)
+		switch {
+		case chunk.Offset >= int64(len(data)):
+			status = chunkMissing
+		case end > int64(len(data)) || crc32.ChecksumIEEE(data[chunk.Offset:end]) != chunk.Checksum:
+			status = chunkModified
+		default:
+			if now, _ := lineColumn(current, chunk.Offset); now != line {
+				status = chunkStale
+			}
+		}
+		if status != "" {
+			check.Chunks = append(check.Chunks, ChunkCheck{ChunkInfo: chunk, Line: line, Status: status})
+		}
+	}
+	return check
+}
+
+// changedChunks returns the chunks whose source text no longer matches their checksum
+// Chunks of documents indexed without checksums are assumed unchanged
+// Parameters:
+//
+//	index: Pointer to the loaded Index structure
+//	chunks: Chunks about to be shown to the user
+//
+// Returns:
+//
+//	[]ChunkInfo: The chunks that changed or can no longer be read
+func changedChunks(index *Index, chunks []ChunkInfo) []ChunkInfo {
+	var changed []ChunkInfo
+	for _, chunk := range chunks {
+		doc := chunkDocument(index, chunk)
+		if doc.Digest == "" {
+			continue
+		}
+		content, err := getChunkContent(doc.Path, chunk.Offset, chunk.Size)
+		if err != nil || len(content) != chunk.Size || crc32.ChecksumIEEE([]byte(content)) != chunk.Checksum {
+			changed = append(changed, chunk)
+		}
+	}
+	return changed
+}
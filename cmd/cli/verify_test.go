@@ -0,0 +1,109 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifyDocument(t *testing.T) {
+	file := "test_verify.txt"
+	defer os.Remove(file)
+	// Four lines of 24 bytes, one per chunk
+	lines := "first line of the text.\nsecond line of the text\nthird line of the text.\nfourth line of the text\n"
+	os.WriteFile(file, []byte(lines), 0644)
+
+	index, err := createIndex(file, 24)
+	if err != nil {
+		t.Fatalf("createIndex failed: %v", err)
+	}
+	if index.Documents[0].Digest != contentDigest([]byte(lines)) || index.Documents[0].ModTime.IsZero() {
+		t.Fatalf("Digest or modification time not recorded: %+v", index.Documents[0])
+	}
+	if check := verifyDocument(index, 0); check.Status != documentOK {
+		t.Errorf("Expected an unchanged document, got %+v", check)
+	}
+
+	// A newer modification time alone is not drift
+	later := time.Now().Add(time.Hour)
+	os.Chtimes(file, later, later)
+	if check := verifyDocument(index, 0); check.Status != documentTouched {
+		t.Errorf("Expected a touched document, got %+v", check)
+	}
+
+	// Editing the second chunk modifies it, and truncation removes the last
+	edited := strings.Replace(lines, "second", "SECOND", 1)
+	os.WriteFile(file, []byte(edited[:60]), 0644)
+	check := verifyDocument(index, 0)
+	if check.Status != documentModified {
+		t.Fatalf("Expected a modified document, got %+v", check)
+	}
+	statuses := make(map[int64]string)
+	for _, chunk := range check.Chunks {
+		statuses[chunk.Offset] = chunk.Status
+	}
+	if statuses[0] != "" || statuses[24] != chunkModified || statuses[48] != chunkModified || statuses[72] != chunkMissing {
+		t.Errorf("Unexpected chunk statuses %v", statuses)
+	}
+	if changed := changedChunks(index, index.Chunks); len(changed) != len(index.Chunks)-1 {
+		t.Errorf("Expected every chunk but the first to have changed, got %+v", changed)
+	}
+
+	// Splitting the first line moves the unchanged chunks after it to other lines
+	shifted := strings.Replace(lines, "first line", "first\nline", 1)
+	os.WriteFile(file, []byte(shifted), 0644)
+	check = verifyDocument(index, 0)
+	statuses = make(map[int64]string)
+	for _, chunk := range check.Chunks {
+		statuses[chunk.Offset] = chunk.Status
+	}
+	if statuses[0] != chunkModified || statuses[24] != chunkStale {
+		t.Errorf("Expected stale chunks after the inserted line break, got %v", statuses)
+	}
+
+	// Missing files and indexes without digests
+	os.Remove(file)
+	if check := verifyDocument(index, 0); check.Status != documentMissing {
+		t.Errorf("Expected a missing document, got %+v", check)
+	}
+	index.Documents[0].Digest = ""
+	if check := verifyDocument(index, 0); check.Status != documentUnverifiable {
+		t.Errorf("Expected an unverifiable document, got %+v", check)
+	}
+	if changed := changedChunks(index, index.Chunks); len(changed) != 0 {
+		t.Errorf("Chunks without checksums should be assumed unchanged, got %+v", changed)
+	}
+}
+
+func Test_verifyCommand(t *testing.T) {
+	file := "test_verify_cmd.txt"
+	indexFile := "test_verify.idx"
+	defer os.Remove(file)
+	defer os.Remove(indexFile)
+	os.WriteFile(file, []byte("The quick brown fox jumps over the lazy dog near the river bank."), 0644)
+	if err := indexCommand(file, 16, indexFile, IndexOptions{}); err != nil {
+		t.Fatalf("indexCommand failed: %v", err)
+	}
+	index, _ := loadIndex(indexFile)
+	hash := index.Chunks[0].Hash
+
+	if err := verifyCommand(indexFile); err != nil {
+		t.Errorf("verifyCommand failed on a fresh index: %v", err)
+	}
+	if err := verifyCommand(""); err == nil {
+		t.Errorf("Expected an error without an index")
+	}
+
+	// After an edit, verify fails and a strict lookup refuses the changed chunk
+	os.WriteFile(file, []byte("A slow green fox jumps over the lazy dog near the river bank."), 0644)
+	if err := verifyCommand(indexFile); err == nil {
+		t.Errorf("Expected verify to report the edited file")
+	}
+	if err := lookupCommand(indexFile, hash, LookupOptions{Strict: true}); err == nil {
+		t.Errorf("Expected a strict lookup to refuse a changed chunk")
+	}
+	if err := lookupCommand(indexFile, hash, LookupOptions{}); err != nil {
+		t.Errorf("Expected lookup to warn and continue, got %v", err)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"math"
+
+	"trufast/internal/textsearch"
+)
+
+// idfScale converts IDF values into the integer feature weights used by simhash
+// Two decimal places are enough to keep rare and common terms apart
+const idfScale = 100
+
+// weightIndex recomputes every chunk fingerprint with TF-IDF feature weights
+// This is the second indexing pass: document frequencies are taken from the
+// posting lists built in the first pass, with each chunk counted as a document,
+// and the resulting IDF table is stored in the index so queries can be weighted alike
+// Parameters:
+//
+//	index: Pointer to the Index structure to update in place
+func weightIndex(index *Index) {
+	total := float64(len(index.Chunks))
+	idf := make(map[string]float64, len(index.Postings))
+	for term, postings := range index.Postings {
+		idf[term] = math.Log((total+1)/(float64(len(postings))+1)) + 1
+	}
+	applyWeights(index, idf)
+}
+
+// applyWeights fingerprints the chunks of an index with the given IDF table
+// Used both for the indexed corpus and for documents compared against it
+// (query documents, exclusion corpus), which must share the corpus's weights
+// Parameters:
+//
+//	index: Pointer to the Index structure to update in place
+//	idf: IDF table of the corpus
+func applyWeights(index *Index, idf map[string]float64) {
+	// Collect the weighted features of each chunk from the posting lists
+	features := make([][]feature, len(index.Chunks))
+	unknown := maxIDF(idf)
+	for term, postings := range index.Postings {
+		weight := termWeight(idf, term, unknown)
+		for _, posting := range postings {
+			features[posting.Chunk] = append(features[posting.Chunk], feature{term: []byte(term), weight: posting.Freq * weight})
+		}
+	}
+
+	index.IDF = idf
+	index.HashToChunks = make(map[Fingerprint][]int, len(index.Chunks))
+	width := indexHashBits(index)
+	for chunkIdx := range index.Chunks {
+		hash := simhashFeatures(features[chunkIdx], width)
+		index.Chunks[chunkIdx].Hash = hash
+		index.HashToChunks[hash] = append(index.HashToChunks[hash], chunkIdx)
+	}
+	buildHierarchy(index)
+}
+
+// termWeight returns the integer simhash weight of a term
+// Terms missing from the table get the unknown IDF, which callers set to the
+// highest value in the table: a term the corpus has not seen is at least as
+// distinctive as its rarest term
+func termWeight(idf map[string]float64, term string, unknown float64) int {
+	value, ok := idf[term]
+	if !ok {
+		value = unknown
+	}
+	return max(int(math.Round(value*idfScale)), 1)
+}
+
+// maxIDF returns the highest IDF in the table, or 1 for an empty table
+func maxIDF(idf map[string]float64) float64 {
+	highest := 1.0
+	for _, value := range idf {
+		highest = math.Max(highest, value)
+	}
+	return highest
+}
+
+// queryFingerprint computes the SimHash of query text the way the index computed its chunks
+// Unweighted indexes use the plain word feature set; TF-IDF indexes weight
+// each query word with the stored IDF table
+// Parameters:
+//
+//	index: Pointer to the loaded Index structure
+//	text: Query text
+//
+// Returns:
+//
+//	Fingerprint: Fingerprint comparable with the index's chunk hashes
+func queryFingerprint(index *Index, text string) Fingerprint {
+	width := indexHashBits(index)
+	if index.IDF == nil {
+		return simhashFeatures(textFeatures([]byte(text)), width)
+	}
+	freqs, _ := textsearch.TermFrequencies(text)
+	features := make([]feature, 0, len(freqs))
+	unknown := maxIDF(index.IDF)
+	for term, freq := range freqs {
+		features = append(features, feature{term: []byte(term), weight: freq * termWeight(index.IDF, term, unknown)})
+	}
+	return simhashFeatures(features, width)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestWeightIndex(t *testing.T) {
+	file := "test_weight.txt"
+	// 32-byte chunks; "the" occurs in every chunk, each animal in one
+	content := "the cat sat on the mat all day\n" +
+		"the dog ran to the park all day\n" +
+		"the owl sat on the oak all nigh\n"
+	os.WriteFile(file, []byte(content), 0644)
+	defer os.Remove(file)
+
+	index, err := createIndex(file, 32)
+	if err != nil {
+		t.Fatalf("createIndex failed: %v", err)
+	}
+	weightIndex(index)
+
+	if index.IDF["cat"] <= index.IDF["sat"] || index.IDF["sat"] <= index.IDF["the"] {
+		t.Errorf("Expected rarer terms to weigh more: cat=%.3f sat=%.3f the=%.3f", index.IDF["cat"], index.IDF["sat"], index.IDF["the"])
+	}
+
+	// Query text is fingerprinted with the stored table, so a chunk finds itself
+	for i, chunk := range index.Chunks {
+		text := content[chunk.Offset : chunk.Offset+int64(chunk.Size)]
+		if hash := queryFingerprint(index, text); hash != chunk.Hash {
+			t.Errorf("Chunk %d: query fingerprint %s differs from indexed %s", i, hash, chunk.Hash)
+		}
+		if len(index.HashToChunks[chunk.Hash]) == 0 {
+			t.Errorf("Chunk %d hash missing from HashToChunks", i)
+		}
+	}
+
+	// Another document weighted with the same table gets the same fingerprints
+	query, err := createIndex(file, 32)
+	if err != nil {
+		t.Fatalf("createIndex failed: %v", err)
+	}
+	applyWeights(query, index.IDF)
+	for i := range query.Chunks {
+		if query.Chunks[i].Hash != index.Chunks[i].Hash {
+			t.Errorf("Chunk %d: applyWeights gave %s, want %s", i, query.Chunks[i].Hash, index.Chunks[i].Hash)
+		}
+	}
+}
+
+func Test_indexCommandWeighted(t *testing.T) {
+	indexFile := "test_weighted.idx"
+	defer os.Remove(indexFile)
+
+	if err := indexCommand("../../resources/original.txt", 64, indexFile, IndexOptions{Weighted: true}); err != nil {
+		t.Fatalf("indexCommand failed: %v", err)
+	}
+	index, err := loadIndex(indexFile)
+	if err != nil {
+		t.Fatalf("loadIndex failed: %v", err)
+	}
+	if len(index.IDF) == 0 {
+		t.Fatalf("Expected IDF table to be stored in the index")
+	}
+
+	// Lookup by text alone uses the stored weights
+	query := "The meeting was scheduled for noon, and everyone was expected to arrive on time."
+	if err := lookupCommand(indexFile, Fingerprint{}, LookupOptions{QueryText: query}); err != nil {
+		t.Errorf("lookupCommand failed: %v", err)
+	}
+
+	// The plagiarised copy is still found; chunks with substituted words may
+	// drop out since words unseen by the corpus carry the highest weight
+	comparison, err := compareDocument(index, "../../resources/plagirized.txt", false)
+	if err != nil {
+		t.Fatalf("compareDocument failed: %v", err)
+	}
+	if len(comparison.Regions) == 0 || comparison.Regions[0].SourceStart != 0 || comparison.Originality >= 50 {
+		t.Errorf("Expected mostly copied document, got %+v (%.1f%%)", comparison.Regions, comparison.Originality)
+	}
+}
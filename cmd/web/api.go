@@ -0,0 +1,562 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mfonda/simhash"
+
+	"trufast/internal/textsearch"
+)
+
+const (
+	maxContextLines = 50
+	maxRequestSize  = 1 << 20
+)
+
+var searchModes = map[string]bool{"simhash": true, "bm25": true, "hybrid": true, "fuzzy": true}
+
+type Hit struct {
+	Document  string              `json:"document"`
+	Title     string              `json:"title"`
+	Offset    int64               `json:"offset"`
+	Length    int                 `json:"length"`
+	Line      int                 `json:"line"`
+	Column    int                 `json:"column"`
+	Distance  int                 `json:"distance"`
+	Score     float64             `json:"score"`
+	Snippet   string              `json:"snippet"`
+	Fragments []Fragment          `json:"fragments"`
+	Diff      []textsearch.DiffOp `json:"diff,omitempty"`
+}
+
+type SearchRequest struct {
+	Query     string   `json:"query"`
+	Mode      string   `json:"mode"`
+	Documents []string `json:"documents"`
+	Diff      bool     `json:"diff"`
+}
+
+type SearchResponse struct {
+	Query   string `json:"query"`
+	Mode    string `json:"mode"`
+	Results []Hit  `json:"results"`
+}
+
+type Resolution struct {
+	ChunkSize int `json:"chunk_size"`
+	Chunks    int `json:"chunks"`
+}
+
+type IndexResponse struct {
+	Document    Document     `json:"document"`
+	Resolutions []Resolution `json:"resolutions"`
+}
+
+type Context struct {
+	Document  string     `json:"document"`
+	Offset    int64      `json:"offset"`
+	Length    int        `json:"length"`
+	FirstLine int        `json:"first_line"`
+	Fragments []Fragment `json:"fragments"`
+}
+
+type apiError struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+}
+
+func apiRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/v1/documents", apiListDocuments)
+	mux.HandleFunc("POST /api/v1/documents", apiCreateDocument)
+	mux.HandleFunc("GET /api/v1/documents/{id}", apiGetDocument)
+	mux.HandleFunc("PUT /api/v1/documents/{id}", apiPutDocument)
+	mux.HandleFunc("DELETE /api/v1/documents/{id}", apiDeleteDocument)
+	mux.HandleFunc("POST /api/v1/documents/{id}/index", apiIndexDocument)
+	mux.HandleFunc("GET /api/v1/documents/{id}/context", apiDocumentContext)
+	mux.HandleFunc("POST /api/v1/search", apiSearch)
+	mux.HandleFunc("POST /api/v1/reports", apiCreateReport)
+	mux.HandleFunc("GET /api/v1/jobs", apiListJobs)
+	mux.HandleFunc("GET /api/v1/jobs/{id}", apiGetJob)
+	mux.HandleFunc("DELETE /api/v1/jobs/{id}", apiCancelJob)
+	mux.HandleFunc("GET /api/v1/jobs/{id}/events", apiJobEvents)
+	mux.HandleFunc("GET /api/v1/live/events", apiLiveEvents)
+	mux.HandleFunc("POST /api/v1/live/{session}", apiLiveQuery)
+	mux.HandleFunc("/api/", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no API endpoint %s %s", r.Method, r.URL.Path))
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		loggerErr.Println(err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	if status >= http.StatusInternalServerError {
+		loggerErr.Println(err)
+	}
+	writeJSON(w, status, map[string]apiError{"error": {Status: status, Message: err.Error()}})
+}
+
+func errorStatus(err error) int {
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.Is(err, errDocumentNotFound), errors.Is(err, errJobNotFound), errors.Is(err, errSessionNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, errJobFinished):
+		return http.StatusConflict
+	case errors.As(err, &tooLarge), errors.Is(err, errReportTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, errInvalidDocumentID), errors.Is(err, errMissingFile), errors.Is(err, errNoSources):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+func decodeRequest(w http.ResponseWriter, r *http.Request, v any) (int, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		if status := errorStatus(err); status == http.StatusRequestEntityTooLarge {
+			return status, err
+		}
+		return http.StatusBadRequest, err
+	}
+	return http.StatusOK, nil
+}
+
+func apiListDocuments(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, library.list())
+}
+
+func apiCreateDocument(w http.ResponseWriter, r *http.Request) {
+	if err := limitUpload(w, r); err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+
+	var doc Document
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if r.URL.Query().Get("async") == "true" {
+		if mediaType == "multipart/form-data" {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("error: async uploads must send the document as the raw request body"))
+			return
+		}
+		apiStageDocument(w, r)
+		return
+	}
+	if mediaType == "multipart/form-data" {
+		_, added, err := streamForm(r)
+		if err == nil && added == nil {
+			err = errMissingFile
+		}
+		if err != nil {
+			writeError(w, errorStatus(err), err)
+			return
+		}
+		doc = *added
+	} else {
+		added, err := library.add(r.URL.Query().Get("title"), r.Body)
+		if err != nil {
+			writeError(w, errorStatus(err), err)
+			return
+		}
+		doc = added
+	}
+	w.Header().Set("Location", "/api/v1/documents/"+doc.ID)
+	writeJSON(w, http.StatusCreated, doc)
+}
+
+func apiPutDocument(w http.ResponseWriter, r *http.Request) {
+	if err := limitUpload(w, r); err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	doc, created, err := library.put(r.PathValue("id"), r.URL.Query().Get("title"), r.Body)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	if created {
+		w.Header().Set("Location", "/api/v1/documents/"+doc.ID)
+		writeJSON(w, http.StatusCreated, doc)
+		return
+	}
+	writeJSON(w, http.StatusOK, doc)
+}
+
+func apiGetDocument(w http.ResponseWriter, r *http.Request) {
+	doc, err := library.document(r.PathValue("id"))
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, doc)
+}
+
+func apiDeleteDocument(w http.ResponseWriter, r *http.Request) {
+	if err := library.remove(r.PathValue("id")); err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func apiStageDocument(w http.ResponseWriter, r *http.Request) {
+	title := r.URL.Query().Get("title")
+	id, partPath, size, err := library.stage(r.Body)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	job, err := jobs.submit(title, size, func(ctx context.Context, progress func(int64, int)) (Document, error) {
+		return library.addStaged(ctx, id, title, partPath, progress)
+	}, func() { os.Remove(partPath) })
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	w.Header().Set("Location", "/api/v1/jobs/"+job.ID)
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+func apiIndexDocument(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("async") == "true" {
+		doc, err := library.document(r.PathValue("id"))
+		if err != nil {
+			writeError(w, errorStatus(err), err)
+			return
+		}
+		job, err := jobs.submit(doc.Title, doc.Size, func(ctx context.Context, progress func(int64, int)) (Document, error) {
+			doc, _, err := library.reindexContext(ctx, doc.ID, progress)
+			return doc, err
+		}, nil)
+		if err != nil {
+			writeError(w, errorStatus(err), err)
+			return
+		}
+		w.Header().Set("Location", "/api/v1/jobs/"+job.ID)
+		writeJSON(w, http.StatusAccepted, job)
+		return
+	}
+
+	doc, multi, err := library.reindex(r.PathValue("id"))
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	response := IndexResponse{Document: doc}
+	for _, index := range multi.Resolutions {
+		response.Resolutions = append(response.Resolutions, Resolution{ChunkSize: index.ChunkSize, Chunks: len(index.Chunks)})
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+func apiSearch(w http.ResponseWriter, r *http.Request) {
+	format, err := exportFormat(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	var request SearchRequest
+	if status, err := decodeRequest(w, r, &request); err != nil {
+		writeError(w, status, fmt.Errorf("error decoding search request: %w", err))
+		return
+	}
+	if request.Mode == "" {
+		request.Mode = "simhash"
+	}
+	if !searchModes[request.Mode] {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("unknown search mode %q", request.Mode))
+		return
+	}
+	if strings.TrimSpace(request.Query) == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("error: query text is required"))
+		return
+	}
+
+	docs, err := library.selection(request.Documents)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	hits, err := libraryHits(library, docs, request.Query, request.Mode, request.Diff)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	response := SearchResponse{Query: request.Query, Mode: request.Mode, Results: hits}
+	if format != "json" {
+		writeExport(w, format, "search", searchExport(response))
+		return
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+func apiCreateReport(w http.ResponseWriter, r *http.Request) {
+	format, err := exportFormat(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	var request ReportRequest
+	if status, err := decodeRequest(w, r, &request); err != nil {
+		writeError(w, status, fmt.Errorf("error decoding report request: %w", err))
+		return
+	}
+	if request.Suspect == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("error: a suspect document is required"))
+		return
+	}
+
+	report, err := buildReport(library, request.Suspect, request.Sources)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	if format != "json" {
+		writeExport(w, format, "report-"+report.Suspect.ID, reportExport(report))
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+func apiListJobs(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, jobs.list())
+}
+
+func apiGetJob(w http.ResponseWriter, r *http.Request) {
+	job, err := jobs.get(r.PathValue("id"))
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
+
+func apiCancelJob(w http.ResponseWriter, r *http.Request) {
+	job, err := jobs.cancel(r.PathValue("id"))
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
+
+func apiJobEvents(w http.ResponseWriter, r *http.Request) {
+	updates, unsubscribe, err := jobs.subscribe(r.PathValue("id"))
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	for {
+		job, err := jobs.get(r.PathValue("id"))
+		if err != nil {
+			return
+		}
+		event := "progress"
+		if job.terminal() {
+			event = job.Status
+		}
+		data, _ := json.Marshal(job)
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+		if flusher != nil {
+			flusher.Flush()
+		}
+		if job.terminal() {
+			return
+		}
+
+		select {
+		case <-updates:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func libraryHits(library *Library, docs []Document, query string, mode string, showDiff bool) ([]Hit, error) {
+	hits := make([]Hit, 0)
+	for _, doc := range docs {
+		multi, err := library.index(doc.ID)
+		if err != nil {
+			return nil, err
+		}
+		docHits, err := documentHits(multi.resolution(len(query)), query, mode, showDiff)
+		if err != nil {
+			return nil, err
+		}
+		for i := range docHits {
+			docHits[i].Document, docHits[i].Title = doc.ID, doc.Title
+		}
+		hits = append(hits, docHits...)
+	}
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].Score > hits[j].Score })
+	return hits, nil
+}
+
+func documentHits(index *Index, query string, mode string, showDiff bool) ([]Hit, error) {
+	queryHash := simhash.Simhash(simhash.NewWordFeatureSet([]byte(query)))
+	var chunks []ChunkInfo
+	var scores []float64
+	terms := make(map[string]bool)
+
+	switch mode {
+	case "simhash":
+		matchingChunks, err := lookupQuery(index, queryHash)
+		if err != nil {
+			return nil, err
+		}
+		if len(matchingChunks) == 0 {
+			return documentHits(index, query, "fuzzy", false)
+		}
+		for _, chunk := range matchingChunks {
+			chunks = append(chunks, chunk)
+			scores = append(scores, 1-float64(HammingDistance(queryHash, chunk.Hash))/64)
+		}
+	case "fuzzy":
+		results, _ := fuzzySearch(index, query, textsearch.MaxEditDistance)
+		for _, result := range results[:min(len(results), maxResults)] {
+			chunks = append(chunks, result.Chunk)
+			scores = append(scores, result.Score)
+			for _, term := range result.Terms {
+				terms[term] = true
+			}
+		}
+	default:
+		var results []SearchResult
+		if mode == "hybrid" {
+			results = hybridSearch(index, query)
+		} else {
+			results = bm25Search(index, query)
+		}
+		for _, result := range results[:min(len(results), maxResults)] {
+			chunks = append(chunks, result.Chunk)
+			scores = append(scores, result.Score)
+		}
+		for _, term := range textsearch.Tokenize(query) {
+			terms[term] = true
+		}
+	}
+
+	hits := make([]Hit, 0, len(chunks))
+	seen := make(map[int64]bool)
+	for i, chunk := range chunks {
+		start, end := chunk.Offset, chunk.Offset+int64(chunk.Size)
+		found := false
+		if mode == "simhash" {
+			span, ok, err := locateMatch(index, chunk, query)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				start, end, found = span.Start, span.End, true
+			}
+		}
+		if seen[start] {
+			continue
+		}
+		seen[start] = true
+		snippet, err := getChunkContent(index.FilePath, start, int(end-start))
+		if err != nil {
+			return nil, err
+		}
+
+		regionStart := min(chunk.Offset, start)
+		regionEnd := max(chunk.Offset+int64(chunk.Size), end)
+		region, err := getChunkContent(index.FilePath, regionStart, int(regionEnd-regionStart))
+		if err != nil {
+			return nil, err
+		}
+		var fragments []Fragment
+		if mode == "simhash" {
+			fragments = spanFragments(region, int(start-regionStart), int(end-regionStart))
+		} else {
+			fragments = termFragments(region, terms)
+		}
+
+		line, column := lineColumn(index, start)
+		hit := Hit{
+			Offset:    start,
+			Length:    int(end - start),
+			Line:      line,
+			Column:    column,
+			Distance:  HammingDistance(queryHash, chunk.Hash),
+			Score:     scores[i],
+			Snippet:   snippet,
+			Fragments: fragments,
+		}
+		if showDiff && found {
+			hit.Diff = textsearch.DiffWords(snippet, query)
+		}
+		hits = append(hits, hit)
+	}
+	return hits, nil
+}
+
+func apiDocumentContext(w http.ResponseWriter, r *http.Request) {
+	multi, err := library.index(r.PathValue("id"))
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+
+	params := r.URL.Query()
+	offset, err := strconv.ParseInt(params.Get("offset"), 10, 64)
+	if err != nil || offset < 0 || offset > multi.Size {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("error: offset must be between 0 and %d", multi.Size))
+		return
+	}
+	length, err := strconv.Atoi(cmp.Or(params.Get("length"), "0"))
+	if err != nil || length < 0 || offset+int64(length) > multi.Size {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("error: length must not reach past the end of the document"))
+		return
+	}
+	lines, err := strconv.Atoi(cmp.Or(params.Get("lines"), "3"))
+	if err != nil || lines < 0 || lines > maxContextLines {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("error: lines must be between 0 and %d", maxContextLines))
+		return
+	}
+
+	index := multi.Resolutions[0]
+	firstLine, _ := lineColumn(index, offset)
+	lastLine, _ := lineColumn(index, offset+int64(max(length-1, 0)))
+	firstLine = max(firstLine-lines, 1)
+	lastLine = min(lastLine+lines, len(multi.LineOffsets))
+	start := multi.LineOffsets[firstLine-1]
+	end := multi.Size
+	if lastLine < len(multi.LineOffsets) {
+		end = multi.LineOffsets[lastLine]
+	}
+
+	text, err := getChunkContent(multi.FilePath, start, int(end-start))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, Context{
+		Document:  r.PathValue("id"),
+		Offset:    offset,
+		Length:    length,
+		FirstLine: firstLine,
+		Fragments: spanFragments(text, int(offset-start), int(offset-start)+length),
+	})
+}
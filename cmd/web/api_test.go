@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func apiRequest(t *testing.T, handler http.Handler, method, path, contentType string, body []byte, out any) int {
+	t.Helper()
+	req := httptest.NewRequest(method, path, bytes.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if out != nil && rec.Body.Len() > 0 {
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			t.Fatalf("%s %s: invalid JSON %q: %v", method, path, rec.Body.String(), err)
+		}
+	}
+	return rec.Code
+}
+
+func matchedText(fragments []Fragment) string {
+	var matched strings.Builder
+	for _, fragment := range fragments {
+		if fragment.Match {
+			matched.WriteString(fragment.Text)
+		}
+	}
+	return matched.String()
+}
+
+func TestAPI(t *testing.T) {
+	var err error
+	library, err = openLibrary(t.TempDir())
+	if err != nil {
+		t.Fatalf("openLibrary failed: %v", err)
+	}
+	defer func() { library = nil }()
+	handler := routes()
+
+	// The first two lines fill one 64-byte chunk each, the smallest resolution
+	firstLine := "first line here" + strings.Repeat(".", 48) + "\n"
+	lawLine := "law of the wolf." + strings.Repeat(" ", 47) + "\n"
+	content := firstLine + lawLine + "third line"
+	var raw Document
+	code := apiRequest(t, handler, "POST", "/api/v1/documents?title=Jungle", "text/plain", []byte(content), &raw)
+	if code != http.StatusCreated || raw.Title != "Jungle" || raw.ID == "" {
+		t.Fatalf("POST raw document = %d %+v", code, raw)
+	}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("file", "bears.txt")
+	part.Write([]byte("bears sleep all winter"))
+	form.Close()
+	var upload Document
+	if code := apiRequest(t, handler, "POST", "/api/v1/documents", form.FormDataContentType(), body.Bytes(), &upload); code != http.StatusCreated || upload.Title != "bears.txt" {
+		t.Fatalf("POST multipart document = %d %+v", code, upload)
+	}
+	var rejected map[string]apiError
+	if code := apiRequest(t, handler, "POST", "/api/v1/documents?async=true", form.FormDataContentType(), body.Bytes(), &rejected); code != http.StatusBadRequest {
+		t.Errorf("POST async multipart document = %d %+v, want 400", code, rejected)
+	}
+
+	var docs []Document
+	if code := apiRequest(t, handler, "GET", "/api/v1/documents", "", nil, &docs); code != http.StatusOK || len(docs) != 2 {
+		t.Errorf("GET documents = %d %+v", code, docs)
+	}
+	var got Document
+	if code := apiRequest(t, handler, "GET", "/api/v1/documents/"+raw.ID, "", nil, &got); code != http.StatusOK || got.ID != raw.ID {
+		t.Errorf("GET document = %d %+v", code, got)
+	}
+
+	var built IndexResponse
+	if code := apiRequest(t, handler, "POST", "/api/v1/documents/"+raw.ID+"/index", "", nil, &built); code != http.StatusOK || len(built.Resolutions) != len(resolutionSizes) {
+		t.Errorf("POST index = %d %+v", code, built)
+	}
+
+	var response SearchResponse
+	query, _ := json.Marshal(SearchRequest{Query: "law of the wolf.", Documents: []string{raw.ID}})
+	if code := apiRequest(t, handler, "POST", "/api/v1/search", "application/json", query, &response); code != http.StatusOK || len(response.Results) == 0 {
+		t.Fatalf("POST search = %d %+v", code, response)
+	}
+	hit := response.Results[0]
+	if hit.Document != raw.ID || hit.Line != 2 || hit.Snippet != "law of the wolf" || hit.Length != len(hit.Snippet) || hit.Offset != 64 || hit.Distance != 0 {
+		t.Errorf("Unexpected hit %+v", hit)
+	}
+
+	if marked := matchedText(hit.Fragments); marked != "law of the wolf" {
+		t.Errorf("Hit fragments mark %q", marked)
+	}
+
+	var context Context
+	path := fmt.Sprintf("/api/v1/documents/%s/context?offset=%d&length=%d&lines=1", raw.ID, hit.Offset, hit.Length)
+	if code := apiRequest(t, handler, "GET", path, "", nil, &context); code != http.StatusOK || context.FirstLine != 1 {
+		t.Fatalf("GET context = %d %+v", code, context)
+	}
+	var text strings.Builder
+	for _, fragment := range context.Fragments {
+		text.WriteString(fragment.Text)
+	}
+	if text.String() != content || matchedText(context.Fragments) != "law of the wolf" {
+		t.Errorf("Unexpected context %+v", context)
+	}
+	path = fmt.Sprintf("/api/v1/documents/%s/context?offset=%d&length=%d&lines=0", raw.ID, hit.Offset, hit.Length)
+	apiRequest(t, handler, "GET", path, "", nil, &context)
+	if context.FirstLine != 2 || len(context.Fragments) != 2 || context.Fragments[1].Text != lawLine[len("law of the wolf"):] {
+		t.Errorf("Context without surrounding lines = %+v", context)
+	}
+
+	query, _ = json.Marshal(SearchRequest{Query: "bears", Mode: "bm25"})
+	apiRequest(t, handler, "POST", "/api/v1/search", "application/json", query, &response)
+	if len(response.Results) != 1 || response.Results[0].Title != "bears.txt" || !strings.Contains(response.Results[0].Snippet, "bears") {
+		t.Errorf("bm25 search = %+v", response.Results)
+	}
+
+	errorCases := []struct {
+		method, path string
+		body         string
+		status       int
+	}{
+		{"GET", "/api/v1/documents/missing", "", http.StatusNotFound},
+		{"POST", "/api/v1/search", `{"query": "x", "mode": "vector"}`, http.StatusBadRequest},
+		{"POST", "/api/v1/search", `{"query": " "}`, http.StatusBadRequest},
+		{"POST", "/api/v1/search", `{"query": "x", "documents": ["missing"]}`, http.StatusNotFound},
+		{"POST", "/api/v1/search", `not json`, http.StatusBadRequest},
+		{"POST", "/api/v1/search", `{"query": "` + strings.Repeat("x", maxRequestSize) + `"}`, http.StatusRequestEntityTooLarge},
+		{"GET", "/api/v1/unknown", "", http.StatusNotFound},
+		{"GET", "/api/v1/documents/" + raw.ID + "/context?offset=-1", "", http.StatusBadRequest},
+		{"GET", fmt.Sprintf("/api/v1/documents/%s/context?offset=%d&length=10", raw.ID, len(content)-3), "", http.StatusBadRequest},
+		{"GET", "/api/v1/documents/" + raw.ID + "/context?offset=0&lines=500", "", http.StatusBadRequest},
+		{"GET", "/api/v1/documents/missing/context?offset=0", "", http.StatusNotFound},
+	}
+	for _, tc := range errorCases {
+		var failure map[string]apiError
+		code := apiRequest(t, handler, tc.method, tc.path, "application/json", []byte(tc.body), &failure)
+		if code != tc.status || failure["error"].Status != tc.status || failure["error"].Message == "" {
+			t.Errorf("%s %s = %d %+v, want %d with an error body", tc.method, tc.path, code, failure, tc.status)
+		}
+	}
+
+	if code := apiRequest(t, handler, "DELETE", "/api/v1/documents/"+upload.ID, "", nil, nil); code != http.StatusNoContent {
+		t.Errorf("DELETE document = %d", code)
+	}
+	if code := apiRequest(t, handler, "DELETE", "/api/v1/documents/"+upload.ID, "", nil, nil); code != http.StatusNotFound {
+		t.Errorf("Second DELETE = %d, want 404", code)
+	}
+	if docs := library.list(); len(docs) != 1 {
+		t.Errorf("Expected one document left, got %+v", docs)
+	}
+}
+
+func TestEmbeddedAssets(t *testing.T) {
+	var err error
+	library, err = openLibrary(t.TempDir())
+	if err != nil {
+		t.Fatalf("openLibrary failed: %v", err)
+	}
+	defer func() { library = nil }()
+	library.add("Jungle Book", strings.NewReader("law of the wolf"))
+	// Tests run from cmd/web, where ./cmd/web/static does not exist, so this only passes with the embedded assets
+	handler := routes()
+
+	for path, want := range map[string]string{
+		"/":                 "Jungle Book",
+		"/static/app.js":    "function openContext",
+		"/static/style.css": "#contextPanel",
+	} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("GET %s = %d, want a body containing %q", path, rec.Code, want)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/favicon.ico", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("GET /favicon.ico = %d, want 404", rec.Code)
+	}
+}
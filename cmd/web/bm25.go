@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/mfonda/simhash"
+
+	"trufast/internal/textsearch"
+)
+
+const (
+	hybridWeight = 0.7
+	maxResults   = 10
+)
+
+var errNoTermMatch = errors.New("no chunk contains the query terms")
+
+type SearchResult struct {
+	Chunk ChunkInfo
+	Score float64
+}
+
+func searchIndexWeb(index *Index, query string, mode string) (string, error) {
+	if strings.TrimSpace(query) == "" {
+		return "", fmt.Errorf("error: query text is required")
+	}
+
+	var results []SearchResult
+	switch mode {
+	case "bm25":
+		results = bm25Search(index, query)
+	case "hybrid":
+		results = hybridSearch(index, query)
+	default:
+		return "", fmt.Errorf("unknown search mode %q", mode)
+	}
+
+	if len(results) == 0 {
+		return "", errNoTermMatch
+	}
+	if len(results) > maxResults {
+		results = results[:maxResults]
+	}
+
+	var content []string
+	for i, result := range results {
+		conten, err := getChunkContent(index.FilePath, result.Chunk.Offset, result.Chunk.Size)
+		if err != nil {
+			return "", err
+		}
+		conten = html.EscapeString(conten)
+
+		line, column := lineColumn(index, result.Chunk.Offset)
+		conten += fmt.Sprintf("\n#%d score: %.4f, byte offset: %d (line %d, column %d)\n", i+1, result.Score, result.Chunk.Offset, line, column)
+
+		if i < len(results)-1 {
+			conten += "\n---\n"
+		}
+		content = append(content, conten)
+	}
+
+	con := fmt.Sprintf("\n--\nShowing %d ranked chunk(s).\n", len(results))
+	content = append(content, con)
+	return strings.Join(content, " "), nil
+}
+
+func bm25Scores(index *Index, query string) map[int]float64 {
+	length := func(chunk int) int { return index.Chunks[chunk].Terms }
+	return textsearch.BM25Scores(index.Postings, len(index.Chunks), length, query, nil)
+}
+
+func bm25Search(index *Index, query string) []SearchResult {
+	scores := bm25Scores(index, query)
+	results := make([]SearchResult, 0, len(scores))
+	for chunkIdx, score := range scores {
+		results = append(results, SearchResult{Chunk: index.Chunks[chunkIdx], Score: score})
+	}
+	sortResults(results)
+	return results
+}
+
+func hybridSearch(index *Index, query string) []SearchResult {
+	scores := bm25Scores(index, query)
+	queryHash := simhash.Simhash(simhash.NewWordFeatureSet([]byte(query)))
+
+	var maxScore float64
+	for _, score := range scores {
+		maxScore = math.Max(maxScore, score)
+	}
+
+	candidates := make(map[int]bool, len(scores))
+	for chunkIdx := range scores {
+		candidates[chunkIdx] = true
+	}
+	for hash, chunkIndices := range index.HashToChunks {
+		if HammingDistance(queryHash, hash) <= maxHammingDistance {
+			for _, chunkIdx := range chunkIndices {
+				candidates[chunkIdx] = true
+			}
+		}
+	}
+
+	results := make([]SearchResult, 0, len(candidates))
+	for chunkIdx := range candidates {
+		chunk := index.Chunks[chunkIdx]
+		var keyword float64
+		if maxScore > 0 {
+			keyword = scores[chunkIdx] / maxScore
+		}
+		similarity := 1 - float64(HammingDistance(queryHash, chunk.Hash))/64
+		results = append(results, SearchResult{
+			Chunk: chunk,
+			Score: hybridWeight*keyword + (1-hybridWeight)*similarity,
+		})
+	}
+	sortResults(results)
+	return results
+}
+
+func sortResults(results []SearchResult) {
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Chunk.Offset < results[j].Chunk.Offset
+	})
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"trufast/internal/reporting"
+)
+
+var exportTypes = map[string]string{
+	"json":     "application/json",
+	"html":     "text/html; charset=utf-8",
+	"markdown": "text/markdown; charset=utf-8",
+	"csv":      "text/csv; charset=utf-8",
+}
+
+var exportExtensions = map[string]string{"json": "json", "html": "html", "markdown": "md", "csv": "csv"}
+
+func exportFormat(r *http.Request) (string, error) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		return "json", nil
+	}
+	if _, ok := exportTypes[format]; !ok {
+		return "", fmt.Errorf("unknown report format %q (expected json, html, markdown or csv)", format)
+	}
+	return format, nil
+}
+
+func writeExport(w http.ResponseWriter, format string, name string, export reporting.Table) {
+	w.Header().Set("Content-Type", exportTypes[format])
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+"."+exportExtensions[format]))
+	w.WriteHeader(http.StatusOK)
+
+	if err := reporting.Write(w, format, export); err != nil {
+		loggerErr.Println(err)
+	}
+}
+
+func searchExport(response SearchResponse) reporting.Table {
+	export := reporting.Table{
+		Title:     "Search report",
+		Generated: time.Now(),
+		Summary:   [][2]string{{"Query", response.Query}, {"Mode", response.Mode}, {"Results", strconv.Itoa(len(response.Results))}},
+		Columns:   []string{"#", "Document", "Title", "Line", "Column", "Offset", "Length", "Score", "Distance", "Snippet"},
+	}
+	for i, hit := range response.Results {
+		export.Rows = append(export.Rows, []string{
+			strconv.Itoa(i + 1),
+			hit.Document,
+			hit.Title,
+			strconv.Itoa(hit.Line),
+			strconv.Itoa(hit.Column),
+			strconv.FormatInt(hit.Offset, 10),
+			strconv.Itoa(hit.Length),
+			strconv.FormatFloat(hit.Score, 'f', 3, 64),
+			strconv.Itoa(hit.Distance),
+			hit.Snippet,
+		})
+	}
+	return export
+}
+
+func reportExport(report *Report) reporting.Table {
+	var text strings.Builder
+	for _, fragment := range report.Fragments {
+		text.WriteString(fragment.Text)
+	}
+	suspect := text.String()
+
+	export := reporting.Table{
+		Title:     "Plagiarism report: " + report.Suspect.Title,
+		Generated: time.Now(),
+		Summary: [][2]string{
+			{"Suspect", fmt.Sprintf("%s (%s)", report.Suspect.Title, report.Suspect.ID)},
+			{"Overlap", fmt.Sprintf("%.1f%% (%d of %d bytes)", report.Overlap, report.MatchedBytes, report.Suspect.Size)},
+		},
+		Columns: []string{"#", "Source", "Title", "Suspect bytes", "Source bytes", "Words", "Text"},
+	}
+	titles := make(map[string]string)
+	for _, source := range report.Sources {
+		titles[source.Document.ID] = source.Document.Title
+		export.Summary = append(export.Summary, [2]string{
+			"Source " + source.Document.Title,
+			fmt.Sprintf("%.1f%% in %d passage(s)", source.Overlap, source.Regions),
+		})
+	}
+	for _, region := range report.Regions {
+		export.Rows = append(export.Rows, []string{
+			strconv.Itoa(region.ID),
+			region.Source,
+			titles[region.Source],
+			fmt.Sprintf("%d-%d", region.Start, region.End),
+			fmt.Sprintf("%d-%d", region.SourceStart, region.SourceEnd),
+			strconv.Itoa(region.Words),
+			suspect[region.Start:region.End],
+		})
+	}
+	return export
+}
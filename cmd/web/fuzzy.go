@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+
+	"trufast/internal/textsearch"
+)
+
+var errNoWordMatch = errors.New("no indexed word is within edit distance")
+
+type FuzzyResult struct {
+	Chunk ChunkInfo
+	Score float64
+	Terms []string
+}
+
+func fuzzyIndexWeb(index *Index, query string, maxDistance int) (string, error) {
+	if strings.TrimSpace(query) == "" {
+		return "", fmt.Errorf("error: query text is required")
+	}
+
+	results, matches := fuzzySearch(index, query, maxDistance)
+	if len(results) == 0 {
+		return "", fmt.Errorf("%w %d of the query", errNoWordMatch, maxDistance)
+	}
+	if len(results) > maxResults {
+		results = results[:maxResults]
+	}
+
+	var content []string
+	for _, term := range textsearch.UniqueTerms(query) {
+		var found []string
+		for _, match := range matches[term] {
+			found = append(found, fmt.Sprintf("%s (%d)", match.Term, match.Distance))
+		}
+		if len(found) == 0 {
+			found = []string{"no match"}
+		}
+		content = append(content, html.EscapeString(fmt.Sprintf("%s => %s\n", term, strings.Join(found, ", "))))
+	}
+
+	for i, result := range results {
+		conten, err := getChunkContent(index.FilePath, result.Chunk.Offset, result.Chunk.Size)
+		if err != nil {
+			return "", err
+		}
+		conten = html.EscapeString(conten)
+
+		conten = "\n---\n" + conten
+		line, column := lineColumn(index, result.Chunk.Offset)
+		conten += fmt.Sprintf("\n#%d score: %.4f, byte offset: %d (line %d, column %d), terms: %s\n", i+1, result.Score, result.Chunk.Offset, line, column, strings.Join(result.Terms, ", "))
+		content = append(content, conten)
+	}
+
+	con := fmt.Sprintf("\n--\nShowing %d chunk(s) with fuzzy matches.\n", len(results))
+	content = append(content, con)
+	return strings.Join(content, " "), nil
+}
+
+func fuzzySearch(index *Index, query string, maxDistance int) ([]FuzzyResult, map[string][]textsearch.TermMatch) {
+	matches := textsearch.Fuzzy(index.terms, index.Postings, query, maxDistance, nil)
+
+	results := make([]FuzzyResult, 0, len(matches.Scores))
+	for chunkIdx, score := range matches.Scores {
+		results = append(results, FuzzyResult{Chunk: index.Chunks[chunkIdx], Score: score, Terms: matches.Terms[chunkIdx]})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Chunk.Offset < results[j].Chunk.Offset
+	})
+	return results, matches.Matches
+}
+
+func indexVocabulary(index *Index) []string {
+	if len(index.Vocabulary) > 0 {
+		return index.Vocabulary
+	}
+	return textsearch.BuildVocabulary(index.Postings)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"html"
+
+	"trufast/internal/textsearch"
+)
+
+type MatchSpan struct {
+	Start int64
+	End   int64
+	Score int
+}
+
+func locateMatch(index *Index, chunk ChunkInfo, query string) (MatchSpan, bool, error) {
+	windowStart := max(chunk.Offset-int64(index.ChunkSize), 0)
+	windowSize := int(chunk.Offset-windowStart) + chunk.Size + index.ChunkSize
+	window, err := getChunkContent(index.FilePath, windowStart, windowSize)
+	if err != nil {
+		return MatchSpan{}, false, err
+	}
+
+	start, end, score := textsearch.Align(query, window)
+	if score <= 0 {
+		return MatchSpan{}, false, nil
+	}
+	return MatchSpan{Start: windowStart + int64(start), End: windowStart + int64(end), Score: score}, true, nil
+}
+
+func highlightRegionHTML(index *Index, chunk ChunkInfo, span MatchSpan) (string, error) {
+	regionStart := min(chunk.Offset, span.Start)
+	regionEnd := max(chunk.Offset+int64(chunk.Size), span.End)
+	region, err := getChunkContent(index.FilePath, regionStart, int(regionEnd-regionStart))
+	if err != nil {
+		return "", err
+	}
+
+	from := min(int(span.Start-regionStart), len(region))
+	to := min(int(span.End-regionStart), len(region))
+	return html.EscapeString(region[:from]) + "<mark>" + html.EscapeString(region[from:to]) + "</mark>" + html.EscapeString(region[to:]), nil
+}
+
+type Fragment struct {
+	Text  string `json:"text"`
+	Match bool   `json:"match,omitempty"`
+}
+
+func spanFragments(text string, from, to int) []Fragment {
+	from = min(max(from, 0), len(text))
+	to = min(max(to, from), len(text))
+	var fragments []Fragment
+	for _, fragment := range []Fragment{{Text: text[:from]}, {Text: text[from:to], Match: true}, {Text: text[to:]}} {
+		if fragment.Text != "" {
+			fragments = append(fragments, fragment)
+		}
+	}
+	return fragments
+}
+
+func termFragments(text string, terms map[string]bool) []Fragment {
+	var fragments []Fragment
+	last := 0
+	for _, token := range textsearch.TokenizeWithOffsets(text) {
+		if !terms[token.Term] {
+			continue
+		}
+		if token.Start > last {
+			fragments = append(fragments, Fragment{Text: text[last:token.Start]})
+		}
+		fragments = append(fragments, Fragment{Text: text[token.Start:token.End], Match: true})
+		last = token.End
+	}
+	if last < len(text) {
+		fragments = append(fragments, Fragment{Text: text[last:]})
+	}
+	return fragments
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	jobQueued    = "queued"
+	jobRunning   = "running"
+	jobDone      = "done"
+	jobFailed    = "failed"
+	jobCancelled = "cancelled"
+)
+
+const (
+	jobRetention    = time.Hour
+	maxFinishedJobs = 256
+)
+
+var (
+	errJobNotFound = errors.New("job not found")
+	errJobFinished = errors.New("job has already finished")
+)
+
+type JobStatus struct {
+	ID         string    `json:"id"`
+	Document   string    `json:"document,omitempty"`
+	Title      string    `json:"title"`
+	Status     string    `json:"status"`
+	Error      string    `json:"error,omitempty"`
+	Bytes      int64     `json:"bytes"`
+	Total      int64     `json:"total"`
+	Chunks     int       `json:"chunks"`
+	ETASeconds float64   `json:"eta_seconds"`
+	Created    time.Time `json:"created"`
+	Started    time.Time `json:"started,omitzero"`
+	Finished   time.Time `json:"finished,omitzero"`
+}
+
+type indexTask func(ctx context.Context, progress func(int64, int)) (Document, error)
+
+type Job struct {
+	status      JobStatus
+	task        indexTask
+	cleanup     func()
+	ctx         context.Context
+	cancel      context.CancelFunc
+	subscribers map[chan struct{}]bool
+}
+
+type JobQueue struct {
+	mu        sync.Mutex
+	jobs      map[string]*Job
+	pending   chan *Job
+	retention time.Duration
+	keep      int
+}
+
+var jobs *JobQueue
+
+func newJobQueue(workers int) *JobQueue {
+	q := &JobQueue{jobs: make(map[string]*Job), pending: make(chan *Job, 1024), retention: jobRetention, keep: maxFinishedJobs}
+	for range workers {
+		go q.work()
+	}
+	return q
+}
+
+// submit queues task; cleanup, if not nil, releases what the task would have
+// consumed (such as a staged upload) when the task fails or never runs
+func (q *JobQueue) submit(title string, total int64, task indexTask, cleanup func()) (JobStatus, error) {
+	id, err := newDocumentID()
+	if err != nil {
+		return JobStatus{}, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &Job{
+		status:      JobStatus{ID: id, Title: title, Status: jobQueued, Total: total, Created: time.Now().UTC()},
+		task:        task,
+		cleanup:     cleanup,
+		ctx:         ctx,
+		cancel:      cancel,
+		subscribers: make(map[chan struct{}]bool),
+	}
+
+	q.mu.Lock()
+	q.prune()
+	q.jobs[id] = job
+	q.mu.Unlock()
+
+	select {
+	case q.pending <- job:
+	default:
+		q.finish(job, Document{}, fmt.Errorf("error: the indexing queue is full"))
+		job.discard()
+	}
+	return q.get(id)
+}
+
+func (q *JobQueue) work() {
+	for job := range q.pending {
+		q.mu.Lock()
+		if job.status.Status != jobQueued {
+			// Cancelled while queued; cancel has already discarded it
+			q.mu.Unlock()
+			continue
+		}
+		job.status.Status = jobRunning
+		job.status.Started = time.Now().UTC()
+		q.notify(job)
+		q.mu.Unlock()
+
+		doc, err := job.task(job.ctx, func(bytes int64, chunks int) {
+			q.update(job, bytes, chunks)
+		})
+		if err != nil {
+			job.discard()
+		}
+		q.finish(job, doc, err)
+	}
+}
+
+func (q *JobQueue) update(job *Job, bytes int64, chunks int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job.status.Bytes, job.status.Chunks = bytes, chunks
+	if bytes > 0 && job.status.Total > bytes {
+		elapsed := time.Since(job.status.Started).Seconds()
+		job.status.ETASeconds = elapsed * float64(job.status.Total-bytes) / float64(bytes)
+	} else {
+		job.status.ETASeconds = 0
+	}
+	q.notify(job)
+}
+
+func (q *JobQueue) finish(job *Job, doc Document, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.finishLocked(job, doc, err)
+}
+
+func (q *JobQueue) finishLocked(job *Job, doc Document, err error) {
+	job.status.Finished = time.Now().UTC()
+	job.status.ETASeconds = 0
+	switch {
+	case errors.Is(err, context.Canceled):
+		job.status.Status = jobCancelled
+	case err != nil:
+		job.status.Status = jobFailed
+		job.status.Error = err.Error()
+	default:
+		job.status.Status = jobDone
+		job.status.Document = doc.ID
+		job.status.Title = doc.Title
+	}
+	job.cancel()
+	q.notify(job)
+}
+
+func (q *JobQueue) notify(job *Job) {
+	for ch := range job.subscribers {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (q *JobQueue) get(id string) (JobStatus, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job, ok := q.jobs[id]
+	if !ok {
+		return JobStatus{}, fmt.Errorf("%w: %s", errJobNotFound, id)
+	}
+	return job.status, nil
+}
+
+func (q *JobQueue) list() []JobStatus {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	statuses := make([]JobStatus, 0, len(q.jobs))
+	for _, job := range q.jobs {
+		statuses = append(statuses, job.status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Created.Before(statuses[j].Created) })
+	return statuses
+}
+
+func (q *JobQueue) cancel(id string) (JobStatus, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job, ok := q.jobs[id]
+	if !ok {
+		return JobStatus{}, fmt.Errorf("%w: %s", errJobNotFound, id)
+	}
+
+	switch job.status.Status {
+	case jobQueued:
+		q.finishLocked(job, Document{}, context.Canceled)
+		job.discard()
+	case jobRunning:
+		job.cancel()
+	default:
+		return JobStatus{}, fmt.Errorf("%w: %s", errJobFinished, id)
+	}
+	return job.status, nil
+}
+
+func (q *JobQueue) subscribe(id string) (<-chan struct{}, func(), error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job, ok := q.jobs[id]
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: %s", errJobNotFound, id)
+	}
+	ch := make(chan struct{}, 1)
+	job.subscribers[ch] = true
+	return ch, func() {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		delete(job.subscribers, ch)
+	}, nil
+}
+
+func (job *Job) discard() {
+	if job.cleanup != nil {
+		job.cleanup()
+	}
+}
+
+// prune forgets finished jobs older than the retention period and, beyond the
+// newest q.keep, any other finished job; q.mu must be held
+func (q *JobQueue) prune() {
+	var finished []*Job
+	for id, job := range q.jobs {
+		if !job.status.terminal() {
+			continue
+		}
+		if time.Since(job.status.Finished) > q.retention {
+			delete(q.jobs, id)
+			continue
+		}
+		finished = append(finished, job)
+	}
+	if len(finished) <= q.keep {
+		return
+	}
+	sort.Slice(finished, func(i, j int) bool { return finished[i].status.Finished.After(finished[j].status.Finished) })
+	for _, job := range finished[q.keep:] {
+		delete(q.jobs, job.status.ID)
+	}
+}
+
+func (status JobStatus) terminal() bool {
+	return status.Status == jobDone || status.Status == jobFailed || status.Status == jobCancelled
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func waitForJob(t *testing.T, id string) JobStatus {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		job, err := jobs.get(id)
+		if err != nil {
+			t.Fatalf("get job failed: %v", err)
+		}
+		if job.terminal() {
+			return job
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("Job %s did not finish", id)
+	return JobStatus{}
+}
+
+func TestAsyncIndexing(t *testing.T) {
+	var err error
+	library, err = openLibrary(t.TempDir())
+	if err != nil {
+		t.Fatalf("openLibrary failed: %v", err)
+	}
+	jobs = newJobQueue(1)
+	defer func() { library, jobs = nil, nil }()
+	server := httptest.NewServer(routes())
+	defer server.Close()
+
+	content := strings.Repeat("wolves hunt in packs across the frozen plains\n", 500)
+	resp, err := http.Post(server.URL+"/api/v1/documents?async=true&title=Wolves", "application/octet-stream", strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("POST failed: %v", err)
+	}
+	var job JobStatus
+	json.NewDecoder(resp.Body).Decode(&job)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted || job.ID == "" || job.Total != int64(len(content)) || resp.Header.Get("Location") != "/api/v1/jobs/"+job.ID {
+		t.Fatalf("Async POST = %d %+v", resp.StatusCode, job)
+	}
+
+	// The event stream ends with the final status
+	resp, err = http.Get(server.URL + "/api/v1/jobs/" + job.ID + "/events")
+	if err != nil {
+		t.Fatalf("GET events failed: %v", err)
+	}
+	if resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Errorf("Unexpected content type %q", resp.Header.Get("Content-Type"))
+	}
+	var last string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if event, ok := strings.CutPrefix(line, "event: "); ok {
+			last = event
+		}
+		if data, ok := strings.CutPrefix(line, "data: "); ok {
+			json.Unmarshal([]byte(data), &job)
+		}
+	}
+	resp.Body.Close()
+	if last != jobDone || job.Bytes != int64(len(content)) || job.Chunks == 0 || job.Document == "" {
+		t.Fatalf("Last event %q with %+v, want a finished job", last, job)
+	}
+
+	doc, err := library.document(job.Document)
+	if err != nil || doc.Title != "Wolves" || doc.Size != int64(len(content)) {
+		t.Errorf("Indexed document = %+v, %v", doc, err)
+	}
+
+	// Rebuilding an index can run as a job too
+	resp, err = http.Post(server.URL+"/api/v1/documents/"+doc.ID+"/index?async=true", "", nil)
+	if err != nil {
+		t.Fatalf("POST index failed: %v", err)
+	}
+	json.NewDecoder(resp.Body).Decode(&job)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		t.Errorf("Async index = %d", resp.StatusCode)
+	}
+	if job = waitForJob(t, job.ID); job.Status != jobDone || job.Document != doc.ID {
+		t.Errorf("Rebuild job = %+v", job)
+	}
+
+	var statuses []JobStatus
+	resp, _ = http.Get(server.URL + "/api/v1/jobs")
+	json.NewDecoder(resp.Body).Decode(&statuses)
+	resp.Body.Close()
+	if len(statuses) != 2 {
+		t.Errorf("Expected two jobs, got %+v", statuses)
+	}
+
+	resp, _ = http.Get(server.URL + "/api/v1/jobs/missing/events")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Events of a missing job = %d", resp.StatusCode)
+	}
+}
+
+func TestJobCancellation(t *testing.T) {
+	var err error
+	library, err = openLibrary(t.TempDir())
+	if err != nil {
+		t.Fatalf("openLibrary failed: %v", err)
+	}
+	jobs = newJobQueue(1)
+	defer func() { library, jobs = nil, nil }()
+
+	started := make(chan struct{})
+	running, _ := jobs.submit("blocking", 100, func(ctx context.Context, progress func(int64, int)) (Document, error) {
+		progress(25, 3)
+		close(started)
+		<-ctx.Done()
+		return Document{}, ctx.Err()
+	}, nil)
+	<-started
+
+	// The single worker is busy, so the staged upload waits in the queue
+	id, partPath, size, err := library.stage(strings.NewReader("bears sleep all winter"))
+	if err != nil {
+		t.Fatalf("stage failed: %v", err)
+	}
+	staging := library
+	queued, _ := jobs.submit("queued", size, func(ctx context.Context, progress func(int64, int)) (Document, error) {
+		return staging.addStaged(ctx, id, "queued", partPath, progress)
+	}, func() { os.Remove(partPath) })
+	if queued.Status != jobQueued {
+		t.Fatalf("Expected a queued job, got %+v", queued)
+	}
+
+	status, _ := jobs.get(running.ID)
+	if status.Status != jobRunning || status.Bytes != 25 || status.Chunks != 3 || status.ETASeconds <= 0 {
+		t.Errorf("Running job = %+v, want progress and an ETA", status)
+	}
+
+	if status, err := jobs.cancel(queued.ID); err != nil || status.Status != jobCancelled {
+		t.Errorf("Cancelling a queued job = %+v, %v", status, err)
+	}
+	if _, err := jobs.cancel(running.ID); err != nil {
+		t.Errorf("Cancelling a running job failed: %v", err)
+	}
+	if status := waitForJob(t, running.ID); status.Status != jobCancelled {
+		t.Errorf("Cancelled job = %+v", status)
+	}
+	if _, err := jobs.cancel(running.ID); !errors.Is(err, errJobFinished) {
+		t.Errorf("Expected errJobFinished, got %v", err)
+	}
+
+	// Cancelling the queued job removes its staged file straight away
+	if _, err := os.Stat(partPath); !os.IsNotExist(err) {
+		t.Errorf("Staged file of a cancelled job was left behind")
+	}
+	if len(library.list()) != 0 {
+		t.Errorf("Cancelled job added a document")
+	}
+}
+
+func TestJobPruning(t *testing.T) {
+	jobs = newJobQueue(1)
+	jobs.keep = 2
+	defer func() { jobs = nil }()
+
+	var ids []string
+	for range 4 {
+		job, err := jobs.submit("quick", 0, func(ctx context.Context, progress func(int64, int)) (Document, error) {
+			return Document{}, nil
+		}, nil)
+		if err != nil {
+			t.Fatalf("submit failed: %v", err)
+		}
+		waitForJob(t, job.ID)
+		ids = append(ids, job.ID)
+	}
+
+	// Submitting forgets the oldest finished jobs beyond the newest two
+	jobs.submit("last", 0, func(ctx context.Context, progress func(int64, int)) (Document, error) {
+		return Document{}, nil
+	}, nil)
+	for i, id := range ids {
+		_, err := jobs.get(id)
+		if kept := i >= len(ids)-2; kept != (err == nil) {
+			t.Errorf("Job %d kept = %v, want %v", i, err == nil, kept)
+		}
+	}
+
+	jobs.retention = 0
+	jobs.submit("after retention", 0, func(ctx context.Context, progress func(int64, int)) (Document, error) {
+		return Document{}, nil
+	}, nil)
+	for _, id := range ids {
+		if _, err := jobs.get(id); !errors.Is(err, errJobNotFound) {
+			t.Errorf("Job %s outlived the retention period", id)
+		}
+	}
+}
@@ -0,0 +1,364 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+)
+
+var (
+	errDocumentNotFound  = errors.New("document not found in the library")
+	errInvalidDocumentID = errors.New("document IDs may only contain letters, digits, '-' and '_'")
+)
+
+var documentIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+type Document struct {
+	ID       string    `json:"id"`
+	Title    string    `json:"title"`
+	Uploaded time.Time `json:"uploaded"`
+	Size     int64     `json:"size"`
+	Checksum string    `json:"checksum"`
+}
+
+type Library struct {
+	Dir       string
+	Documents []Document
+	mu        sync.RWMutex
+	indexes   map[string]*MultiIndex
+}
+
+func openLibrary(dir string) (*Library, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("error creating library directory: %w", err)
+	}
+	library := &Library{Dir: dir, indexes: make(map[string]*MultiIndex)}
+
+	file, err := os.Open(library.catalogPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return library, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error opening library catalog: %w", err)
+	}
+	defer file.Close()
+
+	if err := gob.NewDecoder(file).Decode(&library.Documents); err != nil {
+		return nil, fmt.Errorf("error decoding library catalog: %w", err)
+	}
+	return library, nil
+}
+
+func (l *Library) catalogPath() string {
+	return filepath.Join(l.Dir, "library.gob")
+}
+
+func (l *Library) textPath(id string) string {
+	return filepath.Join(l.Dir, id+".txt")
+}
+
+func (l *Library) indexPath(id string) string {
+	return filepath.Join(l.Dir, id+".idx")
+}
+
+func (l *Library) save() error {
+	file, err := os.Create(l.catalogPath())
+	if err != nil {
+		return fmt.Errorf("error creating library catalog: %w", err)
+	}
+	defer file.Close()
+
+	if err := gob.NewEncoder(file).Encode(l.Documents); err != nil {
+		return fmt.Errorf("error encoding library catalog: %w", err)
+	}
+	return nil
+}
+
+func (l *Library) add(title string, content io.Reader) (Document, error) {
+	id, err := newDocumentID()
+	if err != nil {
+		return Document{}, err
+	}
+
+	multi, partPath, err := l.ingest(id, content)
+	if err != nil {
+		return Document{}, err
+	}
+	return l.register(id, title, multi, partPath)
+}
+
+func (l *Library) stage(content io.Reader) (string, string, int64, error) {
+	id, err := newDocumentID()
+	if err != nil {
+		return "", "", 0, err
+	}
+	out, err := os.CreateTemp(l.Dir, id+"-*.part")
+	if err != nil {
+		return "", "", 0, fmt.Errorf("error creating document file: %w", err)
+	}
+	size, err := io.Copy(out, content)
+	if closeErr := out.Close(); err == nil && closeErr != nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(out.Name())
+		return "", "", 0, fmt.Errorf("error writing document file: %w", err)
+	}
+	return id, out.Name(), size, nil
+}
+
+func (l *Library) addStaged(ctx context.Context, id string, title string, partPath string, progress func(int64, int)) (Document, error) {
+	file, err := os.Open(partPath)
+	if err != nil {
+		return Document{}, fmt.Errorf("error opening document file: %w", err)
+	}
+	multi, err := buildMultiIndexContext(ctx, file, l.textPath(id), resolutionSizes, progress)
+	file.Close()
+	if err != nil {
+		os.Remove(partPath)
+		return Document{}, err
+	}
+	return l.register(id, title, multi, partPath)
+}
+
+func (l *Library) register(id string, title string, multi *MultiIndex, partPath string) (Document, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, doc := range l.Documents {
+		if doc.Checksum == multi.Checksum {
+			os.Remove(partPath)
+			return doc, nil
+		}
+	}
+
+	if title == "" {
+		title = id
+	}
+	doc := Document{ID: id, Title: title, Uploaded: time.Now().UTC(), Size: multi.Size, Checksum: multi.Checksum}
+	if err := l.commit(doc, multi, partPath); err != nil {
+		return Document{}, err
+	}
+	return doc, nil
+}
+
+func (l *Library) put(id string, title string, content io.Reader) (Document, bool, error) {
+	if !documentIDPattern.MatchString(id) {
+		return Document{}, false, fmt.Errorf("%w: %q", errInvalidDocumentID, id)
+	}
+
+	multi, partPath, err := l.ingest(id, content)
+	if err != nil {
+		return Document{}, false, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	doc := Document{ID: id, Title: title, Uploaded: time.Now().UTC(), Size: multi.Size, Checksum: multi.Checksum}
+	created := true
+	for _, existing := range l.Documents {
+		if existing.ID == id {
+			created = false
+			if doc.Title == "" {
+				doc.Title = existing.Title
+			}
+		}
+	}
+	if doc.Title == "" {
+		doc.Title = id
+	}
+	if err := l.commit(doc, multi, partPath); err != nil {
+		return Document{}, false, err
+	}
+	return doc, created, nil
+}
+
+func (l *Library) ingest(id string, content io.Reader) (*MultiIndex, string, error) {
+	out, err := os.CreateTemp(l.Dir, id+"-*.part")
+	if err != nil {
+		return nil, "", fmt.Errorf("error creating document file: %w", err)
+	}
+	multi, err := buildMultiIndex(io.TeeReader(content, out), l.textPath(id), resolutionSizes)
+	if closeErr := out.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("error writing document file: %w", closeErr)
+	}
+	if err != nil {
+		os.Remove(out.Name())
+		return nil, "", err
+	}
+	return multi, out.Name(), nil
+}
+
+func (l *Library) commit(doc Document, multi *MultiIndex, partPath string) error {
+	if err := os.Rename(partPath, l.textPath(doc.ID)); err != nil {
+		os.Remove(partPath)
+		return fmt.Errorf("error storing document file: %w", err)
+	}
+	if err := saveIndex(multi, l.indexPath(doc.ID)); err != nil {
+		return err
+	}
+
+	previous := append([]Document(nil), l.Documents...)
+	replaced := false
+	for i, existing := range l.Documents {
+		if existing.ID == doc.ID {
+			l.Documents[i], replaced = doc, true
+		}
+	}
+	if !replaced {
+		l.Documents = append(l.Documents, doc)
+	}
+	if err := l.save(); err != nil {
+		l.Documents = previous
+		return err
+	}
+	l.indexes[doc.ID] = multi
+	return nil
+}
+
+func (l *Library) list() []Document {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return append([]Document(nil), l.Documents...)
+}
+
+func (l *Library) index(id string) (*MultiIndex, error) {
+	l.mu.RLock()
+	multi, ok := l.indexes[id]
+	l.mu.RUnlock()
+	if ok {
+		return multi, nil
+	}
+	if _, err := l.document(id); err != nil {
+		return nil, err
+	}
+
+	multi, err := loadIndex(l.indexPath(id))
+	if err != nil {
+		return nil, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if cached, ok := l.indexes[id]; ok {
+		return cached, nil
+	}
+	l.indexes[id] = multi
+	return multi, nil
+}
+
+func (l *Library) remove(id string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i, doc := range l.Documents {
+		if doc.ID != id {
+			continue
+		}
+		l.Documents = append(l.Documents[:i:i], l.Documents[i+1:]...)
+		if err := l.save(); err != nil {
+			l.Documents = append(l.Documents[:i:i], append([]Document{doc}, l.Documents[i:]...)...)
+			return err
+		}
+		delete(l.indexes, id)
+		os.Remove(l.indexPath(id))
+		os.Remove(l.textPath(id))
+		return nil
+	}
+	return fmt.Errorf("%w: %s", errDocumentNotFound, id)
+}
+
+func (l *Library) reindex(id string) (Document, *MultiIndex, error) {
+	return l.reindexContext(context.Background(), id, nil)
+}
+
+func (l *Library) reindexContext(ctx context.Context, id string, progress func(int64, int)) (Document, *MultiIndex, error) {
+	if _, err := l.document(id); err != nil {
+		return Document{}, nil, err
+	}
+	file, err := os.Open(l.textPath(id))
+	if err != nil {
+		return Document{}, nil, fmt.Errorf("%w. Check the file path and try again", err)
+	}
+	multi, err := buildMultiIndexContext(ctx, file, l.textPath(id), resolutionSizes, progress)
+	file.Close()
+	if err != nil {
+		return Document{}, nil, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i, doc := range l.Documents {
+		if doc.ID != id {
+			continue
+		}
+		if err := saveIndex(multi, l.indexPath(id)); err != nil {
+			return Document{}, nil, err
+		}
+		l.Documents[i].Size, l.Documents[i].Checksum = multi.Size, multi.Checksum
+		if err := l.save(); err != nil {
+			return Document{}, nil, err
+		}
+		l.indexes[id] = multi
+		return l.Documents[i], multi, nil
+	}
+	return Document{}, nil, fmt.Errorf("%w: %s", errDocumentNotFound, id)
+}
+
+func (l *Library) retitle(id string, title string) (Document, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i, doc := range l.Documents {
+		if doc.ID != id {
+			continue
+		}
+		l.Documents[i].Title = title
+		if err := l.save(); err != nil {
+			l.Documents[i].Title = doc.Title
+			return Document{}, err
+		}
+		return l.Documents[i], nil
+	}
+	return Document{}, fmt.Errorf("%w: %s", errDocumentNotFound, id)
+}
+
+func (l *Library) document(id string) (Document, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	for _, doc := range l.Documents {
+		if doc.ID == id {
+			return doc, nil
+		}
+	}
+	return Document{}, fmt.Errorf("%w: %s", errDocumentNotFound, id)
+}
+
+func (l *Library) selection(ids []string) ([]Document, error) {
+	if len(ids) == 0 {
+		return l.list(), nil
+	}
+	docs := make([]Document, 0, len(ids))
+	for _, id := range ids {
+		doc, err := l.document(id)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
+
+func newDocumentID() (string, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("error generating document id: %w", err)
+	}
+	return hex.EncodeToString(id), nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestLibrary(t *testing.T) {
+	dir := t.TempDir()
+	library, err := openLibrary(dir)
+	if err != nil {
+		t.Fatalf("openLibrary failed: %v", err)
+	}
+
+	wolves, err := library.add("Wolves", strings.NewReader("wolves hunt in packs across the frozen plains"))
+	if err != nil {
+		t.Fatalf("add failed: %v", err)
+	}
+	bears, err := library.add("Bears", strings.NewReader("bears sleep all winter in their dens"))
+	if err != nil {
+		t.Fatalf("add failed: %v", err)
+	}
+	if wolves.ID == bears.ID || wolves.Uploaded.IsZero() || wolves.Size == 0 {
+		t.Fatalf("Unexpected documents %+v %+v", wolves, bears)
+	}
+
+	again, err := library.add("Wolves again", strings.NewReader("wolves hunt in packs across the frozen plains"))
+	if err != nil || again.ID != wolves.ID {
+		t.Errorf("Expected identical content to reuse document %s, got %+v, %v", wolves.ID, again, err)
+	}
+
+	reopened, err := openLibrary(dir)
+	if err != nil {
+		t.Fatalf("openLibrary failed: %v", err)
+	}
+	if len(reopened.Documents) != 2 || reopened.Documents[1].Title != "Bears" {
+		t.Fatalf("Expected the catalog to persist, got %+v", reopened.Documents)
+	}
+
+	docs, err := reopened.selection(nil)
+	if err != nil || len(docs) != 2 {
+		t.Fatalf("selection(nil) = %v, %v", docs, err)
+	}
+	cont, err := searchLibrary(reopened, docs, "bears", "bm25", false)
+	if err != nil {
+		t.Fatalf("searchLibrary failed: %v", err)
+	}
+	if !strings.Contains(cont, "Document: Bears ("+bears.ID) || strings.Contains(cont, "Wolves") {
+		t.Errorf("searchLibrary() = %q, want a result attributed to Bears only", cont)
+	}
+
+	docs, _ = reopened.selection([]string{wolves.ID})
+	cont, err = searchLibrary(reopened, docs, "bears", "bm25", false)
+	if err != nil || !strings.Contains(cont, "No matches found in 1 document(s)") {
+		t.Errorf("searchLibrary() on a subset = %q, %v", cont, err)
+	}
+
+	if _, err := reopened.selection([]string{"missing"}); !errors.Is(err, errDocumentNotFound) {
+		t.Errorf("Expected errDocumentNotFound, got %v", err)
+	}
+}
+
+func TestSearchConcurrent(t *testing.T) {
+	var err error
+	library, err = openLibrary(t.TempDir())
+	if err != nil {
+		t.Fatalf("openLibrary failed: %v", err)
+	}
+	defer func() { library = nil }()
+
+	animals := []string{"wolves", "bears", "eagles", "otters", "lynxes", "badgers", "herons", "falcons"}
+	var wg sync.WaitGroup
+	for round := range 3 {
+		for i, animal := range animals {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				var body bytes.Buffer
+				form := multipart.NewWriter(&body)
+				part, _ := form.CreateFormFile("file", animal+".txt")
+				fmt.Fprintf(part, "the %s of document %d roam the valley at dawn", animal, i)
+				form.WriteField("searchText", animal)
+				form.WriteField("mode", "bm25")
+				form.Close()
+
+				req := httptest.NewRequest("POST", "/search", &body)
+				req.Header.Set("Content-Type", form.FormDataContentType())
+				rec := httptest.NewRecorder()
+				search(rec, req)
+
+				var cont string
+				if rec.Code != 200 || json.NewDecoder(rec.Body).Decode(&cont) != nil {
+					t.Errorf("Round %d: search for %s returned %d", round, animal, rec.Code)
+					return
+				}
+				if !strings.Contains(cont, "Document: "+animal+".txt") {
+					t.Errorf("Round %d: search for %s = %q, want a result from its own document", round, animal, cont)
+				}
+
+				rec = httptest.NewRecorder()
+				routes().ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/documents", nil))
+				if rec.Code != 200 {
+					t.Errorf("GET /api/v1/documents returned %d", rec.Code)
+				}
+			}()
+		}
+	}
+	wg.Wait()
+
+	if docs := library.list(); len(docs) != len(animals) {
+		t.Errorf("Expected %d documents after repeated uploads, got %d", len(animals), len(docs))
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"bytes"
+	"sort"
+)
+
+func appendLineOffsets(offsets []int64, data []byte, base int64) []int64 {
+	for i := 0; i < len(data); {
+		next := bytes.IndexByte(data[i:], '\n')
+		if next < 0 {
+			break
+		}
+		i += next + 1
+		offsets = append(offsets, base+int64(i))
+	}
+	return offsets
+}
+
+func lineColumn(index *Index, offset int64) (int, int) {
+	if len(index.lineOffsets) == 0 {
+		return 1, int(offset) + 1
+	}
+	line := sort.Search(len(index.lineOffsets), func(i int) bool {
+		return index.lineOffsets[i] > offset
+	})
+	return line, int(offset-index.lineOffsets[line-1]) + 1
+}
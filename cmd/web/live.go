@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+var errSessionNotFound = errors.New("live search session not found")
+
+type liveEvent struct {
+	name string
+	data any
+}
+
+type LiveResults struct {
+	Seq      int    `json:"seq"`
+	Query    string `json:"query"`
+	Document string `json:"document"`
+	Hits     []Hit  `json:"hits"`
+}
+
+type LiveDone struct {
+	Seq     int    `json:"seq"`
+	Query   string `json:"query"`
+	Total   int    `json:"total"`
+	Error   string `json:"error,omitempty"`
+	Stopped bool   `json:"stopped,omitempty"`
+}
+
+type liveSession struct {
+	events chan liveEvent
+	cancel context.CancelFunc
+	seq    int
+}
+
+type LiveSessions struct {
+	mu       sync.Mutex
+	sessions map[string]*liveSession
+}
+
+var live = &LiveSessions{sessions: make(map[string]*liveSession)}
+
+func (s *LiveSessions) open() (string, *liveSession, error) {
+	id, err := newDocumentID()
+	if err != nil {
+		return "", nil, err
+	}
+	session := &liveSession{events: make(chan liveEvent, 64), cancel: func() {}}
+	s.mu.Lock()
+	s.sessions[id] = session
+	s.mu.Unlock()
+	return id, session, nil
+}
+
+func (s *LiveSessions) close(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if session, ok := s.sessions[id]; ok {
+		session.cancel()
+		delete(s.sessions, id)
+	}
+}
+
+func (s *LiveSessions) query(id string, request SearchRequest) (int, error) {
+	s.mu.Lock()
+	session, ok := s.sessions[id]
+	if !ok {
+		s.mu.Unlock()
+		return 0, fmt.Errorf("%w: %s", errSessionNotFound, id)
+	}
+	session.cancel()
+	ctx, cancel := context.WithCancel(context.Background())
+	session.cancel = cancel
+	session.seq++
+	seq := session.seq
+	s.mu.Unlock()
+
+	go func() {
+		defer cancel()
+		send := func(event liveEvent) bool {
+			select {
+			case session.events <- event:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		if strings.TrimSpace(request.Query) == "" {
+			send(liveEvent{"done", LiveDone{Seq: seq, Query: request.Query}})
+			return
+		}
+		docs, err := library.selection(request.Documents)
+		if err != nil {
+			send(liveEvent{"done", LiveDone{Seq: seq, Query: request.Query, Error: err.Error()}})
+			return
+		}
+
+		total := 0
+		for _, doc := range docs {
+			if ctx.Err() != nil {
+				return
+			}
+			hits, err := libraryHits(library, []Document{doc}, request.Query, request.Mode, request.Diff)
+			if err != nil {
+				send(liveEvent{"done", LiveDone{Seq: seq, Query: request.Query, Error: err.Error()}})
+				return
+			}
+			if len(hits) == 0 {
+				continue
+			}
+			total += len(hits)
+			if !send(liveEvent{"results", LiveResults{Seq: seq, Query: request.Query, Document: doc.ID, Hits: hits}}) {
+				return
+			}
+		}
+		send(liveEvent{"done", LiveDone{Seq: seq, Query: request.Query, Total: total}})
+	}()
+	return seq, nil
+}
+
+func apiLiveEvents(w http.ResponseWriter, r *http.Request) {
+	id, session, err := live.open()
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	defer live.close(id)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	write := func(event liveEvent) {
+		data, _ := json.Marshal(event.data)
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.name, data)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	write(liveEvent{"session", map[string]string{"session": id}})
+	for {
+		select {
+		case event := <-session.events:
+			write(event)
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func apiLiveQuery(w http.ResponseWriter, r *http.Request) {
+	var request SearchRequest
+	if status, err := decodeRequest(w, r, &request); err != nil {
+		writeError(w, status, fmt.Errorf("error decoding search request: %w", err))
+		return
+	}
+	if request.Mode == "" {
+		request.Mode = "simhash"
+	}
+	if !searchModes[request.Mode] {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("unknown search mode %q", request.Mode))
+		return
+	}
+
+	seq, err := live.query(r.PathValue("session"), request)
+	if err != nil {
+		writeError(w, errorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]int{"seq": seq})
+}
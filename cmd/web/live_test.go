@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLiveSearchCancellation(t *testing.T) {
+	var err error
+	library, err = openLibrary(t.TempDir())
+	if err != nil {
+		t.Fatalf("openLibrary failed: %v", err)
+	}
+	defer func() { library = nil }()
+	for i := range 100 {
+		if _, err := library.add("", strings.NewReader(fmt.Sprintf("wolves of pack %d hunt at night", i))); err != nil {
+			t.Fatalf("add failed: %v", err)
+		}
+	}
+
+	id, session, err := live.open()
+	if err != nil {
+		t.Fatalf("open failed: %v", err)
+	}
+	defer live.close(id)
+
+	// Nobody reads the events yet, so the first query stalls once the buffer is full
+	first, err := live.query(id, SearchRequest{Query: "wolves", Mode: "bm25"})
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	second, err := live.query(id, SearchRequest{Query: "pack", Mode: "bm25"})
+	if err != nil || second != first+1 {
+		t.Fatalf("query = %d, %v; want sequence %d", second, err, first+1)
+	}
+
+	timeout := time.After(10 * time.Second)
+	total := 0
+	for {
+		select {
+		case event := <-session.events:
+			switch data := event.data.(type) {
+			case LiveResults:
+				if data.Seq == second {
+					total += len(data.Hits)
+				}
+			case LiveDone:
+				if data.Seq == first {
+					t.Fatalf("The superseded query ran to completion")
+				}
+				if data.Total != 100 || total != 100 || data.Error != "" {
+					t.Errorf("Final query reported %+v after %d streamed hits", data, total)
+				}
+				return
+			}
+		case <-timeout:
+			t.Fatalf("No final event")
+		}
+	}
+}
+
+func TestLiveSearchStream(t *testing.T) {
+	var err error
+	library, err = openLibrary(t.TempDir())
+	if err != nil {
+		t.Fatalf("openLibrary failed: %v", err)
+	}
+	defer func() { library = nil }()
+	wolves, _ := library.add("Wolves", strings.NewReader("wolves hunt in packs"))
+	library.add("Bears", strings.NewReader("bears sleep all winter"))
+	server := httptest.NewServer(routes())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/api/v1/live/events")
+	if err != nil {
+		t.Fatalf("GET events failed: %v", err)
+	}
+	defer resp.Body.Close()
+	events := make(chan [2]string)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		var name string
+		for scanner.Scan() {
+			if event, ok := strings.CutPrefix(scanner.Text(), "event: "); ok {
+				name = event
+			}
+			if data, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+				events <- [2]string{name, data}
+			}
+		}
+		close(events)
+	}()
+
+	event := <-events
+	var opened map[string]string
+	json.Unmarshal([]byte(event[1]), &opened)
+	if event[0] != "session" || opened["session"] == "" {
+		t.Fatalf("First event = %v", event)
+	}
+
+	post := func(body string) int {
+		resp, err := http.Post(server.URL+"/api/v1/live/"+opened["session"], "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("POST failed: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if code := post(`{"query": "wolves", "mode": "bm25"}`); code != http.StatusAccepted {
+		t.Fatalf("POST query = %d", code)
+	}
+
+	var results LiveResults
+	for event := range events {
+		if event[0] == "results" {
+			json.Unmarshal([]byte(event[1]), &results)
+		}
+		if event[0] == "done" {
+			break
+		}
+	}
+	if results.Document != wolves.ID || len(results.Hits) != 1 || results.Hits[0].Title != "Wolves" {
+		t.Errorf("Live results = %+v", results)
+	}
+
+	if code := post(`{"query": "wolves", "mode": "vector"}`); code != http.StatusBadRequest {
+		t.Errorf("Unknown mode = %d", code)
+	}
+	resp, _ = http.Post(server.URL+"/api/v1/live/missing", "application/json", strings.NewReader(`{"query": "x"}`))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Unknown session = %d", resp.StatusCode)
+	}
+}
@@ -1,48 +1,83 @@
 package main
 
 import (
+	"embed"
+	"flag"
 	"html/template"
 	"log"
 	"net/http"
 	"os"
+
+	"trufast/internal/textsearch"
 )
 
 type ChunkInfo struct {
 	Offset int64
 	Size   int
 	Hash   uint64
+	Terms  int
 }
 
+type Posting = textsearch.Posting
+
 type Index struct {
 	FilePath     string
 	ChunkSize    int
 	Chunks       []ChunkInfo
 	HashToChunks map[uint64][]int
+	Postings     map[string][]Posting
+	Vocabulary   []string
+	lineOffsets  []int64
+	terms        *textsearch.BKTree
 }
 
+//go:embed static
+var staticFiles embed.FS
+
+var (
+	homeTemplate   = template.Must(template.ParseFS(staticFiles, "static/index.html"))
+	reportTemplate = template.Must(template.ParseFS(staticFiles, "static/report.html"))
+)
+
 var (
 	loggerErr  = log.New(os.Stdout, "ERROR\t", log.Ltime|log.Llongfile)
 	loggerInfo = log.New(os.Stdout, "INFO\t", log.Ltime)
 )
 
 func main() {
-	fs := http.FileServer(http.Dir("static"))
-	http.Handle("/static/", http.StripPrefix("/static/", fs))
+	flag.Int64Var(&maxUploadSize, "max-upload", maxUploadSize, "maximum size of an uploaded document in bytes")
+	flag.Parse()
 
-	http.HandleFunc("/", home)
-	http.HandleFunc("/search", search)
+	var err error
+	library, err = openLibrary(uploadDir)
+	if err != nil {
+		loggerErr.Fatalln(err)
+	}
+
+	jobs = newJobQueue(2)
 
 	loggerInfo.Println("Server running at http://127.0.0.1:8080/")
-	http.ListenAndServe(":8080", nil)
+	http.ListenAndServe(":8080", routes())
+}
+
+func routes() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle("GET /static/", http.FileServerFS(staticFiles))
+
+	mux.HandleFunc("/", home)
+	mux.HandleFunc("/search", search)
+	mux.HandleFunc("GET /report", reportView)
+	mux.HandleFunc("POST /report", reportUpload)
+	apiRoutes(mux)
+	return mux
 }
 
 func home(w http.ResponseWriter, r *http.Request) {
-	temp, err := template.ParseFiles("./cmd/web/static/index.html")
-	if err != nil {
-		loggerErr.Println(err)
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
 	}
-	err = temp.Execute(w, nil)
-	if err != nil {
+	if err := homeTemplate.Execute(w, library.list()); err != nil {
 		loggerErr.Println(err)
 	}
 }
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	"trufast/internal/textsearch"
+)
+
+const (
+	reportShingleWords = 6
+	maxReportSize      = 8 << 20
+	reportColours      = 8
+)
+
+var (
+	errReportTooLarge = errors.New("error: document is too large for a report")
+	errNoSources      = errors.New("error: select at least one source document other than the suspect")
+)
+
+type Region struct {
+	ID          int    `json:"id"`
+	Source      string `json:"source"`
+	Start       int64  `json:"start"`
+	End         int64  `json:"end"`
+	SourceStart int64  `json:"source_start"`
+	SourceEnd   int64  `json:"source_end"`
+	Words       int    `json:"words"`
+}
+
+type ReportFragment struct {
+	Text    string `json:"text"`
+	Regions []int  `json:"regions,omitempty"`
+	Anchors []int  `json:"anchors,omitempty"`
+}
+
+type SourceReport struct {
+	Document     Document         `json:"document"`
+	MatchedBytes int64            `json:"matched_bytes"`
+	Overlap      float64          `json:"overlap"`
+	Regions      int              `json:"regions"`
+	Colour       int              `json:"colour"`
+	Fragments    []ReportFragment `json:"fragments,omitempty"`
+}
+
+type Report struct {
+	Suspect      Document         `json:"suspect"`
+	MatchedBytes int64            `json:"matched_bytes"`
+	Overlap      float64          `json:"overlap"`
+	Sources      []SourceReport   `json:"sources"`
+	Regions      []Region         `json:"regions"`
+	Fragments    []ReportFragment `json:"fragments"`
+}
+
+type ReportRequest struct {
+	Suspect string   `json:"suspect"`
+	Sources []string `json:"sources"`
+}
+
+type reportPage struct {
+	Documents []Document
+	Request   ReportRequest
+	Report    *Report
+	Error     string
+}
+
+func (r *Report) Colour(region int) int {
+	if region < 1 || region > len(r.Regions) {
+		return 0
+	}
+	for _, source := range r.Sources {
+		if source.Document.ID == r.Regions[region-1].Source {
+			return source.Colour
+		}
+	}
+	return 0
+}
+
+func (f ReportFragment) RegionList() string {
+	ids := make([]string, len(f.Regions))
+	for i, id := range f.Regions {
+		ids[i] = fmt.Sprint(id)
+	}
+	return strings.Join(ids, " ")
+}
+
+func (p reportPage) Selected(id string) bool {
+	for _, source := range p.Request.Sources {
+		if source == id {
+			return true
+		}
+	}
+	return false
+}
+
+func buildReport(library *Library, suspectID string, sourceIDs []string) (*Report, error) {
+	suspect, err := library.document(suspectID)
+	if err != nil {
+		return nil, err
+	}
+	var sources []Document
+	if len(sourceIDs) == 0 {
+		for _, doc := range library.list() {
+			if doc.ID != suspect.ID {
+				sources = append(sources, doc)
+			}
+		}
+	} else if sources, err = library.selection(sourceIDs); err != nil {
+		return nil, err
+	}
+
+	suspectText, err := reportText(library, suspect)
+	if err != nil {
+		return nil, err
+	}
+	suspectWords := textsearch.TokenizeWithOffsets(suspectText)
+
+	report := &Report{Suspect: suspect}
+	sourceTexts := make(map[string]string)
+	for _, source := range sources {
+		if source.ID == suspect.ID {
+			continue
+		}
+		text, err := reportText(library, source)
+		if err != nil {
+			return nil, err
+		}
+		sourceTexts[source.ID] = text
+		report.Regions = append(report.Regions, matchRegions(suspectWords, textsearch.TokenizeWithOffsets(text), source.ID)...)
+		report.Sources = append(report.Sources, SourceReport{Document: source})
+	}
+	if len(report.Sources) == 0 {
+		return nil, errNoSources
+	}
+	sort.SliceStable(report.Regions, func(i, j int) bool { return report.Regions[i].Start < report.Regions[j].Start })
+	for i := range report.Regions {
+		report.Regions[i].ID = i + 1
+	}
+
+	var all []Region
+	for i := range report.Sources {
+		source := &report.Sources[i]
+		var regions []Region
+		for _, region := range report.Regions {
+			if region.Source == source.Document.ID {
+				regions = append(regions, region)
+			}
+		}
+		source.Regions = len(regions)
+		source.MatchedBytes = coveredBytes(regions)
+		source.Overlap = overlapPercent(source.MatchedBytes, suspect.Size)
+		if len(regions) > 0 {
+			source.Fragments = reportFragments(sourceTexts[source.Document.ID], regions, func(r Region) (int64, int64) { return r.SourceStart, r.SourceEnd })
+		}
+		all = append(all, regions...)
+	}
+	sort.SliceStable(report.Sources, func(i, j int) bool { return report.Sources[i].MatchedBytes > report.Sources[j].MatchedBytes })
+	for i := range report.Sources {
+		report.Sources[i].Colour = i % reportColours
+	}
+
+	report.MatchedBytes = coveredBytes(all)
+	report.Overlap = overlapPercent(report.MatchedBytes, suspect.Size)
+	report.Fragments = reportFragments(suspectText, report.Regions, func(r Region) (int64, int64) { return r.Start, r.End })
+	return report, nil
+}
+
+func reportText(library *Library, doc Document) (string, error) {
+	if doc.Size > maxReportSize {
+		return "", fmt.Errorf("%w: %s has %d bytes, the limit is %d", errReportTooLarge, doc.Title, doc.Size, maxReportSize)
+	}
+	data, err := os.ReadFile(library.textPath(doc.ID))
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// Every shared run of words is found by seeding on word shingles and extending along the diagonal
+func matchRegions(suspect, source []textsearch.Token, sourceID string) []Region {
+	shingle := func(words []textsearch.Token) string {
+		terms := make([]string, len(words))
+		for i, word := range words {
+			terms[i] = word.Term
+		}
+		return strings.Join(terms, " ")
+	}
+	seeds := make(map[string][]int)
+	for j := 0; j+reportShingleWords <= len(source); j++ {
+		key := shingle(source[j : j+reportShingleWords])
+		seeds[key] = append(seeds[key], j)
+	}
+
+	var regions []Region
+	reach := make(map[int]int)
+	for i := 0; i+reportShingleWords <= len(suspect); i++ {
+		for _, j := range seeds[shingle(suspect[i:i+reportShingleWords])] {
+			if end, ok := reach[j-i]; ok && i < end {
+				continue
+			}
+			n := reportShingleWords
+			for i+n < len(suspect) && j+n < len(source) && suspect[i+n].Term == source[j+n].Term {
+				n++
+			}
+			reach[j-i] = i + n
+			regions = append(regions, Region{
+				Source:      sourceID,
+				Start:       int64(suspect[i].Start),
+				End:         int64(suspect[i+n-1].End),
+				SourceStart: int64(source[j].Start),
+				SourceEnd:   int64(source[j+n-1].End),
+				Words:       n,
+			})
+		}
+	}
+	return regions
+}
+
+func coveredBytes(regions []Region) int64 {
+	spans := make([]Region, len(regions))
+	copy(spans, regions)
+	sort.Slice(spans, func(i, j int) bool { return spans[i].Start < spans[j].Start })
+	var covered, reached int64
+	for _, span := range spans {
+		start := max(span.Start, reached)
+		if span.End > start {
+			covered += span.End - start
+		}
+		reached = max(reached, span.End)
+	}
+	return covered
+}
+
+func overlapPercent(matched, size int64) float64 {
+	if size == 0 {
+		return 0
+	}
+	return 100 * float64(matched) / float64(size)
+}
+
+func reportFragments(text string, regions []Region, bounds func(Region) (int64, int64)) []ReportFragment {
+	cuts := []int64{0, int64(len(text))}
+	for _, region := range regions {
+		start, end := bounds(region)
+		cuts = append(cuts, start, end)
+	}
+	sort.Slice(cuts, func(i, j int) bool { return cuts[i] < cuts[j] })
+
+	var fragments []ReportFragment
+	anchored := make(map[int]bool)
+	for i := 1; i < len(cuts); i++ {
+		from, to := cuts[i-1], cuts[i]
+		if from == to {
+			continue
+		}
+		fragment := ReportFragment{Text: text[from:to]}
+		for _, region := range regions {
+			if start, end := bounds(region); start <= from && to <= end {
+				fragment.Regions = append(fragment.Regions, region.ID)
+				if !anchored[region.ID] {
+					anchored[region.ID] = true
+					fragment.Anchors = append(fragment.Anchors, region.ID)
+				}
+			}
+		}
+		fragments = append(fragments, fragment)
+	}
+	return fragments
+}
+
+func reportView(w http.ResponseWriter, r *http.Request) {
+	page := reportPage{Documents: library.list()}
+	params := r.URL.Query()
+	page.Request = ReportRequest{Suspect: params.Get("suspect"), Sources: params["source"]}
+	status := http.StatusOK
+	if page.Request.Suspect != "" {
+		report, err := buildReport(library, page.Request.Suspect, page.Request.Sources)
+		if err != nil {
+			status = errorStatus(err)
+			if status == http.StatusInternalServerError {
+				status = http.StatusBadRequest
+			}
+			page.Error = err.Error()
+		}
+		page.Report = report
+	}
+
+	w.WriteHeader(status)
+	if err := reportTemplate.Execute(w, page); err != nil {
+		loggerErr.Println(err)
+	}
+}
+
+func reportUpload(w http.ResponseWriter, r *http.Request) {
+	if err := limitUpload(w, r); err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	form, _, err := streamForm(r)
+	if err != nil {
+		status := errorStatus(err)
+		if status == http.StatusInternalServerError {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	params := url.Values{"suspect": {cmp.Or(form.Get("suspectFile"), form.Get("suspect"))}}
+	for _, id := range append(form["source"], form["sourceFile"]...) {
+		if id != "" {
+			params.Add("source", id)
+		}
+	}
+	http.Redirect(w, r, "/report?"+params.Encode(), http.StatusSeeOther)
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const (
+	reportWolves = "The law of the jungle says the strength of the pack is the wolf."
+	reportBears  = "Bears sleep through the long winter in dens under the snow."
+)
+
+func TestBuildReport(t *testing.T) {
+	library, err := openLibrary(t.TempDir())
+	if err != nil {
+		t.Fatalf("openLibrary failed: %v", err)
+	}
+	wolves, _ := library.add("Wolves", strings.NewReader(reportWolves))
+	bears, _ := library.add("Bears", strings.NewReader(reportBears))
+	unrelated, _ := library.add("Cats", strings.NewReader("Cats nap in the afternoon sun on the porch."))
+	suspect, _ := library.add("Essay", strings.NewReader("Intro. "+reportWolves+" Then "+reportBears+" The end."))
+
+	report, err := buildReport(library, suspect.ID, nil)
+	if err != nil {
+		t.Fatalf("buildReport failed: %v", err)
+	}
+	if len(report.Sources) != 3 || len(report.Regions) != 2 {
+		t.Fatalf("Expected three sources and two regions, got %+v", report)
+	}
+	matched := int64(len(reportWolves) - 1 + len(reportBears) - 1)
+	if report.MatchedBytes != matched || report.Overlap <= 50 || report.Overlap >= 100 {
+		t.Errorf("Overall overlap = %d bytes, %.1f%%, want %d bytes", report.MatchedBytes, report.Overlap, matched)
+	}
+
+	first := report.Regions[0]
+	if first.ID != 1 || first.Source != wolves.ID || first.Start != 7 || first.SourceStart != 0 || first.Words != 14 {
+		t.Errorf("Unexpected first region %+v", first)
+	}
+	if report.Sources[0].Document.ID != wolves.ID || report.Sources[1].Document.ID != bears.ID || report.Sources[2].Document.ID != unrelated.ID {
+		t.Errorf("Sources should be ordered by overlap, got %+v", report.Sources)
+	}
+	if report.Sources[2].Regions != 0 || report.Sources[2].Overlap != 0 || report.Sources[2].Fragments != nil {
+		t.Errorf("Unrelated source = %+v", report.Sources[2])
+	}
+
+	var text strings.Builder
+	for _, fragment := range report.Fragments {
+		text.WriteString(fragment.Text)
+		if len(fragment.Regions) > 0 && report.Colour(fragment.Regions[0]) != report.Sources[fragment.Regions[0]-1].Colour {
+			t.Errorf("Fragment %q has the wrong colour", fragment.Text)
+		}
+	}
+	if text.String() != "Intro. "+reportWolves+" Then "+reportBears+" The end." {
+		t.Errorf("Suspect fragments do not cover the document: %q", text.String())
+	}
+	source := report.Sources[0].Fragments
+	if len(source) != 2 || source[0].Text != reportWolves[:len(reportWolves)-1] || source[0].Anchors[0] != 1 {
+		t.Errorf("Unexpected source fragments %+v", source)
+	}
+
+	if _, err := buildReport(library, suspect.ID, []string{suspect.ID}); !errors.Is(err, errNoSources) {
+		t.Errorf("Expected errNoSources, got %v", err)
+	}
+	if _, err := buildReport(library, "missing", nil); !errors.Is(err, errDocumentNotFound) {
+		t.Errorf("Expected errDocumentNotFound, got %v", err)
+	}
+}
+
+func TestReportPage(t *testing.T) {
+	var err error
+	library, err = openLibrary(t.TempDir())
+	if err != nil {
+		t.Fatalf("openLibrary failed: %v", err)
+	}
+	defer func() { library = nil }()
+	bears, _ := library.add("Bears", strings.NewReader(reportBears))
+	handler := routes()
+
+	// The suspect and one source are uploaded, another source comes from the library
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("suspectFile", "essay.txt")
+	part.Write([]byte(reportWolves + " " + reportBears))
+	form.WriteField("source", bears.ID)
+	part, _ = form.CreateFormFile("sourceFile", "wolves.txt")
+	part.Write([]byte(reportWolves))
+	form.Close()
+	req := httptest.NewRequest("POST", "/report", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	location := rec.Header().Get("Location")
+	if rec.Code != http.StatusSeeOther || !strings.HasPrefix(location, "/report?") || strings.Count(location, "source=") != 2 {
+		t.Fatalf("POST /report = %d %q", rec.Code, location)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", location, nil))
+	page := rec.Body.String()
+	if rec.Code != http.StatusOK || !strings.Contains(page, "essay.txt: 97.6% overlap") || !strings.Contains(page, `href="#source-region-1"`) || !strings.Contains(page, `id="suspect-region-2"`) {
+		t.Errorf("GET %s = %d %s", location, rec.Code, page)
+	}
+
+	var report Report
+	suspect := library.list()[1]
+	request, _ := json.Marshal(ReportRequest{Suspect: suspect.ID, Sources: []string{bears.ID}})
+	if code := apiRequest(t, handler, "POST", "/api/v1/reports", "application/json", request, &report); code != http.StatusOK || len(report.Sources) != 1 || len(report.Regions) != 1 {
+		t.Errorf("POST /api/v1/reports = %d %+v", code, report)
+	}
+
+	var failure map[string]apiError
+	if code := apiRequest(t, handler, "POST", "/api/v1/reports", "application/json", []byte(`{"sources": []}`), &failure); code != http.StatusBadRequest {
+		t.Errorf("Report without a suspect = %d", code)
+	}
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/report?suspect=missing", nil))
+	if rec.Code != http.StatusNotFound || !strings.Contains(rec.Body.String(), "document not found") {
+		t.Errorf("Report of a missing document = %d", rec.Code)
+	}
+}
+
+func TestExportFormats(t *testing.T) {
+	var err error
+	library, err = openLibrary(t.TempDir())
+	if err != nil {
+		t.Fatalf("openLibrary failed: %v", err)
+	}
+	defer func() { library = nil }()
+	source, _ := library.add("Wolves", strings.NewReader(reportWolves))
+	suspect, _ := library.add("Essay", strings.NewReader("Intro. "+reportWolves))
+	handler := routes()
+
+	export := func(path string, body any) *httptest.ResponseRecorder {
+		data, _ := json.Marshal(body)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("POST", path, bytes.NewReader(data)))
+		return rec
+	}
+
+	rec := export("/api/v1/reports?format=csv", ReportRequest{Suspect: suspect.ID})
+	rows, err := csv.NewReader(rec.Body).ReadAll()
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "text/csv; charset=utf-8" || err != nil {
+		t.Fatalf("CSV report = %d %q, %v", rec.Code, rec.Header().Get("Content-Type"), err)
+	}
+	if len(rows) != 2 || rows[1][1] != source.ID || rows[1][6] != reportWolves[:len(reportWolves)-1] {
+		t.Errorf("CSV rows = %q", rows)
+	}
+	if disposition := rec.Header().Get("Content-Disposition"); disposition != `attachment; filename="report-`+suspect.ID+`.csv"` {
+		t.Errorf("Content-Disposition = %q", disposition)
+	}
+
+	rec = export("/api/v1/reports?format=markdown", ReportRequest{Suspect: suspect.ID})
+	if body := rec.Body.String(); !strings.HasPrefix(body, "# Plagiarism report: Essay") || !strings.Contains(body, "| 1 | "+source.ID+" | Wolves |") {
+		t.Errorf("Markdown report:\n%s", body)
+	}
+
+	rec = export("/api/v1/search?format=html", SearchRequest{Query: "<b>law of the jungle</b>", Mode: "bm25"})
+	if body := rec.Body.String(); rec.Code != http.StatusOK || !strings.Contains(body, "<h1>Search report</h1>") || strings.Contains(body, "<b>law") {
+		t.Errorf("HTML search export = %d\n%s", rec.Code, body)
+	}
+
+	if rec = export("/api/v1/search?format=xml", SearchRequest{Query: "law"}); rec.Code != http.StatusBadRequest {
+		t.Errorf("Unknown format = %d", rec.Code)
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"runtime"
+	"sort"
+	"sync"
+
+	"github.com/mfonda/simhash"
+
+	"trufast/internal/textsearch"
+)
+
+var resolutionSizes = []int{64, 256, 1024, 4096}
+
+type MultiIndex struct {
+	FilePath    string
+	Checksum    string
+	Size        int64
+	LineOffsets []int64
+	Resolutions []*Index
+}
+
+type resolutionJob struct {
+	resolution int
+	data       []byte
+	offset     int64
+}
+
+type resolutionResult struct {
+	resolution int
+	chunk      chunkResult
+}
+
+func buildMultiIndex(r io.Reader, filePath string, sizes []int) (*MultiIndex, error) {
+	return buildMultiIndexContext(context.Background(), r, filePath, sizes, nil)
+}
+
+func buildMultiIndexContext(ctx context.Context, r io.Reader, filePath string, sizes []int, progress func(bytes int64, chunks int)) (*MultiIndex, error) {
+	if len(sizes) == 0 {
+		return nil, fmt.Errorf("error: at least one chunk size is required")
+	}
+	blockSize := 0
+	for _, size := range sizes {
+		if size <= 0 {
+			return nil, fmt.Errorf("invalid chunk size: %d", size)
+		}
+		blockSize = max(blockSize, size)
+	}
+
+	multi := &MultiIndex{FilePath: filePath, LineOffsets: []int64{0}}
+	for _, size := range sizes {
+		multi.Resolutions = append(multi.Resolutions, &Index{
+			FilePath:     filePath,
+			ChunkSize:    size,
+			HashToChunks: make(map[uint64][]int),
+			Postings:     make(map[string][]Posting),
+		})
+	}
+
+	numWorkers := runtime.NumCPU()
+	jobs := make(chan resolutionJob, numWorkers*2)
+	results := make(chan resolutionResult, numWorkers*10)
+	var wg sync.WaitGroup
+
+	for range numWorkers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				text := string(job.data)
+				freqs, terms := textsearch.TermFrequencies(text)
+				results <- resolutionResult{
+					resolution: job.resolution,
+					chunk: chunkResult{
+						info: ChunkInfo{
+							Offset: job.offset,
+							Size:   len(job.data),
+							Hash:   simhash.Simhash(simhash.NewWordFeatureSet(job.data)),
+							Terms:  terms,
+						},
+						freqs: freqs,
+					},
+				}
+			}
+		}()
+	}
+
+	var resultWg sync.WaitGroup
+	resultWg.Add(1)
+	go func() {
+		defer resultWg.Done()
+		for result := range results {
+			index := multi.Resolutions[result.resolution]
+			chunkIdx := int(result.chunk.info.Offset / int64(index.ChunkSize))
+			for len(index.Chunks) <= chunkIdx {
+				index.Chunks = append(index.Chunks, ChunkInfo{})
+			}
+			index.Chunks[chunkIdx] = result.chunk.info
+			for term, freq := range result.chunk.freqs {
+				index.Postings[term] = append(index.Postings[term], Posting{Chunk: chunkIdx, Freq: freq})
+			}
+		}
+	}()
+
+	stop := func() {
+		close(jobs)
+		wg.Wait()
+		close(results)
+		resultWg.Wait()
+	}
+
+	hasher := sha256.New()
+	pending := make([][]byte, len(sizes))
+	pendingOffset := make([]int64, len(sizes))
+	emitted := 0
+	emit := func(resolution int, final bool) {
+		size := sizes[resolution]
+		for len(pending[resolution]) >= size || (final && len(pending[resolution]) > 0) {
+			n := min(size, len(pending[resolution]))
+			data := make([]byte, n)
+			copy(data, pending[resolution][:n])
+			jobs <- resolutionJob{resolution: resolution, data: data, offset: pendingOffset[resolution]}
+			pending[resolution] = pending[resolution][n:]
+			pendingOffset[resolution] += int64(n)
+			emitted++
+		}
+	}
+
+	buffer := make([]byte, blockSize)
+	var offset int64
+	for {
+		if err := ctx.Err(); err != nil {
+			stop()
+			return nil, err
+		}
+		n, err := io.ReadFull(r, buffer)
+		if err == io.EOF {
+			break
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			stop()
+			return nil, fmt.Errorf("error reading file: %w", err)
+		}
+
+		block := buffer[:n]
+		hasher.Write(block)
+		multi.LineOffsets = appendLineOffsets(multi.LineOffsets, block, offset)
+		for resolution := range sizes {
+			pending[resolution] = append(pending[resolution], block...)
+			emit(resolution, false)
+		}
+		offset += int64(n)
+		if progress != nil {
+			progress(offset, emitted)
+		}
+	}
+	for resolution := range sizes {
+		emit(resolution, true)
+	}
+	stop()
+	if progress != nil {
+		progress(offset, emitted)
+	}
+
+	for _, index := range multi.Resolutions {
+		for chunkIdx, chunk := range index.Chunks {
+			index.HashToChunks[chunk.Hash] = append(index.HashToChunks[chunk.Hash], chunkIdx)
+		}
+		for _, postings := range index.Postings {
+			sort.Slice(postings, func(i, j int) bool { return postings[i].Chunk < postings[j].Chunk })
+		}
+		index.Vocabulary = textsearch.BuildVocabulary(index.Postings)
+	}
+	multi.prepare()
+	multi.Size = offset
+	multi.Checksum = hex.EncodeToString(hasher.Sum(nil))
+	return multi, nil
+}
+
+func (m *MultiIndex) prepare() {
+	for _, index := range m.Resolutions {
+		index.lineOffsets = m.LineOffsets
+		index.terms = textsearch.NewBKTree(indexVocabulary(index))
+	}
+}
+
+func (m *MultiIndex) resolution(queryLength int) *Index {
+	if len(m.Resolutions) == 0 {
+		return nil
+	}
+	best := m.Resolutions[0]
+	bestRatio := math.Inf(1)
+	for _, index := range m.Resolutions {
+		ratio := math.Abs(math.Log(float64(index.ChunkSize) / float64(max(queryLength, 1))))
+		if ratio < bestRatio {
+			best, bestRatio = index, ratio
+		}
+	}
+	return best
+}
@@ -1,101 +1,127 @@
 package main
 
 import (
-	"bufio"
 	"encoding/gob"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"html"
 	"io"
 	"math/bits"
 	"net/http"
 	"os"
-	"path/filepath"
-	"runtime"
-	"strconv"
 	"strings"
-	"sync"
 
 	"github.com/mfonda/simhash"
+
+	"trufast/internal/textsearch"
 )
 
+const maxHammingDistance = 10
+
+var errSimHashNotFound = errors.New("SimHash not found. Ensure the file was indexed before looking up")
+
 var (
-	userUploadFile    = "./cmd/web/uploads/uploaded_text.txt"
-	userUploadIndexed = "./cmd/web/uploads/uploaded_text.idx"
+	uploadDir = "./cmd/web/uploads"
+	library   *Library
 )
 
 func search(w http.ResponseWriter, r *http.Request) {
-	loggerInfo.Println("Called one")
 	if r.Method != "POST" {
 		loggerErr.Println("NOt allowed")
 		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
 		return
 	}
-	err := r.ParseForm()
-	if err != nil {
+	if err := limitUpload(w, r); err != nil {
 		loggerErr.Println(err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), errorStatus(err))
 		return
 	}
-	file, _, err := r.FormFile("file")
+	form, doc, err := streamForm(r)
 	if err != nil {
 		loggerErr.Println(err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		status := errorStatus(err)
+		if status == http.StatusInternalServerError {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, err.Error(), status)
 		return
 	}
-	err = r.ParseMultipartForm(40 << 57)
-	if err != nil {
-		loggerErr.Println(err)
-		http.Error(w, "Unable to parse form", http.StatusBadRequest)
-		return
+	if doc != nil {
+		loggerInfo.Printf("Indexed %s as document %s", doc.Title, doc.ID)
 	}
-	defer file.Close()
 
-	fileContent, err := io.ReadAll(file)
-	if err != nil {
-		loggerErr.Println(err)
-		http.Error(w, "Unable to read file content", http.StatusInternalServerError)
-		return
-	}
+	userSerach := form.Get("searchText")
+	mode := form.Get("mode")
+	showDiff := form.Get("diff") == "on"
 
-	outFile, err := os.Create(userUploadFile)
+	docs, err := library.selection(form["doc"])
 	if err != nil {
 		loggerErr.Println(err)
-		http.Error(w, "Unable to create file", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusNotFound)
 		return
 	}
-	defer outFile.Close()
 
-	err = os.WriteFile(userUploadFile, fileContent, 0o644)
+	cont, err := searchLibrary(library, docs, userSerach, mode, showDiff)
 	if err != nil {
 		loggerErr.Println(err)
-		http.Error(w, "Failed to write file to disk", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	userSerach := r.FormValue("searchText")
+	w.Header().Set("Content-Type", "application/json")
 
-	userSerachSimHash := simhash.Simhash(simhash.NewWordFeatureSet([]byte(userSerach)))
-	userChunkSize := len(userSerach)
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(cont)
+}
 
-	err = indexCommand(userUploadFile, userChunkSize, userUploadIndexed)
-	if err != nil {
-		loggerErr.Println(err)
-		http.Error(w, "Failed to write file to disk", http.StatusInternalServerError)
-		return
+func searchLibrary(library *Library, docs []Document, query string, mode string, showDiff bool) (string, error) {
+	if len(docs) == 0 {
+		return "", fmt.Errorf("error: the library has no documents, upload a file first")
 	}
 
-	cont, err := lookupCommandWeb(userUploadIndexed, userSerachSimHash)
-	if err != nil {
-		loggerInfo.Println(cont)
-		loggerErr.Println(err)
-		http.Error(w, "Failed to write file to disk", http.StatusInternalServerError)
-		return
+	var content []string
+	for _, doc := range docs {
+		multi, err := library.index(doc.ID)
+		if err != nil {
+			return "", err
+		}
+		cont, err := searchDocument(multi, query, mode, showDiff)
+		if errors.Is(err, errSimHashNotFound) || errors.Is(err, errNoTermMatch) || errors.Is(err, errNoWordMatch) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		header := fmt.Sprintf("Document: %s (%s, uploaded %s)\n", html.EscapeString(doc.Title), doc.ID, doc.Uploaded.Format("2006-01-02 15:04"))
+		content = append(content, header+cont)
 	}
 
-	w.Header().Set("Content-Type", "application/json")
+	if len(content) == 0 {
+		return fmt.Sprintf("No matches found in %d document(s).\n", len(docs)), nil
+	}
+	return strings.Join(content, "\n===\n"), nil
+}
 
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(cont)
+func searchDocument(multi *MultiIndex, query string, mode string, showDiff bool) (string, error) {
+	index := multi.resolution(len(query))
+	if index == nil {
+		return "", fmt.Errorf("error: index has no resolutions")
+	}
+
+	switch mode {
+	case "", "simhash":
+		queryHash := simhash.Simhash(simhash.NewWordFeatureSet([]byte(query)))
+		cont, err := lookupIndexWeb(index, queryHash, query, showDiff)
+		if errors.Is(err, errSimHashNotFound) {
+			return fuzzyIndexWeb(index, query, textsearch.MaxEditDistance)
+		}
+		return cont, err
+	case "fuzzy":
+		return fuzzyIndexWeb(index, query, textsearch.MaxEditDistance)
+	default:
+		return searchIndexWeb(index, query, mode)
+	}
 }
 
 func getChunkContent(filePath string, offset int64, size int) (string, error) {
@@ -120,27 +146,18 @@ func getChunkContent(filePath string, offset int64, size int) (string, error) {
 	return string(data[:n]), nil
 }
 
-func lookupCommandWeb(indexFile string, queryHash uint64) (string, error) {
-	if indexFile == "" {
-		return "", fmt.Errorf("error: index file is required")
-	}
+func lookupIndexWeb(index *Index, queryHash uint64, queryText string, showDiff bool) (string, error) {
 	if queryHash == 0 {
 		return "", fmt.Errorf("error: simhash value is required")
 	}
 
-	index, err := loadIndex(indexFile)
-	if err != nil {
-		return "", err
-	}
-
 	matchingChunks, err := lookupQuery(index, queryHash)
 	if err != nil {
 		return "", err
 	}
 
 	if len(matchingChunks) == 0 {
-		fmt.Println("No matches found for query.")
-		return "", fmt.Errorf("SimHash not found. Ensure the file was indexed before looking up")
+		return "", errSimHashNotFound
 	}
 
 	var content []string
@@ -150,9 +167,35 @@ func lookupCommandWeb(indexFile string, queryHash uint64) (string, error) {
 		if err != nil {
 			return "", err
 		}
+		conten = html.EscapeString(conten)
+
+		span, found, err := locateMatch(index, chunk, queryText)
+		if err != nil {
+			return "", err
+		}
+		if found {
+			conten, err = highlightRegionHTML(index, chunk, span)
+			if err != nil {
+				return "", err
+			}
+			line, column := lineColumn(index, span.Start)
+			conten += fmt.Sprintf("\nBest match at byte offsets: %d-%d (line %d, column %d)", span.Start, span.End, line, column)
+		}
 
-		conten += fmt.Sprintf("Query found in chunk at byte offset: %d\n", chunk.Offset)
-		conten += "Chunk content:"
+		if showDiff {
+			start, end := chunk.Offset, chunk.Offset+int64(chunk.Size)
+			if found {
+				start, end = span.Start, span.End
+			}
+			source, err := getChunkContent(index.FilePath, start, int(end-start))
+			if err != nil {
+				return "", err
+			}
+			conten += "\nWord diff of the query against the match:\n" + textsearch.RenderDiffHTML(textsearch.DiffWords(source, queryText))
+		}
+
+		line, column := lineColumn(index, chunk.Offset)
+		conten += fmt.Sprintf("\nQuery found in chunk at byte offset: %d (line %d, column %d)\n", chunk.Offset, line, column)
 
 		if i < len(matchingChunks)-1 {
 			conten += "\n---\n"
@@ -177,8 +220,6 @@ func lookupQuery(index *Index, queryHash uint64) ([]ChunkInfo, error) {
 	}
 
 	if len(matchingChunks) == 0 {
-		const maxHammingDistance = 10
-
 		for hash, chunkIndices := range index.HashToChunks {
 			distance := HammingDistance(queryHash, hash)
 			if distance <= maxHammingDistance {
@@ -192,29 +233,7 @@ func lookupQuery(index *Index, queryHash uint64) ([]ChunkInfo, error) {
 	return matchingChunks, nil
 }
 
-func indexCommand(inputFile string, chunkSize int, outputFile string) error {
-	if inputFile == "" {
-		return fmt.Errorf("error: input file is required")
-	}
-
-	if outputFile == "" {
-		outputFile = filepath.Base(inputFile) + ".idx"
-	}
-
-	index, err := createIndex(inputFile, chunkSize)
-	if err != nil {
-		return err
-	}
-
-	err = saveIndex(index, outputFile)
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func saveIndex(index *Index, outputPath string) error {
+func saveIndex(multi *MultiIndex, outputPath string) error {
 	file, err := os.Create(outputPath)
 	if err != nil {
 		return fmt.Errorf("error creating index file: %w", err)
@@ -223,157 +242,33 @@ func saveIndex(index *Index, outputPath string) error {
 
 	encoder := gob.NewEncoder(file)
 
-	if err := encoder.Encode(index); err != nil {
+	if err := encoder.Encode(multi); err != nil {
 		return fmt.Errorf("error encoding index: %w", err)
 	}
 
-	generateHashLogs(index)
-
 	return nil
 }
 
-func generateHashLogs(index *Index) {
-	if _, err := os.Stat("hashlogs.txt"); err == nil {
-		os.Remove("hashlogs.txt")
-	}
-
-	file, err := os.OpenFile("hashlogs.txt", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
-	if err != nil {
-		fmt.Println("Error creating file:", err)
-		return
-	}
-	defer file.Close()
-
-	writer := bufio.NewWriter(file)
-
-	var firstTenHashes []ChunkInfo
-	if len(index.Chunks) > 10 {
-		firstTenHashes = index.Chunks[:9]
-	} else if len(index.Chunks) < 10 {
-		firstTenHashes = index.Chunks[:]
-	}
-
-	for _, line := range firstTenHashes {
-		hash := fmt.Sprintf("%x", line.Hash)
-		offset := strconv.Itoa(int(line.Offset))
-		_, err := writer.WriteString(hash + "=>" + offset + "\n")
-		if err != nil {
-			fmt.Println("Error writing to file:", err)
-			return
-		}
-	}
-
-	err = writer.Flush()
-	if err != nil {
-		fmt.Println("Error flushing buffer:", err)
-		return
-	}
-}
-
-func createIndex(filePath string, chunkSize int) (*Index, error) {
-	file, err := os.Open(filePath)
-	if err != nil {
-		return nil, fmt.Errorf("%w. Check the file path and try again", err)
-	}
-	defer file.Close()
-
-	info, err := file.Stat()
-	if err != nil {
-		return nil, fmt.Errorf("error getting file info: %w", err)
-	}
-	fileSize := info.Size()
-	estimatedChunks := int(fileSize / int64(chunkSize))
-	if fileSize%int64(chunkSize) != 0 {
-		estimatedChunks++
-	}
-
-	index := &Index{
-		FilePath:     filePath,
-		ChunkSize:    chunkSize,
-		Chunks:       make([]ChunkInfo, 0, estimatedChunks),
-		HashToChunks: make(map[uint64][]int),
-	}
-
-	numWorkers := runtime.NumCPU()
-	jobs := make(chan struct {
-		data   []byte
-		offset int64
-	}, numWorkers*2)
-	results := make(chan ChunkInfo, numWorkers*10)
-	var wg sync.WaitGroup
-
-	for range numWorkers {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			for job := range jobs {
-				text := string(job.data)
-				hash := simhash.Simhash(simhash.NewWordFeatureSet([]byte(text)))
-				results <- ChunkInfo{
-					Offset: job.offset,
-					Size:   len(job.data),
-					Hash:   hash,
-				}
-			}
-		}()
-	}
-
-	var resultWg sync.WaitGroup
-	resultWg.Add(1)
-	go func() {
-		defer resultWg.Done()
-		for chunkInfo := range results {
-			chunkIdx := len(index.Chunks)
-			index.Chunks = append(index.Chunks, chunkInfo)
-			index.HashToChunks[chunkInfo.Hash] = append(index.HashToChunks[chunkInfo.Hash], chunkIdx)
-		}
-	}()
-
-	buffer := make([]byte, chunkSize)
-	var offset int64 = 0
-	for {
-		n, err := file.Read(buffer)
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			close(jobs)
-			return nil, fmt.Errorf("error reading file: %w", err)
-		}
-
-		data := make([]byte, n)
-		copy(data, buffer[:n])
-
-		jobs <- struct {
-			data   []byte
-			offset int64
-		}{data, offset}
-
-		offset += int64(n)
-	}
-
-	close(jobs)
-	wg.Wait()
-	close(results)
-	resultWg.Wait()
-
-	return index, nil
+type chunkResult struct {
+	info  ChunkInfo
+	freqs map[string]int
 }
 
-func loadIndex(indexPath string) (*Index, error) {
+func loadIndex(indexPath string) (*MultiIndex, error) {
 	file, err := os.Open(indexPath)
 	if err != nil {
 		return nil, fmt.Errorf("error opening index file: %w", err)
 	}
 	defer file.Close()
 
-	var index Index
+	var multi MultiIndex
 
 	decoder := gob.NewDecoder(file)
 
-	if err := decoder.Decode(&index); err != nil {
+	if err := decoder.Decode(&multi); err != nil {
 		return nil, fmt.Errorf("error decoding index: %w", err)
 	}
+	multi.prepare()
 
-	return &index, nil
+	return &multi, nil
 }
@@ -2,20 +2,142 @@ package main
 
 import (
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+
+	"trufast/internal/textsearch"
 )
 
-func TestCreateIndex(t *testing.T) {
-	file := "test.txt"
-	content := "Hello, world! This is a test file."
-	os.WriteFile(file, []byte(content), 0644)
-	defer os.Remove(file)
+// indexTestFile writes content to file and indexes it at the given chunk sizes
+func indexTestFile(t *testing.T, file, content string, sizes ...int) *MultiIndex {
+	t.Helper()
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", file, err)
+	}
+	t.Cleanup(func() { os.Remove(file) })
 
-	index, err := createIndex(file, 10)
+	multi, err := buildMultiIndex(strings.NewReader(content), file, sizes)
 	if err != nil {
-		t.Fatalf("createIndex failed: %v", err)
+		t.Fatalf("buildMultiIndex failed: %v", err)
 	}
+	return multi
+}
+
+func TestBuildMultiIndex(t *testing.T) {
+	index := indexTestFile(t, "test.txt", "Hello, world! This is a test file.", 10).Resolutions[0]
 	if len(index.Chunks) == 0 {
 		t.Fatalf("Expected chunks, got %d", len(index.Chunks))
 	}
 }
+
+func TestSearchIndexWeb(t *testing.T) {
+	index := indexTestFile(t, "test_bm25.txt", "wolves hunt in packs. bears sleep all winter", 22).Resolutions[0]
+
+	for _, mode := range []string{"bm25", "hybrid"} {
+		cont, err := searchIndexWeb(index, "bears", mode)
+		if err != nil {
+			t.Fatalf("searchIndexWeb(%s) failed: %v", mode, err)
+		}
+		if !strings.Contains(cont, "bears sleep") {
+			t.Errorf("searchIndexWeb(%s) = %q, want chunk containing the term", mode, cont)
+		}
+	}
+
+	if _, err := searchIndexWeb(index, "bears", "vector"); err == nil {
+		t.Errorf("Expected error for unknown mode")
+	}
+}
+
+func TestFuzzyIndexWeb(t *testing.T) {
+	multi := indexTestFile(t, "test_fuzzy.txt", "mowgli ran with the wolves", 64)
+	path := filepath.Join(t.TempDir(), "fuzzy.idx")
+	if err := saveIndex(multi, path); err != nil {
+		t.Fatalf("saveIndex failed: %v", err)
+	}
+	loaded, err := loadIndex(path)
+	if err != nil {
+		t.Fatalf("loadIndex failed: %v", err)
+	}
+	if multi.Resolutions[0].terms == nil || loaded.Resolutions[0].terms == nil {
+		t.Fatalf("Expected the vocabulary BK-tree to be built with the index and on load")
+	}
+	index := loaded.Resolutions[0]
+
+	cont, err := fuzzyIndexWeb(index, "mowgly", textsearch.MaxEditDistance)
+	if err != nil {
+		t.Fatalf("fuzzyIndexWeb failed: %v", err)
+	}
+	if !strings.Contains(cont, "mowgly =&gt; mowgli (1)") {
+		t.Errorf("fuzzyIndexWeb() = %q, want resolved term", cont)
+	}
+
+	if _, err := fuzzyIndexWeb(index, "tiger", textsearch.MaxEditDistance); err == nil {
+		t.Errorf("Expected error for unmatched word")
+	}
+}
+
+func TestLookupIndexWebHighlight(t *testing.T) {
+	index := indexTestFile(t, "test_highlight.txt", "the first chunk law of the <jungle> is here", 64).Resolutions[0]
+
+	cont, err := lookupIndexWeb(index, index.Chunks[0].Hash, "law of the jungle", false)
+	if err != nil {
+		t.Fatalf("lookupIndexWeb failed: %v", err)
+	}
+	if !strings.Contains(cont, "<mark>law of the &lt;jungle</mark>&gt;") {
+		t.Errorf("lookupIndexWeb() = %q, want escaped text with marked span", cont)
+	}
+}
+
+func TestLookupIndexWebDiff(t *testing.T) {
+	index := indexTestFile(t, "test_diff.txt", "everyone was expected to arrive on time", 64).Resolutions[0]
+
+	cont, err := lookupIndexWeb(index, index.Chunks[0].Hash, "expected to arrive in time", true)
+	if err != nil {
+		t.Fatalf("lookupIndexWeb failed: %v", err)
+	}
+	if !strings.Contains(cont, "arrive <del>on</del><ins>in</ins> time") {
+		t.Errorf("lookupIndexWeb() = %q, want word diff", cont)
+	}
+}
+
+func TestLineColumn(t *testing.T) {
+	index := indexTestFile(t, "test_lines.txt", "one\ntwo\nthree\n", 5).Resolutions[0]
+	for offset, want := range map[int64][2]int{0: {1, 1}, 5: {2, 2}, 10: {3, 3}} {
+		line, column := lineColumn(index, offset)
+		if line != want[0] || column != want[1] {
+			t.Errorf("lineColumn(%d) = %d:%d; want %d:%d", offset, line, column, want[0], want[1])
+		}
+	}
+}
+
+func TestMultiIndexResolution(t *testing.T) {
+	data, err := os.ReadFile("../../resources/original.txt")
+	if err != nil {
+		t.Fatalf("Failed to read resources/original.txt: %v", err)
+	}
+	multi := indexTestFile(t, "test_resolution.txt", string(data), resolutionSizes...)
+	if len(multi.Resolutions) != len(resolutionSizes) || multi.Checksum == "" {
+		t.Fatalf("Expected %d resolutions and a checksum, got %d %q", len(resolutionSizes), len(multi.Resolutions), multi.Checksum)
+	}
+
+	for _, index := range multi.Resolutions {
+		single := indexTestFile(t, "test_resolution.txt", string(data), index.ChunkSize).Resolutions[0]
+		var covered int64
+		for i, chunk := range index.Chunks {
+			if chunk != single.Chunks[i] {
+				t.Errorf("Resolution %d chunk %d = %+v, want %+v", index.ChunkSize, i, chunk, single.Chunks[i])
+			}
+			covered += int64(chunk.Size)
+		}
+		if covered != multi.Size || len(index.Chunks) != len(single.Chunks) {
+			t.Errorf("Resolution %d covers %d of %d bytes", index.ChunkSize, covered, multi.Size)
+		}
+	}
+
+	for queryLength, want := range map[int]int{0: 64, 17: 64, 100: 64, 300: 256, 2000: 1024, 100000: 4096} {
+		if got := multi.resolution(queryLength).ChunkSize; got != want {
+			t.Errorf("resolution(%d) = %d, want %d", queryLength, got, want)
+		}
+	}
+}
@@ -0,0 +1,375 @@
+function refreshLibrary() {
+    let selected = new Set(Array.from(document.querySelectorAll('#library input:checked')).map(input => input.value));
+    fetch('/api/v1/documents')
+    .then(response => response.json())
+    .then(docs => {
+        let library = document.getElementById('library');
+        library.replaceChildren();
+        (docs || []).forEach(doc => {
+            let label = document.createElement('label');
+            let input = document.createElement('input');
+            input.type = 'checkbox';
+            input.name = 'doc';
+            input.value = doc.id;
+            input.checked = selected.has(doc.id);
+            let uploaded = document.createElement('small');
+            uploaded.textContent = new Date(doc.uploaded).toLocaleString();
+            label.append(input, ' ' + doc.title + ' ', uploaded);
+            library.appendChild(label);
+        });
+    });
+}
+
+let results = {hits: [], active: -1};
+
+function showSummary(text) {
+    document.getElementById('resultsSummary').textContent = text;
+    document.getElementById('results').style.display = 'block';
+}
+
+function clearResults() {
+    results.hits = [];
+    results.active = -1;
+    document.getElementById('searchResults').replaceChildren();
+    document.getElementById('contextPanel').style.display = 'none';
+    showSummary('');
+}
+
+// Fragments come back as plain text, so matches are marked without trusting any markup
+function appendFragments(parent, fragments) {
+    (fragments || []).forEach(fragment => {
+        if (fragment.match) {
+            let mark = document.createElement('mark');
+            mark.textContent = fragment.text;
+            parent.appendChild(mark);
+        } else {
+            parent.appendChild(document.createTextNode(fragment.text));
+        }
+    });
+}
+
+function renderHit(hit, position) {
+    let item = document.createElement('li');
+    item.className = 'hit';
+
+    let meta = document.createElement('div');
+    meta.className = 'hit-meta';
+    let title = document.createElement('strong');
+    title.textContent = hit.title;
+    let where = document.createElement('span');
+    where.textContent = 'line ' + hit.line + ', column ' + hit.column;
+    let score = document.createElement('span');
+    score.textContent = 'score ' + hit.score.toFixed(3) + (hit.distance ? ', distance ' + hit.distance : '');
+    let open = document.createElement('button');
+    open.type = 'button';
+    open.textContent = 'View in context';
+    open.onclick = () => openContext(position);
+    meta.append(title, where, score, open);
+
+    let snippet = document.createElement('div');
+    snippet.className = 'snippet';
+    appendFragments(snippet, hit.fragments);
+    item.append(meta, snippet);
+
+    if (hit.diff) {
+        let diff = document.createElement('div');
+        diff.className = 'diff';
+        hit.diff.forEach(op => {
+            if (op.op === 'equal') {
+                diff.append(op.source, ' ');
+                return;
+            }
+            if (op.source) {
+                let removed = document.createElement('del');
+                removed.textContent = op.source;
+                diff.append(removed, ' ');
+            }
+            if (op.query) {
+                let added = document.createElement('ins');
+                added.textContent = op.query;
+                diff.append(added, ' ');
+            }
+        });
+        item.appendChild(diff);
+    }
+    return item;
+}
+
+function addHits(hits) {
+    let list = document.getElementById('searchResults');
+    (hits || []).forEach(hit => {
+        results.hits.push(hit);
+        list.appendChild(renderHit(hit, results.hits.length - 1));
+    });
+    document.getElementById('results').style.display = 'block';
+}
+
+function openContext(position) {
+    let hit = results.hits[position];
+    if (!hit) {
+        return;
+    }
+    results.active = position;
+    document.querySelectorAll('#searchResults .hit').forEach((item, i) => item.classList.toggle('active', i === position));
+    document.getElementById('prevHit').disabled = position === 0;
+    document.getElementById('nextHit').disabled = position === results.hits.length - 1;
+
+    let params = new URLSearchParams({offset: hit.offset, length: hit.length, lines: 5});
+    fetch('/api/v1/documents/' + encodeURIComponent(hit.document) + '/context?' + params)
+    .then(response => response.json())
+    .then(context => {
+        if (results.active !== position) {
+            return;
+        }
+        let panel = document.getElementById('contextPanel');
+        let text = document.getElementById('contextText');
+        document.getElementById('contextTitle').textContent = hit.title + ' (' + (position + 1) + ' of ' + results.hits.length + ')';
+        text.replaceChildren();
+        panel.style.display = 'block';
+        if (context.error) {
+            text.textContent = context.error.message;
+            return;
+        }
+
+        let line = context.first_line;
+        let number = () => {
+            let span = document.createElement('span');
+            span.className = 'line-number';
+            span.textContent = line++;
+            return span;
+        };
+        text.appendChild(number());
+        context.fragments.forEach(fragment => {
+            let parent = text;
+            if (fragment.match) {
+                parent = document.createElement('mark');
+                text.appendChild(parent);
+            }
+            fragment.text.split('\n').forEach((part, i) => {
+                if (i > 0) {
+                    text.append('\n', number());
+                    if (fragment.match) {
+                        parent = document.createElement('mark');
+                        text.appendChild(parent);
+                    }
+                }
+                parent.append(part);
+            });
+        });
+        let match = text.querySelector('mark');
+        if (match) {
+            match.scrollIntoView({block: 'nearest'});
+        }
+    });
+}
+
+document.getElementById('prevHit').addEventListener('click', () => openContext(results.active - 1));
+document.getElementById('nextHit').addEventListener('click', () => openContext(results.active + 1));
+document.getElementById('closeContext').addEventListener('click', () => {
+    results.active = -1;
+    document.getElementById('contextPanel').style.display = 'none';
+    document.querySelectorAll('#searchResults .hit.active').forEach(item => item.classList.remove('active'));
+});
+document.addEventListener('keydown', event => {
+    if (results.active < 0 || ['INPUT', 'TEXTAREA', 'SELECT'].includes(event.target.tagName)) {
+        return;
+    }
+    if (event.key === 'ArrowDown' || event.key === 'ArrowRight') {
+        event.preventDefault();
+        openContext(Math.min(results.active + 1, results.hits.length - 1));
+    } else if (event.key === 'ArrowUp' || event.key === 'ArrowLeft') {
+        event.preventDefault();
+        openContext(Math.max(results.active - 1, 0));
+    }
+});
+
+function runSearch(uploaded) {
+    let documents = selectedDocuments();
+    if (uploaded && documents.length > 0) {
+        documents.push(uploaded);
+    }
+
+    fetch('/api/v1/search', {
+        method: 'POST',
+        headers: {'Content-Type': 'application/json'},
+        body: JSON.stringify({
+            query: document.getElementById('searchInput').value,
+            mode: document.getElementById('modeInput').value,
+            documents: documents,
+            diff: document.getElementById('diffInput').checked
+        })
+    })
+    .then(response => response.json())
+    .then(data => {
+        clearResults();
+        if (data.error) {
+            showSummary(data.error.message);
+            return;
+        }
+        addHits(data.results);
+        showSummary(results.hits.length + ' result(s) for "' + data.query + '".');
+    })
+    .catch(error => {
+        console.error('Error:', error);
+    });
+}
+
+function formatSeconds(seconds) {
+    return seconds < 60 ? Math.ceil(seconds) + 's' : Math.floor(seconds / 60) + 'm ' + Math.ceil(seconds % 60) + 's';
+}
+
+function watchJob(job, onDone) {
+    let indexing = document.getElementById('indexing');
+    let progress = document.getElementById('indexProgress');
+    let status = document.getElementById('indexStatus');
+    let cancel = document.getElementById('cancelIndex');
+    indexing.style.display = 'block';
+    cancel.onclick = () => fetch('/api/v1/jobs/' + job.id, {method: 'DELETE'});
+
+    let events = new EventSource('/api/v1/jobs/' + job.id + '/events');
+    let show = event => {
+        let job = JSON.parse(event.data);
+        progress.value = job.total > 0 ? job.bytes / job.total : 0;
+        status.textContent = 'Indexing ' + job.title + ': ' + job.bytes + ' of ' + job.total + ' bytes, '
+            + job.chunks + ' chunks' + (job.eta_seconds > 0 ? ', about ' + formatSeconds(job.eta_seconds) + ' left' : '');
+        return job;
+    };
+    events.addEventListener('progress', show);
+    events.addEventListener('done', event => {
+        events.close();
+        let job = show(event);
+        indexing.style.display = 'none';
+        onDone(job.document);
+    });
+    ['failed', 'cancelled'].forEach(name => events.addEventListener(name, event => {
+        events.close();
+        let job = JSON.parse(event.data);
+        status.textContent = 'Indexing ' + job.title + ' ' + job.status + (job.error ? ': ' + job.error : '');
+        cancel.onclick = null;
+    }));
+}
+
+let live = {session: null, seq: 0, events: null, timer: null};
+
+function selectedDocuments() {
+    return Array.from(document.querySelectorAll('#library input:checked')).map(input => input.value);
+}
+
+// Results of superseded queries may still be in flight, so only the newest sequence is shown
+function acceptLive(seq) {
+    if (seq < live.seq) {
+        return false;
+    }
+    if (seq > live.seq) {
+        live.seq = seq;
+        clearResults();
+    }
+    return true;
+}
+
+function startLive() {
+    live.events = new EventSource('/api/v1/live/events');
+    live.events.addEventListener('session', event => {
+        live.session = JSON.parse(event.data).session;
+        scheduleLive();
+    });
+    live.events.addEventListener('results', event => {
+        let data = JSON.parse(event.data);
+        if (acceptLive(data.seq)) {
+            addHits(data.hits);
+        }
+    });
+    live.events.addEventListener('done', event => {
+        let data = JSON.parse(event.data);
+        if (!acceptLive(data.seq)) {
+            return;
+        }
+        showSummary(data.error ? data.error : data.total + ' result(s) for "' + data.query + '".');
+    });
+}
+
+function stopLive() {
+    clearTimeout(live.timer);
+    if (live.events) {
+        live.events.close();
+    }
+    live.session = null;
+    live.events = null;
+}
+
+function scheduleLive() {
+    clearTimeout(live.timer);
+    live.timer = setTimeout(() => {
+        if (!live.session) {
+            return;
+        }
+        fetch('/api/v1/live/' + live.session, {
+            method: 'POST',
+            headers: {'Content-Type': 'application/json'},
+            body: JSON.stringify({
+                query: document.getElementById('searchInput').value,
+                mode: document.getElementById('modeInput').value,
+                documents: selectedDocuments(),
+                diff: document.getElementById('diffInput').checked
+            })
+        })
+        .then(response => response.json())
+        .then(data => {
+            if (data.seq) {
+                acceptLive(data.seq);
+            }
+        });
+    }, 250);
+}
+
+document.getElementById('liveInput').addEventListener('change', event => event.target.checked ? startLive() : stopLive());
+['searchInput', 'modeInput', 'diffInput', 'library'].forEach(id => {
+    let element = document.getElementById(id);
+    element.addEventListener(id === 'searchInput' ? 'input' : 'change', () => {
+        if (live.events) {
+            scheduleLive();
+        }
+    });
+});
+
+document.getElementById('upload-form').addEventListener('submit', function(event) {
+    event.preventDefault();
+
+    let fileInput = document.getElementById('fileInput');
+    let searchInput = document.getElementById('searchInput').value;
+    
+    if (!searchInput) {
+        alert("Please provide a search term.");
+        return;
+    }
+
+    let file = fileInput.files[0];
+    if (!file) {
+        runSearch();
+        return;
+    }
+
+    // Large files are indexed in the background while the progress is streamed back
+    let title = document.getElementById('titleInput').value || file.name;
+    fetch('/api/v1/documents?async=true&title=' + encodeURIComponent(title), {
+        method: 'POST',
+        headers: {'Content-Type': 'application/octet-stream'},
+        body: file
+    })
+    .then(response => response.json())
+    .then(job => {
+        if (job.error) {
+            clearResults();
+            showSummary(job.error.message);
+            return;
+        }
+        fileInput.value = '';
+        watchJob(job, documentID => {
+            refreshLibrary();
+            runSearch(documentID);
+        });
+    })
+    .catch(error => {
+        console.error('Error:', error);
+    });
+});
@@ -0,0 +1,17 @@
+// Passages are linked by region ID, so hovering one lights up its counterparts in both panes
+document.querySelectorAll('#report .match').forEach(match => {
+    let regions = match.dataset.regions.split(' ');
+    let linked = () => Array.from(document.querySelectorAll('#report .match'))
+        .filter(other => other.dataset.regions.split(' ').some(id => regions.includes(id)));
+    match.addEventListener('mouseenter', () => linked().forEach(other => other.classList.add('linked')));
+    match.addEventListener('mouseleave', () => linked().forEach(other => other.classList.remove('linked')));
+});
+
+document.getElementById('report-form').addEventListener('submit', event => {
+    let suspect = document.getElementById('suspectInput').value;
+    let file = document.getElementById('suspectFile').files[0];
+    if (!suspect && !file) {
+        event.preventDefault();
+        alert('Please select or upload a suspect document.');
+    }
+});
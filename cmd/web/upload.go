@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+const maxFieldSize = 1 << 20
+
+var maxUploadSize int64 = 4 << 30
+
+var errMissingFile = errors.New("error: the form has no file field")
+
+func limitUpload(w http.ResponseWriter, r *http.Request) error {
+	if r.ContentLength > maxUploadSize {
+		return &http.MaxBytesError{Limit: maxUploadSize}
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+	return nil
+}
+
+func streamForm(r *http.Request) (url.Values, *Document, error) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	values := make(url.Values)
+	var doc *Document
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			if title := values.Get("title"); doc != nil && title != "" && title != doc.Title {
+				retitled, err := library.retitle(doc.ID, title)
+				if err != nil {
+					return nil, nil, err
+				}
+				doc = &retitled
+			}
+			return values, doc, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if part.FileName() != "" {
+			added, err := library.add(part.FileName(), part)
+			if err != nil {
+				return nil, nil, err
+			}
+			values.Add(part.FormName(), added.ID)
+			if part.FormName() == "file" {
+				doc = &added
+			}
+			continue
+		}
+
+		value, err := io.ReadAll(io.LimitReader(part, maxFieldSize+1))
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(value) > maxFieldSize {
+			return nil, nil, fmt.Errorf("error: form field %q is longer than %d bytes", part.FormName(), maxFieldSize)
+		}
+		values.Add(part.FormName(), string(value))
+	}
+}
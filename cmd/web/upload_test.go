@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestStreamingUpload(t *testing.T) {
+	var err error
+	library, err = openLibrary(t.TempDir())
+	if err != nil {
+		t.Fatalf("openLibrary failed: %v", err)
+	}
+	defer func() { library = nil }()
+	server := httptest.NewServer(routes())
+	defer server.Close()
+
+	// Chunked transfer: the body has no length and arrives in pieces
+	reader, writer := io.Pipe()
+	go func() {
+		for i := range 2000 {
+			fmt.Fprintf(writer, "line %d of a streamed document about wolves\n", i)
+		}
+		writer.Close()
+	}()
+	req, _ := http.NewRequest("PUT", server.URL+"/api/v1/documents/streamed?title=Streamed", reader)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("PUT failed: %v", err)
+	}
+	var doc Document
+	json.NewDecoder(resp.Body).Decode(&doc)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated || doc.ID != "streamed" || doc.Title != "Streamed" || doc.Size < 80000 {
+		t.Fatalf("PUT = %d %+v", resp.StatusCode, doc)
+	}
+	content, _ := os.ReadFile(library.textPath("streamed"))
+	if int64(len(content)) != doc.Size || !strings.HasPrefix(string(content), "line 0 of") {
+		t.Errorf("Stored %d bytes, want %d", len(content), doc.Size)
+	}
+	multi, err := library.index("streamed")
+	if err != nil || multi.Checksum != doc.Checksum || len(multi.LineOffsets) != 2001 {
+		t.Errorf("Unexpected index for the streamed document: %v", err)
+	}
+
+	// Replacing the content keeps the title
+	req, _ = http.NewRequest("PUT", server.URL+"/api/v1/documents/streamed", strings.NewReader("bears sleep all winter"))
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("PUT failed: %v", err)
+	}
+	json.NewDecoder(resp.Body).Decode(&doc)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || doc.Title != "Streamed" || doc.Size != 22 {
+		t.Errorf("Replacing PUT = %d %+v", resp.StatusCode, doc)
+	}
+	if docs := library.list(); len(docs) != 1 {
+		t.Errorf("Expected one document, got %+v", docs)
+	}
+
+	req, _ = http.NewRequest("PUT", server.URL+"/api/v1/documents/bad.id", strings.NewReader("x"))
+	if resp, err = http.DefaultClient.Do(req); err != nil || resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("PUT with an invalid ID = %v, %v", resp.StatusCode, err)
+	}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	form.WriteField("title", "No file")
+	form.Close()
+	resp, err = http.Post(server.URL+"/api/v1/documents", form.FormDataContentType(), &body)
+	if err != nil || resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Multipart POST without a file = %v, %v", resp.StatusCode, err)
+	}
+
+	// The title applies whether it is sent before or after the file
+	for i, titleFirst := range []bool{true, false} {
+		body.Reset()
+		form = multipart.NewWriter(&body)
+		if titleFirst {
+			form.WriteField("title", "Titled")
+		}
+		part, _ := form.CreateFormFile("file", "pack.txt")
+		fmt.Fprintf(part, "wolves of pack %d hunt at night", i)
+		if !titleFirst {
+			form.WriteField("title", "Titled")
+		}
+		form.Close()
+		resp, err = http.Post(server.URL+"/api/v1/documents", form.FormDataContentType(), &body)
+		if err != nil {
+			t.Fatalf("Multipart POST failed: %v", err)
+		}
+		json.NewDecoder(resp.Body).Decode(&doc)
+		resp.Body.Close()
+		if stored, _ := library.document(doc.ID); resp.StatusCode != http.StatusCreated || doc.Title != "Titled" || stored.Title != "Titled" {
+			t.Errorf("Multipart POST with the title first = %v: %d %+v, stored %+v", titleFirst, resp.StatusCode, doc, stored)
+		}
+	}
+}
+
+func TestUploadLimit(t *testing.T) {
+	var err error
+	library, err = openLibrary(t.TempDir())
+	if err != nil {
+		t.Fatalf("openLibrary failed: %v", err)
+	}
+	defer func() { library = nil }()
+	defer func(size int64) { maxUploadSize = size }(maxUploadSize)
+	maxUploadSize = 1024
+	server := httptest.NewServer(routes())
+	defer server.Close()
+
+	large := strings.Repeat("wolves hunt in packs\n", 100)
+	var failure map[string]apiError
+
+	// Declared length over the limit
+	resp, err := http.Post(server.URL+"/api/v1/documents", "text/plain", strings.NewReader(large))
+	if err != nil {
+		t.Fatalf("POST failed: %v", err)
+	}
+	json.NewDecoder(resp.Body).Decode(&failure)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestEntityTooLarge || failure["error"].Status != http.StatusRequestEntityTooLarge {
+		t.Errorf("POST over the limit = %d %+v", resp.StatusCode, failure)
+	}
+
+	// Chunked body that only turns out too large while streaming
+	req, _ := http.NewRequest("PUT", server.URL+"/api/v1/documents/large", io.MultiReader(strings.NewReader(large)))
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("PUT failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("Chunked PUT over the limit = %d", resp.StatusCode)
+	}
+
+	// Multipart upload through the search form
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	form.WriteField("searchText", "wolves")
+	part, _ := form.CreateFormFile("file", "large.txt")
+	part.Write([]byte(large))
+	form.Close()
+	resp, err = http.Post(server.URL+"/search", form.FormDataContentType(), io.MultiReader(&body))
+	if err != nil {
+		t.Fatalf("POST /search failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("Search upload over the limit = %d", resp.StatusCode)
+	}
+
+	entries, _ := os.ReadDir(library.Dir)
+	if len(library.list()) != 0 || len(entries) != 0 {
+		t.Errorf("Rejected uploads left %d documents and %d files behind", len(library.list()), len(entries))
+	}
+}
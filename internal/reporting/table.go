@@ -0,0 +1,122 @@
+// Package reporting renders match reports as standalone HTML, Markdown or CSV
+// Both the command-line tool and the web server describe their results as a
+// Table, so the two produce the same documents for the same matches
+package reporting
+
+import (
+	"encoding/csv"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"time"
+)
+
+// snippetLength is the number of characters of a cell shown in Markdown tables
+const snippetLength = 80
+
+// Table is a report: headline figures followed by one row per match
+type Table struct {
+	Title string
+	// Title is the heading of the report
+
+	Generated time.Time
+	// Generated is the time the report was created
+
+	Summary [][2]string
+	// Summary holds name and value pairs of headline figures, in display order
+
+	Columns []string
+	// Columns names the cells of each row; the last column holds the matched text
+
+	Rows [][]string
+	// Rows holds one row of cells per match
+}
+
+// Write renders a table in the requested format
+// Parameters:
+//
+//	w: Destination of the rendered report
+//	format: "html", "markdown" or "csv"
+//	table: The report to render
+//
+// Returns:
+//
+//	error: nil on success, error if the format is unknown or writing fails
+func Write(w io.Writer, format string, table Table) error {
+	switch format {
+	case "html":
+		return htmlTemplate.Execute(w, table)
+	case "markdown":
+		_, err := io.WriteString(w, Markdown(table))
+		return err
+	case "csv":
+		writer := csv.NewWriter(w)
+		writer.Write(table.Columns)
+		writer.WriteAll(table.Rows)
+		return writer.Error()
+	}
+	return fmt.Errorf("unknown report format %q (expected html, markdown or csv)", format)
+}
+
+// htmlTemplate renders a self-contained HTML report; the styles are inlined
+// and every cell is escaped by html/template
+var htmlTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="UTF-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: Arial, sans-serif; margin: 2em; color: #333; }
+table { border-collapse: collapse; margin-bottom: 1.5em; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; vertical-align: top; }
+th { background: #f0f0f0; }
+td:last-child { white-space: pre-wrap; word-wrap: break-word; max-width: 60em; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>Generated {{.Generated.Format "2006-01-02 15:04:05 MST"}}</p>
+<table>
+{{range .Summary}}<tr><th>{{index . 0}}</th><td>{{index . 1}}</td></tr>
+{{end}}</table>
+{{if .Rows}}<table>
+<tr>{{range .Columns}}<th>{{.}}</th>{{end}}</tr>
+{{range .Rows}}<tr>{{range .}}<td>{{.}}</td>{{end}}</tr>
+{{end}}</table>
+{{else}}<p>No matches.</p>
+{{end}}</body>
+</html>
+`))
+
+// Markdown renders a table as a Markdown summary
+// Cells are collapsed onto a single line and shortened to snippetLength characters
+func Markdown(table Table) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# %s\n\nGenerated %s.\n\n", table.Title, table.Generated.Format("2006-01-02 15:04:05 MST"))
+	for _, stat := range table.Summary {
+		fmt.Fprintf(&sb, "- **%s:** %s\n", stat[0], cell(stat[1]))
+	}
+	if len(table.Rows) == 0 {
+		sb.WriteString("\nNo matches.\n")
+		return sb.String()
+	}
+	sb.WriteString("\n| " + strings.Join(table.Columns, " | ") + " |\n|" + strings.Repeat("---|", len(table.Columns)) + "\n")
+	for _, row := range table.Rows {
+		cells := make([]string, len(row))
+		for i, value := range row {
+			cells[i] = cell(value)
+		}
+		sb.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+	}
+	return sb.String()
+}
+
+// cell prepares text for a Markdown table cell
+func cell(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if runes := []rune(text); len(runes) > snippetLength {
+		text = string(runes[:snippetLength]) + "…"
+	}
+	return strings.ReplaceAll(text, "|", `\|`)
+}
@@ -0,0 +1,48 @@
+package reporting
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	table := Table{
+		Title:   "Search report",
+		Summary: [][2]string{{"Query", "law | jungle"}},
+		Columns: []string{"#", "Text"},
+		Rows:    [][]string{{"1", "<script>alert(1)</script>\n" + strings.Repeat("x", 100)}},
+	}
+	render := func(format string) string {
+		t.Helper()
+		var buf bytes.Buffer
+		if err := Write(&buf, format, table); err != nil {
+			t.Fatalf("Write(%s) failed: %v", format, err)
+		}
+		return buf.String()
+	}
+
+	markdown := render("markdown")
+	if !strings.Contains(markdown, `- **Query:** law \| jungle`) || !strings.Contains(markdown, "| # | Text |\n|---|---|\n") || !strings.Contains(markdown, strings.Repeat("x", 54)+"…") {
+		t.Errorf("Markdown report:\n%s", markdown)
+	}
+
+	// The HTML report stands alone and escapes cell text
+	page := render("html")
+	if !strings.Contains(page, "<style>") || strings.Contains(page, "<script>") || !strings.Contains(page, "&lt;script&gt;") {
+		t.Errorf("HTML report:\n%s", page)
+	}
+
+	rows, err := csv.NewReader(strings.NewReader(render("csv"))).ReadAll()
+	if err != nil || len(rows) != 2 || rows[0][1] != "Text" || rows[1][1] != table.Rows[0][1] {
+		t.Errorf("CSV report = %q, %v", rows, err)
+	}
+
+	if err := Write(&bytes.Buffer{}, "xml", table); err == nil {
+		t.Errorf("Expected error for unknown format")
+	}
+	if empty := Markdown(Table{Title: "Empty"}); !strings.Contains(empty, "No matches.") {
+		t.Errorf("Empty Markdown report = %q", empty)
+	}
+}
@@ -0,0 +1,72 @@
+package textsearch
+
+const (
+	alignMatch    = 2
+	alignMismatch = -1
+	alignGap      = -1
+	// Word-level scores used by Align (local alignment)
+)
+
+// Align performs a word-level Smith-Waterman local alignment of query against text
+// Parameters:
+//
+//	query: The query text
+//	text: The text to search in
+//
+// Returns:
+//
+//	int: Byte offset in text where the aligned region starts
+//	int: Byte offset in text where the aligned region ends
+//	int: Alignment score, 0 when nothing aligns
+func Align(query string, text string) (int, int, int) {
+	queryWords := TokenizeWithOffsets(query)
+	textWords := TokenizeWithOffsets(text)
+	if len(queryWords) == 0 || len(textWords) == 0 {
+		return 0, 0, 0
+	}
+
+	// Two rows of the scoring matrix, plus the text word where each path began
+	previous := make([]int, len(textWords)+1)
+	current := make([]int, len(textWords)+1)
+	previousStart := make([]int, len(textWords)+1)
+	currentStart := make([]int, len(textWords)+1)
+
+	bestScore, bestStart, bestEnd := 0, 0, 0
+	for i := 1; i <= len(queryWords); i++ {
+		current[0] = 0
+		for j := 1; j <= len(textWords); j++ {
+			substitution := alignMismatch
+			if queryWords[i-1].Term == textWords[j-1].Term {
+				substitution = alignMatch
+			}
+
+			// Pick the best of starting afresh, diagonal, skipping a query word or skipping a text word
+			score, start := 0, j-1
+			if diagonal := previous[j-1] + substitution; diagonal > score {
+				score, start = diagonal, previousStart[j-1]
+				if previous[j-1] == 0 {
+					start = j - 1
+				}
+			}
+			if up := previous[j] + alignGap; up > score {
+				score, start = up, previousStart[j]
+			}
+			if left := current[j-1] + alignGap; left > score {
+				score, start = left, currentStart[j-1]
+			}
+			current[j], currentStart[j] = score, start
+
+			// Only regions ending on a matching word are worth reporting
+			if score > bestScore && substitution == alignMatch {
+				bestScore, bestStart, bestEnd = score, start, j-1
+			}
+		}
+		previous, current = current, previous
+		previousStart, currentStart = currentStart, previousStart
+	}
+
+	if bestScore == 0 {
+		return 0, 0, 0
+	}
+	return textWords[bestStart].Start, textWords[bestEnd].End, bestScore
+}
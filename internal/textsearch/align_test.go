@@ -0,0 +1,31 @@
+package textsearch
+
+import "testing"
+
+func TestAlign(t *testing.T) {
+	text := "Mowgli was a man-cub. He ran with the wolves of the Seeonee pack."
+	tests := []struct {
+		name      string
+		query     string
+		wantSpan  string
+		wantScore bool
+	}{
+		{"exact phrase", "ran with the wolves", "ran with the wolves", true},
+		{"case insensitive", "THE SEEONEE PACK", "the Seeonee pack", true},
+		{"one word changed", "ran beside the wolves", "ran with the wolves", true},
+		{"no shared words", "tiger tiger", "", false},
+		{"empty query", "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, score := Align(tt.query, text)
+			if (score > 0) != tt.wantScore {
+				t.Fatalf("Align() score = %d, want positive %v", score, tt.wantScore)
+			}
+			if got := text[start:end]; got != tt.wantSpan {
+				t.Errorf("Align() span = %q, want %q", got, tt.wantSpan)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package textsearch
+
+import (
+	"math"
+	"sort"
+)
+
+const (
+	bm25K1 = 1.2
+	// bm25K1 controls term frequency saturation in BM25 scoring
+
+	bm25B = 0.75
+	// bm25B controls how strongly chunk length normalises the score
+)
+
+// Posting records how often a term occurs in a single chunk
+// It is one entry of an inverted index posting list
+type Posting struct {
+	Chunk int
+	// Chunk is the index of the chunk in the index's Chunks slice
+
+	Freq int
+	// Freq is the number of times the term occurs in the chunk
+}
+
+// BuildVocabulary collects the terms of an inverted index in sorted order
+func BuildVocabulary(postings map[string][]Posting) []string {
+	vocabulary := make([]string, 0, len(postings))
+	for term := range postings {
+		vocabulary = append(vocabulary, term)
+	}
+	sort.Strings(vocabulary)
+	return vocabulary
+}
+
+// BM25Scores computes the Okapi BM25 score of every chunk containing a query term
+// Parameters:
+//
+//	postings: Inverted index from terms to the chunks containing them
+//	chunks: Number of chunks in the index
+//	length: Returns the number of terms in a chunk
+//	query: Free-text query
+//	skip: Reports chunks to leave out of the results; nil keeps every chunk
+//
+// Returns:
+//
+//	map[int]float64: BM25 score keyed by chunk index; chunks without query terms are absent
+func BM25Scores(postings map[string][]Posting, chunks int, length func(chunk int) int, query string, skip func(chunk int) bool) map[int]float64 {
+	scores := make(map[int]float64)
+	total := chunks
+	if total == 0 {
+		return scores
+	}
+
+	// Average chunk length in terms, used for length normalisation
+	var termSum int
+	for chunk := range chunks {
+		termSum += length(chunk)
+	}
+	avgTerms := float64(termSum) / float64(total)
+	if avgTerms == 0 {
+		return scores
+	}
+
+	// Score each distinct query term once
+	for _, term := range UniqueTerms(query) {
+		termPostings := postings[term]
+		if len(termPostings) == 0 {
+			continue
+		}
+		df := float64(len(termPostings))
+		idf := math.Log(1 + (float64(total)-df+0.5)/(df+0.5))
+
+		for _, posting := range termPostings {
+			if skip != nil && skip(posting.Chunk) {
+				continue
+			}
+			tf := float64(posting.Freq)
+			terms := float64(length(posting.Chunk))
+			norm := tf + bm25K1*(1-bm25B+bm25B*terms/avgTerms)
+			scores[posting.Chunk] += idf * tf * (bm25K1 + 1) / norm
+		}
+	}
+	return scores
+}
@@ -0,0 +1,181 @@
+package textsearch
+
+import (
+	"html"
+	"strings"
+)
+
+// DiffOp is one step of a word-level diff between a source passage and a query
+type DiffOp struct {
+	Op string `json:"op"`
+	// Op is one of "equal", "delete", "insert" or "replace"
+	// "delete" words only appear in the source, "insert" words only in the query,
+	// and "replace" is a deletion immediately followed by an insertion
+
+	Source string `json:"source,omitempty"`
+	// Source is the text of the source passage covered by this step
+
+	Query string `json:"query,omitempty"`
+	// Query is the text of the query covered by this step
+}
+
+// DiffWords computes a word-level diff of query against source
+// Words are compared case-insensitively using the same word boundaries as the index;
+// the returned steps carry the original text, including punctuation between words
+// Parameters:
+//
+//	source: The matched source passage
+//	query: The query or suspect text
+//
+// Returns:
+//
+//	[]DiffOp: Steps in order, with adjacent steps of the same kind merged
+func DiffWords(source string, query string) []DiffOp {
+	sourceWords := TokenizeWithOffsets(source)
+	queryWords := TokenizeWithOffsets(query)
+	edits := myersDiff(sourceWords, queryWords)
+
+	// Group consecutive edits of the same kind into runs of words
+	var ops []DiffOp
+	for i := 0; i < len(edits); {
+		j := i
+		for j < len(edits) && edits[j].kind == edits[i].kind {
+			j++
+		}
+		run := edits[i:j]
+		op := DiffOp{Op: run[0].kind}
+		switch op.Op {
+		case "equal":
+			op.Source = source[sourceWords[run[0].a].Start:sourceWords[run[len(run)-1].a].End]
+			op.Query = query[queryWords[run[0].b].Start:queryWords[run[len(run)-1].b].End]
+		case "delete":
+			op.Source = source[sourceWords[run[0].a].Start:sourceWords[run[len(run)-1].a].End]
+		case "insert":
+			op.Query = query[queryWords[run[0].b].Start:queryWords[run[len(run)-1].b].End]
+		}
+
+		// A deletion followed by an insertion is a substitution
+		if n := len(ops); n > 0 && ops[n-1].Op == "delete" && op.Op == "insert" {
+			ops[n-1].Op = "replace"
+			ops[n-1].Query = op.Query
+		} else {
+			ops = append(ops, op)
+		}
+		i = j
+	}
+	return ops
+}
+
+// wordEdit is a single step of a Myers edit script
+// a and b index the source and query words involved (-1 when not applicable)
+type wordEdit struct {
+	kind string
+	a, b int
+}
+
+// myersDiff computes the shortest edit script between two word sequences
+// using Myers' O(ND) algorithm
+// Deletions are emitted before insertions so that substitutions can be detected
+func myersDiff(a, b []Token) []wordEdit {
+	n, m := len(a), len(b)
+	limit := n + m
+	offset := limit + 1
+	v := make([]int, 2*limit+3)
+	var trace [][]int
+
+	// Forward pass: record the furthest reaching path for each diagonal k
+	for d := 0; d <= limit; d++ {
+		snapshot := make([]int, len(v))
+		copy(snapshot, v)
+		trace = append(trace, snapshot)
+
+		done := false
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1] // Move down: insertion
+			} else {
+				x = v[offset+k-1] + 1 // Move right: deletion
+			}
+			y := x - k
+			for x < n && y < m && a[x].Term == b[y].Term {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				done = true
+				break
+			}
+		}
+		if done {
+			break
+		}
+	}
+
+	// Backward pass: walk the recorded paths from the end to the start
+	var edits []wordEdit
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0 && (x > 0 || y > 0); d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			edits = append(edits, wordEdit{kind: "equal", a: x, b: y})
+		}
+		if d > 0 {
+			if x == prevX {
+				y--
+				edits = append(edits, wordEdit{kind: "insert", a: -1, b: y})
+			} else {
+				x--
+				edits = append(edits, wordEdit{kind: "delete", a: x, b: -1})
+			}
+		}
+	}
+
+	// Reverse into forward order, then move deletions ahead of adjacent insertions
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	for i := 1; i < len(edits); i++ {
+		for j := i; j > 0 && edits[j].kind == "delete" && edits[j-1].kind == "insert"; j-- {
+			edits[j], edits[j-1] = edits[j-1], edits[j]
+		}
+	}
+	return edits
+}
+
+// RenderDiffHTML renders diff steps as an HTML fragment using <del> and <ins>
+// All text is escaped
+func RenderDiffHTML(ops []DiffOp) string {
+	var sb strings.Builder
+	sb.WriteString(`<div class="diff">`)
+	for i, op := range ops {
+		if i > 0 {
+			sb.WriteString(" ")
+		}
+		switch op.Op {
+		case "equal":
+			sb.WriteString(html.EscapeString(op.Query))
+		case "delete":
+			sb.WriteString("<del>" + html.EscapeString(op.Source) + "</del>")
+		case "insert":
+			sb.WriteString("<ins>" + html.EscapeString(op.Query) + "</ins>")
+		case "replace":
+			sb.WriteString("<del>" + html.EscapeString(op.Source) + "</del><ins>" + html.EscapeString(op.Query) + "</ins>")
+		}
+	}
+	sb.WriteString("</div>")
+	return sb.String()
+}
@@ -0,0 +1,66 @@
+package textsearch
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffWords(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		query  string
+		want   []DiffOp
+	}{
+		{
+			name:   "identical",
+			source: "The meeting was scheduled",
+			query:  "the meeting was scheduled",
+			want:   []DiffOp{{Op: "equal", Source: "The meeting was scheduled", Query: "the meeting was scheduled"}},
+		},
+		{
+			name:   "substitutions",
+			source: "The meeting was scheduled for noon, and everyone was expected to arrive on time.",
+			query:  "The meeting is scheduled for noon, and everyone was expected to arrive in time.",
+			want: []DiffOp{
+				{Op: "equal", Source: "The meeting", Query: "The meeting"},
+				{Op: "replace", Source: "was", Query: "is"},
+				{Op: "equal", Source: "scheduled for noon, and everyone was expected to arrive", Query: "scheduled for noon, and everyone was expected to arrive"},
+				{Op: "replace", Source: "on", Query: "in"},
+				{Op: "equal", Source: "time", Query: "time"},
+			},
+		},
+		{
+			name:   "insertion and deletion",
+			source: "birds chirped in the distance",
+			query:  "birds chirped loudly in the",
+			want: []DiffOp{
+				{Op: "equal", Source: "birds chirped", Query: "birds chirped"},
+				{Op: "insert", Query: "loudly"},
+				{Op: "equal", Source: "in the", Query: "in the"},
+				{Op: "delete", Source: "distance"},
+			},
+		},
+		{
+			name:   "empty query",
+			source: "calm atmosphere",
+			query:  "",
+			want:   []DiffOp{{Op: "delete", Source: "calm atmosphere"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DiffWords(tt.source, tt.query); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DiffWords() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderDiffHTML(t *testing.T) {
+	ops := DiffWords("arrive on <time>", "arrive in <time>")
+	if got, want := RenderDiffHTML(ops), `<div class="diff">arrive <del>on</del><ins>in</ins> time</div>`; got != want {
+		t.Errorf("RenderDiffHTML() = %q, want %q", got, want)
+	}
+}
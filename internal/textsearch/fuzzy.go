@@ -0,0 +1,189 @@
+package textsearch
+
+import (
+	"sort"
+)
+
+// MaxEditDistance is the largest edit distance accepted for fuzzy term queries
+// Larger distances match too many unrelated words to be useful
+const MaxEditDistance = 2
+
+// TermMatch is a vocabulary term that matched a query term approximately
+type TermMatch struct {
+	Term string
+	// Term is the matched vocabulary term
+
+	Distance int
+	// Distance is the Levenshtein distance between the query term and Term
+}
+
+// FuzzyMatches is the outcome of a fuzzy query against an inverted index
+type FuzzyMatches struct {
+	Scores map[int]float64
+	// Scores rewards chunks matching more query terms with smaller edit distances
+
+	Terms map[int][]string
+	// Terms lists the vocabulary terms found in each scored chunk, sorted alphabetically
+
+	Matches map[string][]TermMatch
+	// Matches holds the vocabulary matches of each distinct query word
+}
+
+// BKTree is a Burkhard-Keller tree over vocabulary terms
+// Each child edge is labelled with the edit distance between parent and child,
+// which lets a search skip whole subtrees using the triangle inequality
+// Building a tree costs more than one linear scan of the vocabulary, so a tree
+// should be built once per vocabulary and reused across queries
+type BKTree struct {
+	root *bkNode
+}
+
+// bkNode is a single term in a BKTree
+type bkNode struct {
+	term     string
+	children map[int]*bkNode
+}
+
+// NewBKTree builds a BK-tree containing the given terms
+func NewBKTree(terms []string) *BKTree {
+	tree := &BKTree{}
+	for _, term := range terms {
+		tree.add(term)
+	}
+	return tree
+}
+
+// add inserts a term into the tree, ignoring duplicates
+func (t *BKTree) add(term string) {
+	if t.root == nil {
+		t.root = &bkNode{term: term, children: make(map[int]*bkNode)}
+		return
+	}
+	node := t.root
+	for {
+		distance := Levenshtein(term, node.term)
+		if distance == 0 {
+			return
+		}
+		child, ok := node.children[distance]
+		if !ok {
+			node.children[distance] = &bkNode{term: term, children: make(map[int]*bkNode)}
+			return
+		}
+		node = child
+	}
+}
+
+// Search returns all terms within maxDistance edits of term
+// Results are ordered by distance, then alphabetically
+func (t *BKTree) Search(term string, maxDistance int) []TermMatch {
+	var matches []TermMatch
+	if t.root == nil {
+		return matches
+	}
+
+	stack := []*bkNode{t.root}
+	for len(stack) > 0 {
+		node := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		distance := Levenshtein(term, node.term)
+		if distance <= maxDistance {
+			matches = append(matches, TermMatch{Term: node.term, Distance: distance})
+		}
+		// Only children whose edge label lies within maxDistance of distance can match
+		for edge, child := range node.children {
+			if edge >= distance-maxDistance && edge <= distance+maxDistance {
+				stack = append(stack, child)
+			}
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Distance != matches[j].Distance {
+			return matches[i].Distance < matches[j].Distance
+		}
+		return matches[i].Term < matches[j].Term
+	})
+	return matches
+}
+
+// Fuzzy maps each query word to nearby vocabulary terms and scores the chunks containing them
+// Parameters:
+//
+//	tree: BK-tree over the index's vocabulary
+//	postings: Inverted index from terms to the chunks containing them
+//	query: Free-text query
+//	maxDistance: Maximum edit distance per word
+//	skip: Reports chunks to leave out of the results; nil keeps every chunk
+//
+// Returns:
+//
+//	FuzzyMatches: Chunk scores, the terms matched in each chunk and the matches of each query word
+func Fuzzy(tree *BKTree, postings map[string][]Posting, query string, maxDistance int, skip func(chunk int) bool) FuzzyMatches {
+	result := FuzzyMatches{
+		Scores:  make(map[int]float64),
+		Terms:   make(map[int][]string),
+		Matches: make(map[string][]TermMatch),
+	}
+
+	// Best (highest) contribution of each query word to each chunk
+	chunkTerms := make(map[int]map[string]bool)
+	for _, queryTerm := range UniqueTerms(query) {
+		termMatches := tree.Search(queryTerm, maxDistance)
+		result.Matches[queryTerm] = termMatches
+
+		best := make(map[int]float64)
+		for _, match := range termMatches {
+			weight := 1 / float64(1+match.Distance)
+			for _, posting := range postings[match.Term] {
+				if skip != nil && skip(posting.Chunk) {
+					continue
+				}
+				if weight > best[posting.Chunk] {
+					best[posting.Chunk] = weight
+				}
+				if chunkTerms[posting.Chunk] == nil {
+					chunkTerms[posting.Chunk] = make(map[string]bool)
+				}
+				chunkTerms[posting.Chunk][match.Term] = true
+			}
+		}
+		for chunk, weight := range best {
+			result.Scores[chunk] += weight
+		}
+	}
+
+	for chunk, terms := range chunkTerms {
+		sorted := make([]string, 0, len(terms))
+		for term := range terms {
+			sorted = append(sorted, term)
+		}
+		sort.Strings(sorted)
+		result.Terms[chunk] = sorted
+	}
+	return result
+}
+
+// Levenshtein computes the edit distance between two strings
+// Insertions, deletions and substitutions of single runes each cost 1
+func Levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
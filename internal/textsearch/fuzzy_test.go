@@ -0,0 +1,71 @@
+package textsearch
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b   string
+		expect int
+	}{
+		{"", "", 0},
+		{"jungle", "jungle", 0},
+		{"jungle", "jungel", 2}, // Transposition costs two edits
+		{"wolf", "wolfs", 1},    // Insertion
+		{"mowgli", "mowgly", 1}, // Substitution
+		{"", "bear", 4},
+		{"café", "cafe", 1}, // Multi-byte runes count once
+	}
+
+	for _, tt := range tests {
+		if got := Levenshtein(tt.a, tt.b); got != tt.expect {
+			t.Errorf("Levenshtein(%q, %q) = %d; want %d", tt.a, tt.b, got, tt.expect)
+		}
+	}
+}
+
+func TestBKTreeSearch(t *testing.T) {
+	tree := NewBKTree([]string{"bagheera", "baloo", "jungle", "mowgli", "wolf", "wolves", "words"})
+
+	got := tree.Search("wolfs", 1)
+	want := []TermMatch{{Term: "wolf", Distance: 1}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Search(wolfs, 1) = %v, want %v", got, want)
+	}
+
+	got = tree.Search("wolfs", 2)
+	want = []TermMatch{{Term: "wolf", Distance: 1}, {Term: "wolves", Distance: 2}, {Term: "words", Distance: 2}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Search(wolfs, 2) = %v, want %v", got, want)
+	}
+
+	if got := NewBKTree(nil).Search("wolf", 2); len(got) != 0 {
+		t.Errorf("Expected no matches from empty tree, got %v", got)
+	}
+}
+
+func TestFuzzy(t *testing.T) {
+	postings := map[string][]Posting{
+		"mowgli": {{Chunk: 0, Freq: 1}},
+		"baloo":  {{Chunk: 1, Freq: 1}},
+	}
+	tree := NewBKTree(BuildVocabulary(postings))
+
+	result := Fuzzy(tree, postings, "mowgly balu", 2, nil)
+	if result.Scores[0] != 0.5 || result.Scores[1] != 1.0/3 {
+		t.Errorf("Unexpected scores %v", result.Scores)
+	}
+	if !reflect.DeepEqual(result.Terms[0], []string{"mowgli"}) {
+		t.Errorf("Unexpected terms %v", result.Terms)
+	}
+	if !reflect.DeepEqual(result.Matches["mowgly"], []TermMatch{{Term: "mowgli", Distance: 1}}) {
+		t.Errorf("Unexpected matches for mowgly: %v", result.Matches["mowgly"])
+	}
+
+	skip := func(chunk int) bool { return chunk == 0 }
+	if result := Fuzzy(tree, postings, "mowgly", 2, skip); len(result.Scores) != 0 {
+		t.Errorf("Expected skipped chunk to be left out, got %v", result.Scores)
+	}
+}
@@ -0,0 +1,81 @@
+// Package textsearch holds the word-level text processing shared by the
+// command-line tool and the web server: tokenizing, BM25 keyword scoring,
+// typo-tolerant term lookup, local alignment of a query against text and
+// word-level diffs
+package textsearch
+
+import (
+	"regexp"
+	"strings"
+)
+
+// wordPattern matches the words used as search terms
+// It is plain runs of word characters and apostrophes; unlike the SimHash
+// feature pattern it does not keep URLs together, so "http://example.com/a"
+// yields the terms "http", "example", "com" and "a"
+var wordPattern = regexp.MustCompile(`[\w']+`)
+
+// Token is a lowercase word and its byte range in the text it came from
+type Token struct {
+	Term string
+	// Term is the lowercase word
+
+	Start int
+	// Start is the byte offset of the word in the text
+
+	End int
+	// End is the byte offset just past the word
+}
+
+// Tokenize splits text into lowercase word terms
+// Parameters:
+//
+//	text: Text to split
+//
+// Returns:
+//
+//	[]string: Terms in order of appearance, duplicates included
+func Tokenize(text string) []string {
+	return wordPattern.FindAllString(strings.ToLower(text), -1)
+}
+
+// TokenizeWithOffsets splits text into lowercase words, keeping their byte ranges
+func TokenizeWithOffsets(text string) []Token {
+	locations := wordPattern.FindAllStringIndex(text, -1)
+	tokens := make([]Token, len(locations))
+	for i, loc := range locations {
+		tokens[i] = Token{Term: strings.ToLower(text[loc[0]:loc[1]]), Start: loc[0], End: loc[1]}
+	}
+	return tokens
+}
+
+// TermFrequencies counts how often each term occurs in text
+// Parameters:
+//
+//	text: Text to analyse
+//
+// Returns:
+//
+//	map[string]int: Number of occurrences of each term
+//	int: Total number of terms in the text
+func TermFrequencies(text string) (map[string]int, int) {
+	terms := Tokenize(text)
+	freqs := make(map[string]int, len(terms))
+	for _, term := range terms {
+		freqs[term]++
+	}
+	return freqs, len(terms)
+}
+
+// UniqueTerms tokenizes text and drops repeated terms, keeping first occurrences
+func UniqueTerms(text string) []string {
+	seen := make(map[string]bool)
+	var terms []string
+	for _, term := range Tokenize(text) {
+		if !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
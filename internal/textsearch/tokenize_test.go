@@ -0,0 +1,42 @@
+package textsearch
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	got := Tokenize("The Law of the Jungle -- it's old!")
+	want := []string{"the", "law", "of", "the", "jungle", "it's", "old"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Tokenize() = %v, want %v", got, want)
+	}
+
+	// URLs are split into their words, unlike SimHash features
+	got = Tokenize("see http://example.com/a")
+	want = []string{"see", "http", "example", "com", "a"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Tokenize() = %v, want %v", got, want)
+	}
+}
+
+func TestBM25Scores(t *testing.T) {
+	postings := map[string][]Posting{
+		"wolves": {{Chunk: 0, Freq: 1}, {Chunk: 2, Freq: 1}},
+		"bears":  {{Chunk: 1, Freq: 1}},
+	}
+	length := func(chunk int) int { return 4 }
+
+	scores := BM25Scores(postings, 3, length, "Wolves wolves", nil)
+	if len(scores) != 2 || scores[0] <= 0 || scores[0] != scores[2] {
+		t.Errorf("BM25Scores() = %v", scores)
+	}
+
+	skip := func(chunk int) bool { return chunk == 2 }
+	if scores := BM25Scores(postings, 3, length, "wolves", skip); len(scores) != 1 || scores[0] <= 0 {
+		t.Errorf("BM25Scores() with skip = %v", scores)
+	}
+	if scores := BM25Scores(postings, 3, length, "tigers", nil); len(scores) != 0 {
+		t.Errorf("Expected no scores for unknown term, got %v", scores)
+	}
+}